@@ -1,14 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"crypto/ed25519"
 	"encoding/hex"
+	"encoding/json"
 	"path/filepath"
+	"strconv"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	tmconfig "github.com/tendermint/tendermint/config"
@@ -16,15 +24,20 @@ import (
 	tmjson "github.com/tendermint/tendermint/libs/json"
 	tmlog "github.com/tendermint/tendermint/libs/log"
 	tmos "github.com/tendermint/tendermint/libs/os"
+	tmtypes "github.com/tendermint/tendermint/types"
 	tmversion "github.com/tendermint/tendermint/version"
 
 	"github.com/zennetwork/zennetwork/x/consensus"
+	"github.com/zennetwork/zennetwork/x/mempool"
 	"github.com/zennetwork/zennetwork/x/network"
 	"github.com/zennetwork/zennetwork/x/vm"
 	"github.com/zennetwork/zennetwork/x/oracle"
 	"github.com/zennetwork/zennetwork/x/halving"
+	"github.com/zennetwork/zennetwork/x/lightclient"
 	"github.com/zennetwork/zennetwork/x/fees"
 	"github.com/zennetwork/zennetwork/x/security"
+	"github.com/zennetwork/zennetwork/x/service"
+	"github.com/zennetwork/zennetwork/x/tokenomics"
 	"github.com/zennetwork/zennetwork/x/zenkit"
 )
 
@@ -42,6 +55,17 @@ var (
 	nodeIP          string
 	enableAnalytics bool
 	validatorMode   bool
+	rpcAddr         string
+	metricsAddr     string
+)
+
+// Light client flags
+var (
+	lightPrimary        string
+	lightWitnesses      []string
+	lightTrustedHeight  int64
+	lightTrustedHash    string
+	lightTrustingPeriod time.Duration
 )
 
 var rootCmd = &cobra.Command{
@@ -89,6 +113,14 @@ func main() {
 	rootCmd.PersistentFlags().StringVar(&nodeIP, "node-ip", "", "IP address for P2P networking")
 	rootCmd.PersistentFlags().BoolVar(&enableAnalytics, "analytics", false, "enable anonymous analytics (default: false)")
 	rootCmd.PersistentFlags().BoolVar(&validatorMode, "validator", false, "run as validator node (default: false)")
+	rootCmd.PersistentFlags().StringVar(&rpcAddr, "rpc-laddr", "127.0.0.1:26657", "address the node serves light client RPC on")
+	rootCmd.PersistentFlags().StringVar(&metricsAddr, "metrics-laddr", "127.0.0.1:26660", "address the node serves Prometheus metrics on")
+
+	lightCmd.Flags().StringVar(&lightPrimary, "primary", "http://127.0.0.1:26657", "RPC URL of the node to follow")
+	lightCmd.Flags().StringSliceVar(&lightWitnesses, "witnesses", nil, "RPC URLs of nodes to cross-check the primary against")
+	lightCmd.Flags().Int64Var(&lightTrustedHeight, "trusted-height", 1, "height of the trusted header")
+	lightCmd.Flags().StringVar(&lightTrustedHash, "trusted-hash", "", "hex hash of the trusted header")
+	lightCmd.Flags().DurationVar(&lightTrustingPeriod, "trusting-period", lightclient.DefaultTrustingPeriod, "how long a trusted header can be verified from")
 
 	// Add subcommands
 	rootCmd.AddCommand(initCmd)
@@ -98,6 +130,7 @@ func main() {
 	rootCmd.AddCommand(validateGenesisCmd)
 	rootCmd.AddCommand(debugCmd)
 	rootCmd.AddCommand(toolsCmd)
+	rootCmd.AddCommand(lightCmd)
 
 	// Register aliases
 	rootCmd.AddCommand(&cobra.Command{
@@ -168,6 +201,24 @@ To connect to testnet:
 	RunE: runNode,
 }
 
+// lightCmd runs a light client
+var lightCmd = &cobra.Command{
+	Use:   "light",
+	Short: "Run a light client",
+	Long: fmt.Sprintf(`
+Follow the chain without running a full node.
+
+The light client starts from a trusted header and verifies new headers
+from a primary node's RPC by their commit signatures, skipping ahead with
+bisection when the validator set changes. Witness nodes are cross-checked
+and a conflicting header they can prove halts the client.
+
+Example:
+  %s light --trusted-height 1 --trusted-hash <hash> --witnesses http://10.0.0.2:26657
+`, AppName),
+	RunE: runLight,
+}
+
 // statusCmd shows node status
 var statusCmd = &cobra.Command{
 	Use:   "status",
@@ -361,7 +412,9 @@ func initializeNode(moniker string) error {
 
 	// Create validator keypair if requested
 	if validatorMode {
-		generateValidatorKeys(keysDir)
+		if err := generateValidatorKeys(keysDir); err != nil {
+			return err
+		}
 		fmt.Println("✓ Validator keys generated")
 	}
 
@@ -434,10 +487,10 @@ func createGenesisTemplate() map[string]interface{} {
 				"adaptive_enabled":  true,
 			},
 			"fees": map[string]interface{}{
-				"base_fee":     "100000000000000", // 0.0001 ZEN
+				"base_fee":     "4000000000", // 4 gwei per gas
 				"burn_percent": 20,
 				"min_tip":      "0",
-				"max_tip":      "1000000000000", // 0.001 ZEN
+				"max_tip":      "1000000000", // 1 gwei per gas
 			},
 			"consensus": map[string]interface{}{
 				"consensus_type": "pos_poh_hybrid",
@@ -462,14 +515,110 @@ func createGenesisTemplate() map[string]interface{} {
 	}
 }
 
+// validatorKeyFile is the on-disk format of priv_validator_key.json
+type validatorKeyFile struct {
+	Address    string `json:"address"`
+	PubKey     string `json:"pub_key"`
+	PrivKey    string `json:"priv_key"`
+	VRFPubKey  string `json:"vrf_pub_key"`
+	VRFPrivKey string `json:"vrf_priv_key"`
+}
+
 // Generate validator keypair
-func generateValidatorKeys(keysDir string) {
-	// This would generate actual validator keys in production
-	// For now, we create placeholders
-	privKeyPath := filepath.Join(keysDir, "priv_validator_key.json")
-	_, _ = os.Create(privKeyPath)
+func generateValidatorKeys(keysDir string) error {
+	pubKey, privKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return fmt.Errorf("failed to generate validator key: %w", err)
+	}
+	vrfKey, err := security.GenerateVRFKey()
+	if err != nil {
+		return fmt.Errorf("failed to generate VRF key: %w", err)
+	}
+
+	keyFile := validatorKeyFile{
+		Address:    hex.EncodeToString(consensus.ValidatorAddress(pubKey)),
+		PubKey:     hex.EncodeToString(pubKey),
+		PrivKey:    hex.EncodeToString(privKey),
+		VRFPubKey:  hex.EncodeToString(security.VRFPublicKeyBytes(&vrfKey.PublicKey)),
+		VRFPrivKey: hex.EncodeToString(security.VRFPrivateKeyBytes(vrfKey)),
+	}
 
-	fmt.Println("Note: Generate actual validator keys with production implementation")
+	bz, err := tmjson.MarshalIndent(keyFile, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(keysDir, "priv_validator_key.json"), bz, 0600)
+}
+
+// Load the validator keypair into the consensus engine
+func loadValidatorKeys(keysDir string, cons *consensus.Consensus) error {
+	bz, err := os.ReadFile(filepath.Join(keysDir, "priv_validator_key.json"))
+	if err != nil {
+		return fmt.Errorf("failed to read validator key: %w", err)
+	}
+
+	var keyFile validatorKeyFile
+	if err := tmjson.Unmarshal(bz, &keyFile); err != nil {
+		return fmt.Errorf("failed to parse validator key: %w", err)
+	}
+
+	privKey, err := hex.DecodeString(keyFile.PrivKey)
+	if err != nil || len(privKey) != ed25519.PrivateKeySize {
+		return fmt.Errorf("invalid validator private key")
+	}
+	vrfBytes, err := hex.DecodeString(keyFile.VRFPrivKey)
+	if err != nil {
+		return fmt.Errorf("invalid VRF private key: %w", err)
+	}
+	vrfKey, err := security.VRFPrivateKeyFromBytes(vrfBytes)
+	if err != nil {
+		return err
+	}
+
+	signKey := ed25519.PrivateKey(privKey)
+	address := consensus.ValidatorAddress(signKey.Public().(ed25519.PublicKey))
+	cons.SetValidatorKeys(address, signKey, vrfKey)
+	return nil
+}
+
+// Load the genesis chain ID and evidence params into the consensus engine
+func loadEvidenceParams(genesisPath string, cons *consensus.Consensus) error {
+	bz, err := os.ReadFile(genesisPath)
+	if os.IsNotExist(err) {
+		return nil // Keep the defaults
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read genesis: %w", err)
+	}
+
+	var genesis struct {
+		ChainID         string `json:"chain_id"`
+		ConsensusParams struct {
+			Evidence struct {
+				MaxAgeNumBlocks int64  `json:"max_age_num_blocks"`
+				MaxAgeDuration  string `json:"max_age_duration"` // Nanoseconds
+			} `json:"evidence"`
+		} `json:"consensus_params"`
+	}
+	if err := json.Unmarshal(bz, &genesis); err != nil {
+		return fmt.Errorf("failed to parse genesis: %w", err)
+	}
+
+	if genesis.ChainID != "" {
+		cons.ChainID = genesis.ChainID
+	}
+	evidence := genesis.ConsensusParams.Evidence
+	if evidence.MaxAgeNumBlocks > 0 {
+		cons.EvidenceParams.MaxAgeNumBlocks = evidence.MaxAgeNumBlocks
+	}
+	if evidence.MaxAgeDuration != "" {
+		nanos, err := strconv.ParseInt(evidence.MaxAgeDuration, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid evidence max_age_duration: %w", err)
+		}
+		cons.EvidenceParams.MaxAgeDuration = time.Duration(nanos)
+	}
+	return nil
 }
 
 // Run the node
@@ -479,48 +628,101 @@ func runNode(cmd *cobra.Command, args []string) error {
 	// Initialize core modules
 	network := network.New()
 	consensus := consensus.New()
+	txPool := mempool.New()
 	vm := vm.NewEVM()
 	halving := halving.New()
-	fees := fees.New()
+	fees := newFeeSystem()
+	tokenomics := tokenomics.New()
 	security := security.New()
 	oracle := oracle.New()
 	zenkit := zenkit.NewSDK()
 
+	// Services run until SIGINT or SIGTERM, then stop in reverse order
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	started := make([]service.Service, 0)
+	defer func() { stopServices(started) }()
+
 	// Start services
 	fmt.Println("✓ Initializing P2P network...")
-	if err := network.Start(); err != nil {
+	if err := network.Start(ctx); err != nil {
 		return fmt.Errorf("network start failed: %w", err)
 	}
+	started = append(started, network)
+
+	fmt.Println("✓ Starting halving engine (AEH)...")
+	if err := halving.Start(ctx); err != nil {
+		return fmt.Errorf("halving start failed: %w", err)
+	}
+	started = append(started, halving)
 
 	fmt.Println("✓ Starting consensus engine (PoS + PoH)...")
-	if err := consensus.Start(); err != nil {
+	wireConsensusGossip(network, consensus)
+	wireTxGossip(network, txPool)
+	consensus.SetMempool(txPool)
+	wireFeeChecks(txPool, fees)
+	// Fees settle to the proposer through x/fees; consensus only pays the
+	// minted block reward
+	consensus.SetRewardSources(halving, nil)
+	fees.SetBurner(tokenomics)
+	wireFeeSettlement(consensus, fees)
+	if err := loadEvidenceParams(filepath.Join(homeDir, "config", "genesis.json"), consensus); err != nil {
+		return fmt.Errorf("genesis: %w", err)
+	}
+	if err := consensus.OpenStorage(filepath.Join(homeDir, "data")); err != nil {
+		return fmt.Errorf("consensus storage: %w", err)
+	}
+	if validatorMode {
+		if err := loadValidatorKeys(filepath.Join(homeDir, "keys"), consensus); err != nil {
+			consensus.Stop()
+			return fmt.Errorf("validator keys: %w", err)
+		}
+	}
+	if err := consensus.Start(ctx); err != nil {
+		consensus.Stop()
 		return fmt.Errorf("consensus start failed: %w", err)
 	}
+	started = append(started, consensus)
 
-	fmt.Println("✓ Initializing EVM parallel executor...")
-	if err := vm.Start(); err != nil {
-		return fmt.Errorf("vm start failed: %w", err)
+	fmt.Println("✓ Serving light client RPC...")
+	rpc := lightclient.NewServer(rpcAddr, lightclient.NewNodeProvider(consensus))
+	if err := rpc.Start(ctx); err != nil {
+		return fmt.Errorf("light client RPC start failed: %w", err)
 	}
+	started = append(started, rpc)
 
-	fmt.Println("✓ Starting halving engine (AEH)...")
-	if err := halving.Start(); err != nil {
-		return fmt.Errorf("halving start failed: %w", err)
+	fmt.Println("✓ Serving Prometheus metrics...")
+	metrics := service.NewMetricsServer(metricsAddr, consensus.Metrics())
+	if err := metrics.Start(ctx); err != nil {
+		return fmt.Errorf("metrics server start failed: %w", err)
 	}
+	started = append(started, metrics)
+
+	fmt.Println("✓ Initializing EVM parallel executor...")
+	if err := vm.Start(ctx); err != nil {
+		return fmt.Errorf("vm start failed: %w", err)
+	}
+	started = append(started, vm)
+	wireReorgs(consensus, vm)
 
 	fmt.Println("✓ Initializing fee system (low fees, 20% burn)...")
-	if err := fees.Start(); err != nil {
+	if err := fees.Start(ctx); err != nil {
 		return fmt.Errorf("fees start failed: %w", err)
 	}
+	started = append(started, fees)
 
 	fmt.Println("✓ Starting security module (MPC, anomaly detection)...")
-	if err := security.Start(); err != nil {
+	if err := security.Start(ctx); err != nil {
 		return fmt.Errorf("security start failed: %w", err)
 	}
+	started = append(started, security)
 
 	fmt.Println("✓ Starting AI-native oracle...")
-	if err := oracle.Start(); err != nil {
+	if err := oracle.Start(ctx); err != nil {
 		return fmt.Errorf("oracle start failed: %w", err)
 	}
+	started = append(started, oracle)
 
 	fmt.Println("✓ Initializing ZenKit SDK...")
 	if err := zenkit.Initialize(); err != nil {
@@ -541,8 +743,253 @@ func runNode(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Security: Post-quantum crypto + MPC\n")
 	fmt.Println(strings.Repeat("=", 60))
 
-	// Keep the node running
-	select {}
+	// Keep the node running until signalled
+	<-ctx.Done()
+	fmt.Println("\nShutting down ZenNetwork Node...")
+	return nil
+}
+
+// runLight follows the primary's chain until signalled, halting on a fork
+func runLight(cmd *cobra.Command, args []string) error {
+	hash, err := hex.DecodeString(lightTrustedHash)
+	if err != nil || len(hash) == 0 {
+		return fmt.Errorf("--trusted-hash must be a hex header hash")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	witnesses := make([]lightclient.Provider, len(lightWitnesses))
+	for i, url := range lightWitnesses {
+		witnesses[i] = lightclient.NewHTTPProvider(url)
+	}
+	options := lightclient.TrustOptions{Period: lightTrustingPeriod, Height: lightTrustedHeight, Hash: hash}
+	client, err := lightclient.NewClient(ctx, options, lightclient.NewHTTPProvider(lightPrimary), witnesses)
+	if err != nil {
+		return fmt.Errorf("light client: %w", err)
+	}
+
+	ticker := time.NewTicker(consensus.BlockTime * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		lb, err := client.Update(ctx, time.Now())
+		var fork *lightclient.ForkError
+		switch {
+		case errors.As(err, &fork):
+			return err
+		case err != nil:
+			fmt.Printf("Light client update failed: %v\n", err)
+		case lb != nil:
+			fmt.Printf("Verified height %d: %X\n", lb.Height(), lb.Hash())
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// stopServices stops started services in reverse order, waiting for
+// each one's goroutines to exit
+func stopServices(started []service.Service) {
+	for i := len(started) - 1; i >= 0; i-- {
+		if err := started[i].Stop(); err != nil {
+			fmt.Printf("Error stopping service: %v\n", err)
+		}
+	}
+}
+
+// Gossip BFT proposals and votes over the consensus protocol
+func wireConsensusGossip(net *network.Network, cons *consensus.Consensus) {
+	cons.SetBroadcaster(func(msg consensus.ConsensusMessage) {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return
+		}
+		net.BroadcastMessage(network.NetworkMessage{
+			Type:      network.MsgTypeConsensus,
+			Data:      data,
+			Timestamp: time.Now().Unix(),
+		})
+	})
+
+	net.RegisterListener(network.MsgTypeConsensus, func(msg network.NetworkMessage) {
+		var consMsg consensus.ConsensusMessage
+		if err := json.Unmarshal(msg.Data, &consMsg); err != nil {
+			return
+		}
+		if err := cons.HandleMessage(consMsg); err != nil {
+			fmt.Printf("Rejected consensus message from %s: %v\n", msg.PeerID, err)
+		}
+	})
+}
+
+// wireReorgs reverts non-final execution when the fork choice switches
+// branches and makes it permanent once blocks commit
+func wireReorgs(cons *consensus.Consensus, evm *vm.EVM) {
+	cons.RegisterReorgListener(func(reorg consensus.ReorgEvent) {
+		if err := evm.RollbackTo(reorg.AncestorHeight()); err != nil {
+			fmt.Printf("[EVM] Reorg rollback failed: %v\n", err)
+		}
+	})
+	cons.RegisterCommitListener(func(block *tmtypes.Block, _ *tmtypes.Commit) {
+		evm.Finalize(block.Header.Height)
+	})
+}
+
+// newFeeSystem creates the fee system under the EIP-1559 model, whose base
+// fee follows the gas usage of committed blocks
+func newFeeSystem() *fees.Fees {
+	f := fees.New()
+	f.SetFeeModel(fees.Solidity)
+	return f
+}
+
+// wireFeeSettlement pays out the fees of every committed block: to its
+// proposer and the committee members that produced its shard blocks. It then
+// moves the base fee, resource fees and local fee floors past the block and
+// tallies the fee proposals due by the next block against the validators.
+func wireFeeSettlement(cons *consensus.Consensus, f *fees.Fees) {
+	cons.RegisterCommitListener(func(block *tmtypes.Block, _ *tmtypes.Commit) {
+		height := block.Header.Height
+		baseFee := f.BaseFee()
+		var used fees.Resources
+		accounts := make(map[common.Address]uint64)
+		txs := make([]*fees.Transaction, 0, len(block.Data.Txs))
+		for _, bz := range block.Data.Txs {
+			tx, err := mempool.DecodeTx(bz)
+			if err != nil {
+				continue
+			}
+			hash := mempool.TxHash(bz)
+			if err := tx.VerifyFeePayer(); err != nil {
+				fmt.Printf("[FEES] Tx %s not charged at height %d: %v\n", hash.Hex(), height, err)
+				continue
+			}
+			fee, err := settledFee(f, height, tx)
+			if err != nil {
+				fmt.Printf("[FEES] Tx %s not charged at height %d: %v\n", hash.Hex(), height, err)
+				continue
+			}
+			for r, amount := range txResources(tx, fee.GasUsed) {
+				used[r] += amount
+			}
+			accounts[tx.From] += fee.GasUsed
+			if tx.To != (common.Address{}) && tx.To != tx.From {
+				accounts[tx.To] += fee.GasUsed
+			}
+			txs = append(txs, &fees.Transaction{
+				Hash:        hash,
+				From:        tx.From,
+				To:          tx.To,
+				GasLimit:    tx.GasLimit,
+				GasUsed:     fee.GasUsed,
+				Fee:         *fee,
+				BlockNumber: height,
+				Timestamp:   block.Header.Time.Unix(),
+				TxType:      tx.Type(),
+				FeePayer:    tx.FeePayer,
+			})
+		}
+
+		committee := make([]common.Address, 0)
+		if shards, err := consensus.ShardBlocksOf(block); err == nil {
+			for _, sb := range shards {
+				committee = append(committee, common.BytesToAddress(sb.Producer))
+			}
+		}
+		if _, err := f.SettleBlock(height, baseFee, cons.BlockGasLimit, common.BytesToAddress(block.Header.Proposer), committee, txs); err != nil {
+			fmt.Printf("[FEES] Settlement failed at height %d: %v\n", height, err)
+		}
+		parent := fees.ParentBlock{Height: height, GasLimit: cons.BlockGasLimit, GasUsed: used[fees.ResourceGas], BaseFee: baseFee}
+		if _, err := f.UpdateBaseFee(parent); err != nil {
+			fmt.Printf("[FEES] Base fee not updated at height %d: %v\n", height, err)
+		}
+		if _, err := f.UpdateDimensionFees(used); err != nil {
+			fmt.Printf("[FEES] Resource fees not updated at height %d: %v\n", height, err)
+		}
+		f.UpdateLocalFees(accounts)
+
+		// Fee proposals due by the next block come into force before it
+		validators, err := cons.LoadValidators(height)
+		if err != nil {
+			fmt.Printf("[FEES] Fee proposals not tallied at height %d: %v\n", height+1, err)
+			return
+		}
+		powers := make(map[common.Address]uint64, len(validators))
+		for _, val := range validators {
+			powers[common.BytesToAddress(val.Address)] = uint64(consensus.VotingPower(val))
+		}
+		f.ActivateFeeProposals(height+1, powers)
+	})
+}
+
+// wireFeeChecks admits to the mempool only transactions paying the current
+// fees, sponsored ones within their allowance, and drops pending ones that
+// no longer qualify
+func wireFeeChecks(pool *mempool.Mempool, f *fees.Fees) {
+	pool.SetCheckTx(func(tx *mempool.Tx) error {
+		if err := f.CheckFee(&tx.Fee, tx.Type(), tx.From, tx.To); err != nil {
+			return err
+		}
+		if tx.Sponsored() {
+			return f.CheckAllowance(tx.FeePayer, tx.From, tx.To, tx.Fee.Total)
+		}
+		return nil
+	})
+}
+
+// settledFee recomputes a committed transaction's fee from its gas limit
+// and tip at the prices of its block, charged for the gas it executed. The
+// fee the sender put on the wire is not trusted.
+func settledFee(f *fees.Fees, height int64, tx *mempool.Tx) (*fees.Fee, error) {
+	prepaid, err := f.CalculateFeeAt(height, tx.GasLimit, tx.Fee.Tip, tx.Type())
+	if err != nil {
+		return nil, err
+	}
+	return f.SettleFee(height, prepaid, executedGas(tx))
+}
+
+// executedGas returns the gas a committed transaction is charged for. The
+// node does not execute block transactions yet, so a transfer is charged
+// its intrinsic gas and anything else its whole gas limit.
+func executedGas(tx *mempool.Tx) uint64 {
+	if tx.Type() == "transfer" {
+		return min(fees.TransferGas, tx.GasLimit)
+	}
+	return tx.GasLimit
+}
+
+// txResources returns what a committed transaction used of each fee
+// resource: its gas, its calldata, the code a deployment stores and a
+// message when it calls across execution shards
+func txResources(tx *mempool.Tx, gasUsed uint64) fees.Resources {
+	var used fees.Resources
+	used[fees.ResourceGas] = gasUsed
+	used[fees.ResourceCalldataBytes] = uint64(len(tx.Data))
+	if tx.Type() == "contract_deploy" {
+		used[fees.ResourceStateBytes] = uint64(len(tx.Data))
+	} else if fees.AccountShard(tx.From, fees.DefaultLocalShards) != fees.AccountShard(tx.To, fees.DefaultLocalShards) {
+		used[fees.ResourceCrossShard] = 1
+	}
+	return used
+}
+
+// Insert transactions gossiped over the tx protocol into the mempool and
+// relay the ones that are new
+func wireTxGossip(net *network.Network, pool *mempool.Mempool) {
+	net.RegisterListener(network.MsgTypeTx, func(msg network.NetworkMessage) {
+		if err := pool.Insert(msg.Data); err != nil {
+			return
+		}
+		net.BroadcastMessage(network.NetworkMessage{
+			Type:      network.MsgTypeTx,
+			Data:      msg.Data,
+			Timestamp: time.Now().Unix(),
+		})
+	})
 }
 
 // Initialize config
@@ -36,6 +36,9 @@ require (
 	github.com/owulveryck/onnx-go v0.0.0-20240312143317-9307b2b62c15
 	github.com/schu/eGon-scraper v0.0.0-20240315195929-86e43e5b33d7
 
+	// Storage
+	github.com/syndtr/goleveldb v1.0.1-0.20220721030215-126854af5e6e
+
 	// Logging and Monitoring
 	github.com/rs/zerolog v1.32.0
 	github.com/prometheus/client_golang v1.19.1
@@ -44,6 +47,9 @@ require (
 	github.com/spf13/cobra v1.8.1
 	github.com/spf13/viper v1.19.0
 	github.com/pelletier/go-toml/v2 v2.2.2
+
+	// Testing
+	go.uber.org/goleak v1.3.0
 )
 
 require (
@@ -153,7 +159,6 @@ require (
 	github.com/status-im/keycard-go v0.2.0 // indirect
 	github.com/stretchr/testify v1.9.0 // indirect
 	github.com/subosito/gotenv v1.6.0 // indirect
-	github.com/syndtr/goleveldb v1.0.1-0.20220721030215-126854af5e6e // indirect
 	github.com/tendermint/btcd v0.1.1 // indirect
 	github.com/tendermint/crypto v0.0.0-20191022155603-50747014af02 // indirect
 	github.com/tendermint/go-amino v0.16.0 // indirect
@@ -169,7 +174,6 @@ require (
 	go.opentelemetry.io/otel/metric v1.27.0 // indirect
 	go.opentelemetry.io/otel/trace v1.27.0 // indirect
 	go.uber.org/automaxprocs v1.5.3 // indirect
-	go.uber.org/goleak v1.3.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	go.uber.org/zap v1.27.0 // indirect
 	golang.org/x/mod v0.17.0 // indirect
@@ -1,6 +1,8 @@
 package tests
 
 import (
+	"context"
+	"crypto/ed25519"
 	"crypto/rand"
 	"testing"
 	"time"
@@ -8,6 +10,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/types"
+	tmtypes "github.com/tendermint/tendermint/types"
 	"github.com/zennetwork/zennetwork/x/consensus"
 	"github.com/zennetwork/zennetwork/x/fees"
 	"github.com/zennetwork/zennetwork/x/halving"
@@ -17,33 +20,41 @@ import (
 
 // BenchmarkConsensus benchmarks consensus performance
 func BenchmarkConsensus(b *testing.B) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	vrfKey, _ := security.GenerateVRFKey()
+	address := consensus.ValidatorAddress(pub)
+
+	// Single validator so every height is proposed, voted and committed locally
 	cons := consensus.New()
-	if err := cons.Start(); err != nil {
-		b.Fatalf("Failed to start consensus: %v", err)
-	}
+	cons.TimeoutCommit = 0
+	cons.ValidatorSet = append(cons.ValidatorSet, consensus.Validator{
+		Address:   address,
+		PubKey:    pub,
+		Power:     1,
+		VRFPubKey: security.VRFPublicKeyBytes(&vrfKey.PublicKey),
+	})
+	cons.SetValidatorKeys(address, priv, vrfKey)
 
-	b.ResetTimer()
-	b.RunParallel(func(pb *testing.PB) {
-		i := 0
-		for pb.Next() {
-			// Simulate block production
-			txs := generateTestTxs(100)
-			block, err := cons.ProduceBlock(int64(i), txs)
-			if err != nil {
-				b.Errorf("Block production failed: %v", err)
-			}
-			if err := cons.CommitBlock(block); err != nil {
-				b.Errorf("Block commit failed: %v", err)
-			}
-			i++
+	done := make(chan struct{})
+	cons.RegisterCommitListener(func(block *tmtypes.Block, commit *tmtypes.Commit) {
+		if block.Header.Height == int64(b.N) {
+			close(done)
 		}
 	})
+
+	b.ResetTimer()
+	if err := cons.Start(context.Background()); err != nil {
+		b.Fatalf("Failed to start consensus: %v", err)
+	}
+	<-done
+	b.StopTimer()
+	cons.Stop()
 }
 
 // BenchmarkVM benchmarks EVM parallel execution
 func BenchmarkVM(b *testing.B) {
 	vm := vm.NewEVM()
-	if err := vm.Start(); err != nil {
+	if err := vm.Start(context.Background()); err != nil {
 		b.Fatalf("Failed to start VM: %v", err)
 	}
 
@@ -63,7 +74,7 @@ func BenchmarkVM(b *testing.B) {
 // BenchmarkFees benchmarks fee calculation
 func BenchmarkFees(b *testing.B) {
 	f := fees.New()
-	if err := f.Start(); err != nil {
+	if err := f.Start(context.Background()); err != nil {
 		b.Fatalf("Failed to start fees: %v", err)
 	}
 
@@ -79,7 +90,7 @@ func BenchmarkFees(b *testing.B) {
 // BenchmarkHalving benchmarks halving calculations
 func BenchmarkHalving(b *testing.B) {
 	h := halving.New()
-	if err := h.Start(); err != nil {
+	if err := h.Start(context.Background()); err != nil {
 		b.Fatalf("Failed to start halving: %v", err)
 	}
 
@@ -101,7 +112,7 @@ func BenchmarkSecurity(b *testing.B) {
 	if err := s.Initialize("/tmp"); err != nil {
 		b.Fatalf("Failed to initialize security: %v", err)
 	}
-	if err := s.Start(); err != nil {
+	if err := s.Start(context.Background()); err != nil {
 		b.Fatalf("Failed to start security: %v", err)
 	}
 
@@ -146,7 +157,7 @@ func BenchmarkMPC(b *testing.B) {
 // BenchmarkTPS measures transactions per second
 func BenchmarkTPS(b *testing.B) {
 	evm := vm.NewEVM()
-	if err := evm.Start(); err != nil {
+	if err := evm.Start(context.Background()); err != nil {
 		b.Fatalf("Failed to start VM: %v", err)
 	}
 
@@ -188,7 +199,7 @@ func TestZeroInflation(t *testing.T) {
 // TestBurnMechanism tests fee burning
 func TestBurnMechanism(t *testing.T) {
 	f := fees.New()
-	if err := f.Start(); err != nil {
+	if err := f.Start(context.Background()); err != nil {
 		t.Fatalf("Failed to start fees: %v", err)
 	}
 
@@ -198,8 +209,8 @@ func TestBurnMechanism(t *testing.T) {
 		t.Fatalf("Fee calculation failed: %v", err)
 	}
 
-	// Verify burn percentage
-	expectedBurn := float64(fee.BaseFee) * 0.20
+	// Verify burn percentage of the base fee for the gas used
+	expectedBurn := float64(fee.BaseFee*fee.GasUsed) * 0.20
 	if fee.Burned != uint64(expectedBurn) {
 		t.Errorf("Incorrect burn amount: got %d, want %d", fee.Burned, uint64(expectedBurn))
 	}
@@ -240,7 +251,7 @@ func TestAntiHackFeatures(t *testing.T) {
 // TestParallelExecution tests parallel transaction processing
 func TestParallelExecution(t *testing.T) {
 	evm := vm.NewEVM()
-	if err := evm.Start(); err != nil {
+	if err := evm.Start(context.Background()); err != nil {
 		t.Fatalf("Failed to start VM: %v", err)
 	}
 
@@ -292,7 +303,7 @@ func generateTestTxs(count int) [][]byte {
 // TestAEHHalving tests Adaptive Exponential Halving
 func TestAEHHalving(t *testing.T) {
 	h := halving.New()
-	if err := h.Start(); err != nil {
+	if err := h.Start(context.Background()); err != nil {
 		t.Fatalf("Failed to start halving: %v", err)
 	}
 
@@ -318,7 +329,7 @@ func TestAEHHalving(t *testing.T) {
 	}
 
 	// Reward should decrease due to halving
-	if reward2 >= reward1 {
+	if reward2.Cmp(reward1) >= 0 {
 		t.Errorf("Reward should decrease with halving: phase 0: %d, phase 1: %d", reward1, reward2)
 	}
 
@@ -328,7 +339,7 @@ func TestAEHHalving(t *testing.T) {
 // TestLowFees tests that fees are very low
 func TestLowFees(t *testing.T) {
 	f := fees.New()
-	if err := f.Start(); err != nil {
+	if err := f.Start(context.Background()); err != nil {
 		t.Fatalf("Failed to start fees: %v", err)
 	}
 
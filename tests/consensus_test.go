@@ -0,0 +1,875 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	"github.com/tendermint/tendermint/types"
+	"github.com/zennetwork/zennetwork/x/consensus"
+	"github.com/zennetwork/zennetwork/x/security"
+)
+
+// TestPoHChainVerification tests that a generated PoH chain re-verifies
+func TestPoHChainVerification(t *testing.T) {
+	genesis := consensus.ProofOfHistoryEntry{Hash: []byte("genesis")}
+	gen := consensus.NewPoHGenerator(genesis, 100, 8)
+
+	entries := make([]consensus.ProofOfHistoryEntry, 0, 32)
+	for i := 0; i < 32; i++ {
+		txs := generateTestTxs(i % 4)
+		entries = append(entries, gen.Record(consensus.TxHashes(txs), int64(i)))
+	}
+
+	if err := consensus.VerifyPoHEntries(genesis, entries, 100, 8); err != nil {
+		t.Fatalf("Valid PoH chain rejected: %v", err)
+	}
+
+	// Tampering with a mixin must break verification
+	entries[17].EntryData = []byte("tampered")
+	if err := consensus.VerifyPoHEntries(genesis, entries, 100, 8); err == nil {
+		t.Errorf("Tampered PoH chain accepted")
+	}
+
+	t.Log("✓ PoH hash chain verifies across CPU cores")
+}
+
+// TestPoHRejectsBrokenLink tests that entries must chain to their predecessor
+func TestPoHRejectsBrokenLink(t *testing.T) {
+	genesis := consensus.ProofOfHistoryEntry{Hash: []byte("genesis")}
+	gen := consensus.NewPoHGenerator(genesis, 10, 4)
+
+	first := gen.Record(nil, 0)
+	second := gen.Record(nil, 0)
+
+	if err := consensus.VerifyPoHEntry(first, second, 10, 4); err != nil {
+		t.Fatalf("Valid PoH link rejected: %v", err)
+	}
+	if err := consensus.VerifyPoHEntry(genesis, second, 10, 4); err == nil {
+		t.Errorf("PoH entry accepted without its predecessor")
+	}
+}
+
+// TestPoHEnforcesTickCount tests that entries must carry the configured amount of work
+func TestPoHEnforcesTickCount(t *testing.T) {
+	genesis := consensus.ProofOfHistoryEntry{Hash: []byte("genesis")}
+
+	// An entry with fewer hashes than configured proves no elapsed time
+	short := consensus.NewPoHGenerator(genesis, 10, 1).Record(nil, 0)
+	if err := consensus.VerifyPoHEntry(genesis, short, 10, 4); err == nil {
+		t.Errorf("PoH entry with too few ticks accepted")
+	}
+
+	// Claiming a huge hash count must fail before any hashing
+	entry := consensus.NewPoHGenerator(genesis, 10, 4).Record(nil, 0)
+	entry.NumHashes = 1 << 63
+	if err := consensus.VerifyPoHEntries(genesis, []consensus.ProofOfHistoryEntry{entry}, 10, 4); err == nil {
+		t.Errorf("PoH entry with forged hash count accepted")
+	}
+
+	// A forged intermediate tick must be caught
+	entry = consensus.NewPoHGenerator(genesis, 10, 4).Record(nil, 0)
+	entry.Ticks[1] = []byte("forged")
+	if err := consensus.VerifyPoHEntry(genesis, entry, 10, 4); err == nil {
+		t.Errorf("PoH entry with forged tick accepted")
+	}
+}
+
+// TestVRFProofs tests ECVRF proof generation and verification
+func TestVRFProofs(t *testing.T) {
+	s := security.New()
+
+	seed := []byte("zen-proposer-election")
+	output, proof, err := s.GenerateVRF(seed)
+	if err != nil {
+		t.Fatalf("VRF generation failed: %v", err)
+	}
+
+	if !s.VerifyVRF(seed, output, proof) {
+		t.Errorf("Valid VRF proof rejected")
+	}
+	if s.VerifyVRF([]byte("other seed"), output, proof) {
+		t.Errorf("VRF proof accepted for a different seed")
+	}
+
+	// Proofs are deterministic for the same key and seed
+	output2, _, err := s.GenerateVRF(seed)
+	if err != nil {
+		t.Fatalf("VRF generation failed: %v", err)
+	}
+	if string(output) != string(output2) {
+		t.Errorf("VRF output is not deterministic")
+	}
+}
+
+// TestProposerSelectionWeightedByStake tests that election frequency follows voting power
+func TestProposerSelectionWeightedByStake(t *testing.T) {
+	validators := make([]consensus.Validator, 4)
+	totalPower := int64(0)
+	for i := range validators {
+		address := make([]byte, 20)
+		address[0] = byte(i + 1)
+		validators[i] = consensus.Validator{Address: address, Power: int64(i + 1)}
+		totalPower += int64(i + 1)
+	}
+
+	const draws = 20000
+	wins := make(map[byte]int)
+	for h := int64(1); h <= draws; h++ {
+		seed := consensus.ProposerSeed([]byte("beacon"), h)
+		winner, err := consensus.ElectProposer(validators, seed, 0)
+		if err != nil {
+			t.Fatalf("Election failed: %v", err)
+		}
+		wins[winner[0]]++
+	}
+
+	for _, val := range validators {
+		expected := float64(draws) * float64(val.Power) / float64(totalPower)
+		got := float64(wins[val.Address[0]])
+		if math.Abs(got-expected) > 0.05*draws {
+			t.Errorf("Validator with power %d won %v times, expected ~%v", val.Power, got, expected)
+		}
+	}
+
+	// Every node computes the same ranking from the same seed
+	seed := consensus.ProposerSeed([]byte("beacon"), 42)
+	first := consensus.RankProposers(validators, seed)
+	reversed := []consensus.Validator{validators[3], validators[2], validators[1], validators[0]}
+	second := consensus.RankProposers(reversed, seed)
+	for i := range first {
+		if !bytes.Equal(first[i], second[i]) {
+			t.Fatalf("Ranking depends on validator order")
+		}
+	}
+}
+
+// testValidator holds the keys of a test validator
+type testValidator struct {
+	info    consensus.Validator
+	signKey ed25519.PrivateKey
+	vrfKey  *ecdsa.PrivateKey
+}
+
+// newTestValidators creates equally weighted validators with signing and VRF keys
+func newTestValidators(t *testing.T, n int) []testValidator {
+	vals := make([]testValidator, n)
+	for i := range vals {
+		pub, priv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatalf("Key generation failed: %v", err)
+		}
+		vrfKey, err := security.GenerateVRFKey()
+		if err != nil {
+			t.Fatalf("VRF key generation failed: %v", err)
+		}
+		vals[i] = testValidator{
+			info: consensus.Validator{
+				Address:   consensus.ValidatorAddress(pub),
+				PubKey:    pub,
+				Power:     10,
+				VRFPubKey: security.VRFPublicKeyBytes(&vrfKey.PublicKey),
+			},
+			signKey: priv,
+			vrfKey:  vrfKey,
+		}
+	}
+	return vals
+}
+
+// newTestNode creates a started consensus node with the given validator set,
+// running as self if set
+func newTestNode(t *testing.T, vals []testValidator, self *testValidator) *consensus.Consensus {
+	c := configureTestNode(t, vals, self)
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start consensus: %v", err)
+	}
+	return c
+}
+
+// configureTestNode creates a consensus node with short PoH entries and timeouts
+func configureTestNode(t *testing.T, vals []testValidator, self *testValidator) *consensus.Consensus {
+	c := consensus.New()
+	c.HashesPerTick = 10
+	c.TicksPerEntry = 4
+	c.TimeoutPropose = 200 * time.Millisecond
+	c.TimeoutPrevote = 50 * time.Millisecond
+	c.TimeoutPrecommit = 50 * time.Millisecond
+	c.TimeoutDelta = 50 * time.Millisecond
+	c.TimeoutCommit = 10 * time.Millisecond
+	for _, v := range vals {
+		c.ValidatorSet = append(c.ValidatorSet, v.info)
+	}
+	if self != nil {
+		c.SetValidatorKeys(self.info.Address, self.signKey, self.vrfKey)
+	}
+	t.Cleanup(func() { c.Stop() })
+	return c
+}
+
+// signTestCommit builds a commit with a precommit from every validator
+func signTestCommit(vals []testValidator, block *types.Block) *types.Commit {
+	commit := &types.Commit{
+		Height:  block.Header.Height,
+		BlockID: types.BlockID{Hash: block.Header.Hash()},
+	}
+	for _, v := range vals {
+		vote := &types.Vote{
+			Type:             tmproto.PrecommitType,
+			Height:           commit.Height,
+			BlockID:          commit.BlockID,
+			Timestamp:        time.Now(),
+			ValidatorAddress: v.info.Address,
+		}
+		commit.Signatures = append(commit.Signatures, types.CommitSig{
+			BlockIDFlag:      types.BlockIDFlagCommit,
+			ValidatorAddress: v.info.Address,
+			Timestamp:        vote.Timestamp,
+			Signature:        ed25519.Sign(v.signKey, consensus.VoteSignBytes(consensus.DefaultChainID, vote)),
+		})
+	}
+	return commit
+}
+
+// TestCommitBlockRequiresElectedProposer tests that only the elected, signing proposer can commit
+func TestCommitBlockRequiresElectedProposer(t *testing.T) {
+	vals := newTestValidators(t, 4)
+	verifier := newTestNode(t, vals, nil)
+
+	elected, err := verifier.ProposerFor(1, 0)
+	if err != nil {
+		t.Fatalf("Election failed: %v", err)
+	}
+	var winner, loser testValidator
+	for _, v := range vals {
+		if bytes.Equal(v.info.Address, elected) {
+			winner = v
+		} else {
+			loser = v
+		}
+	}
+
+	// A node without keys refuses to produce blocks
+	if _, err := verifier.ProduceBlock(1, nil); err == nil {
+		t.Errorf("Block produced without validator keys")
+	}
+
+	// A validator that lost the election cannot produce
+	node := newTestNode(t, vals, &loser)
+	if _, err := node.ProduceBlock(1, nil); !errors.Is(err, consensus.ErrNotProposer) {
+		t.Errorf("Losing validator produced a block: %v", err)
+	}
+
+	// A loser that only knows itself elects itself, but peers reject its block
+	first := make(chan *types.Block, 1)
+	alone := configureTestNode(t, []testValidator{loser}, &loser)
+	alone.RegisterCommitListener(func(block *types.Block, _ *types.Commit) {
+		select {
+		case first <- block:
+		default:
+		}
+	})
+	if err := alone.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start consensus: %v", err)
+	}
+	forged := <-first
+	if err := verifier.CommitBlock(forged, signTestCommit(vals, forged)); err == nil {
+		t.Errorf("Block from a losing proposer committed")
+	}
+
+	// The winner's block commits on every node
+	producer := newTestNode(t, vals, &winner)
+	block, err := producer.ProduceBlock(1, shardTestTxs(t, producer, winner, 1, 3))
+	if err != nil {
+		t.Fatalf("Block production failed: %v", err)
+	}
+	commit := signTestCommit(vals, block)
+
+	// Stripping the VRF proof must invalidate the block
+	var proof consensus.PoHProof
+	if err := json.Unmarshal(block.Data.Extensions[0].Bytes, &proof); err != nil {
+		t.Fatalf("Failed to decode PoH proof: %v", err)
+	}
+	good := block.Data.Extensions[0].Bytes
+	proof.VRFProof = nil
+	block.Data.Extensions[0].Bytes, _ = json.Marshal(proof)
+	if err := verifier.CommitBlock(block, commit); err == nil {
+		t.Errorf("Block without VRF proof committed")
+	}
+	block.Data.Extensions[0].Bytes = good
+
+	// A commit without +2/3 of the power is not enough
+	weak := signTestCommit(vals, block)
+	weak.Signatures[0] = types.CommitSig{BlockIDFlag: types.BlockIDFlagAbsent}
+	weak.Signatures[1] = types.CommitSig{BlockIDFlag: types.BlockIDFlagAbsent}
+	if err := verifier.CommitBlock(block, weak); err == nil {
+		t.Errorf("Block committed without +2/3 precommits")
+	}
+
+	if err := verifier.CommitBlock(block, commit); err != nil {
+		t.Fatalf("Block from elected proposer rejected: %v", err)
+	}
+	if err := node.CommitBlock(block, commit); err != nil {
+		t.Fatalf("Block from elected proposer rejected: %v", err)
+	}
+
+	// The revealed VRF output seeds the next height identically on both nodes
+	next1, err1 := verifier.ProposerFor(2, 0)
+	next2, err2 := node.ProposerFor(2, 0)
+	if err1 != nil || err2 != nil || !bytes.Equal(next1, next2) {
+		t.Errorf("Nodes disagree on the next proposer")
+	}
+}
+
+// testCommit is a commit observed by a test node
+type testCommit struct {
+	node   int
+	block  *types.Block
+	commit *types.Commit
+}
+
+// startTestNetwork starts one node per online validator, gossiping in
+// memory. Setup functions run on every node before it starts.
+func startTestNetwork(t *testing.T, vals []testValidator, online []int, setup ...func(*consensus.Consensus)) ([]*consensus.Consensus, chan testCommit) {
+	commits := make(chan testCommit, 256)
+	nodes := make([]*consensus.Consensus, len(online))
+
+	for i, idx := range online {
+		nodes[i] = configureTestNode(t, vals, &vals[idx])
+		for _, fn := range setup {
+			fn(nodes[i])
+		}
+	}
+	for i, node := range nodes {
+		i := i
+		node.RegisterCommitListener(func(block *types.Block, commit *types.Commit) {
+			commits <- testCommit{i, block, commit}
+		})
+		node.SetBroadcaster(func(msg consensus.ConsensusMessage) {
+			for j, peer := range nodes {
+				if j != i {
+					go peer.HandleMessage(msg)
+				}
+			}
+		})
+	}
+	for _, node := range nodes {
+		if err := node.Start(context.Background()); err != nil {
+			t.Fatalf("Failed to start consensus: %v", err)
+		}
+	}
+
+	return nodes, commits
+}
+
+// waitForCommits waits until every node committed the given number of heights
+// and checks that all nodes committed the same block with a valid certificate
+func waitForCommits(t *testing.T, vals []testValidator, commits chan testCommit, nodes int, heights int64) map[int64]*types.Commit {
+	validators := make([]consensus.Validator, len(vals))
+	for i, v := range vals {
+		validators[i] = v.info
+	}
+
+	decided := make(map[int64]*types.Commit)
+	seen := make(map[int64]int)
+	timeout := time.After(20 * time.Second)
+	for {
+		select {
+		case c := <-commits:
+			height := c.block.Header.Height
+			if err := consensus.VerifyCommit(consensus.DefaultChainID, validators, c.commit); err != nil {
+				t.Fatalf("Invalid commit at height %d: %v", height, err)
+			}
+			if prev, ok := decided[height]; ok && !bytes.Equal(prev.BlockID.Hash, c.commit.BlockID.Hash) {
+				t.Fatalf("Conflicting blocks committed at height %d", height)
+			}
+			decided[height] = c.commit
+			seen[height]++
+
+			done := true
+			for h := int64(1); h <= heights; h++ {
+				if seen[h] < nodes {
+					done = false
+				}
+			}
+			if done {
+				return decided
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %d heights (seen: %v)", heights, seen)
+		}
+	}
+}
+
+// TestBFTCommitsWithVotes tests that validators reach commits through prevotes and precommits
+func TestBFTCommitsWithVotes(t *testing.T) {
+	vals := newTestValidators(t, 4)
+	_, commits := startTestNetwork(t, vals, []int{0, 1, 2, 3})
+
+	decided := waitForCommits(t, vals, commits, 4, 3)
+	for height, commit := range decided {
+		if len(commit.Signatures) != len(vals) {
+			t.Errorf("Commit at height %d has %d signatures", height, len(commit.Signatures))
+		}
+	}
+}
+
+// TestBFTRoundChangeOnTimeout tests that a silent proposer is skipped after a timeout
+func TestBFTRoundChangeOnTimeout(t *testing.T) {
+	vals := newTestValidators(t, 4)
+
+	// Take the first proposer of height 1 offline
+	probe := newTestNode(t, vals, nil)
+	elected, err := probe.ProposerFor(1, 0)
+	if err != nil {
+		t.Fatalf("Election failed: %v", err)
+	}
+	online := make([]int, 0, 3)
+	for i, v := range vals {
+		if !bytes.Equal(v.info.Address, elected) {
+			online = append(online, i)
+		}
+	}
+
+	_, commits := startTestNetwork(t, vals, online)
+	decided := waitForCommits(t, vals, commits, len(online), 1)
+	if decided[1].Round == 0 {
+		t.Errorf("Height 1 committed in round 0 without its proposer")
+	}
+}
+
+// TestDoubleSignEvidenceJailsValidator tests that conflicting votes become
+// evidence that is committed in a block and slashes the signer everywhere
+func TestDoubleSignEvidenceJailsValidator(t *testing.T) {
+	vals := newTestValidators(t, 4)
+	byzantine := vals[3]
+
+	// A keyless node stays at height 1 while it sees no proposals
+	gossiped := make(chan consensus.ConsensusMessage, 16)
+	watcher := configureTestNode(t, vals, nil)
+	watcher.SetBroadcaster(func(msg consensus.ConsensusMessage) { gossiped <- msg })
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start consensus: %v", err)
+	}
+
+	prevote := func(hash []byte) *types.Vote {
+		vote := &types.Vote{
+			Type:             tmproto.PrevoteType,
+			Height:           1,
+			BlockID:          types.BlockID{Hash: hash},
+			Timestamp:        time.Now(),
+			ValidatorAddress: byzantine.info.Address,
+			ValidatorIndex:   3,
+		}
+		vote.Signature = ed25519.Sign(byzantine.signKey, consensus.VoteSignBytes(consensus.DefaultChainID, vote))
+		return vote
+	}
+	if err := watcher.HandleMessage(consensus.ConsensusMessage{Vote: prevote(bytes.Repeat([]byte{1}, 32))}); err != nil {
+		t.Fatalf("First prevote rejected: %v", err)
+	}
+	if err := watcher.HandleMessage(consensus.ConsensusMessage{Vote: prevote(bytes.Repeat([]byte{2}, 32))}); err == nil {
+		t.Fatalf("Conflicting prevote accepted")
+	}
+	if len(watcher.GetEvidence()) != 1 {
+		t.Fatalf("Expected 1 pending evidence, got %d", len(watcher.GetEvidence()))
+	}
+
+	var evidence *consensus.ConsensusMessage
+	for evidence == nil {
+		select {
+		case msg := <-gossiped:
+			if msg.Evidence != nil {
+				evidence = &msg
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Evidence was not gossiped")
+		}
+	}
+
+	// Tampered evidence is rejected
+	forged := *evidence.Evidence.DuplicateVote
+	forgedVote := *forged.VoteB
+	forgedVote.Round = 1
+	forged.VoteB = &forgedVote
+	if err := watcher.HandleMessage(consensus.ConsensusMessage{Evidence: &consensus.Evidence{DuplicateVote: &forged}}); err == nil {
+		t.Errorf("Evidence with votes from different rounds accepted")
+	}
+
+	// The honest validators commit the evidence and jail the double signer
+	nodes, commits := startTestNetwork(t, vals, []int{0, 1, 2})
+	if err := nodes[0].HandleMessage(*evidence); err != nil {
+		t.Fatalf("Evidence rejected: %v", err)
+	}
+
+	jailed := 0
+	timeout := time.After(20 * time.Second)
+	for jailed < len(nodes) {
+		select {
+		case c := <-commits:
+			if len(c.block.Header.EvidenceHash) == 0 {
+				continue
+			}
+			val, err := nodes[c.node].GetValidator(byzantine.info.Address)
+			if err != nil {
+				t.Fatalf("Validator lookup failed: %v", err)
+			}
+			if !val.Jailed || !val.Tombstoned || consensus.VotingPower(val) != 0 {
+				t.Fatalf("Double signer not jailed on node %d", c.node)
+			}
+			if len(val.SlashingEvents) != 1 || val.SlashingEvents[0].Reason != string(consensus.InfractionDoubleSign) {
+				t.Errorf("Unexpected slashing events on node %d: %+v", c.node, val.SlashingEvents)
+			}
+			if err := nodes[c.node].Unjail(byzantine.info.Address); err == nil {
+				t.Errorf("Tombstoned validator unjailed on node %d", c.node)
+			}
+			jailed++
+		case <-timeout:
+			t.Fatalf("Evidence was not committed (jailed on %d nodes)", jailed)
+		}
+	}
+}
+
+// TestEpochValidatorSetChanges tests that joins and stake changes wait for the epoch boundary
+func TestEpochValidatorSetChanges(t *testing.T) {
+	vals := newTestValidators(t, 2)
+	genesis, newcomer := vals[0], vals[1]
+	genesis.info.Stake = consensus.MinStake
+	newcomer.info.Stake = consensus.MinStake
+
+	node := configureTestNode(t, nil, &genesis)
+	node.EpochLength = 3
+	node.TimeoutCommit = 300 * time.Millisecond
+	if err := node.AddValidator(genesis.info); err != nil {
+		t.Fatalf("Genesis validator rejected: %v", err)
+	}
+
+	// Delegate, then unbond half of the delegation
+	delegator := []byte("delegator")
+	delegation, err := node.Delegate(delegator, genesis.info.Address, consensus.MinStake)
+	if err != nil {
+		t.Fatalf("Delegation failed: %v", err)
+	}
+	if delegation.Shares.Cmp(consensus.MinStake) != 0 {
+		t.Errorf("Expected %s shares, got %s", consensus.MinStake, delegation.Shares)
+	}
+	half := new(big.Int).Rsh(delegation.Shares, 1)
+	entry, err := node.Undelegate(delegator, genesis.info.Address, half)
+	if err != nil {
+		t.Fatalf("Undelegation failed: %v", err)
+	}
+	if entry.Amount.Cmp(half) != 0 || len(node.GetUnbonding(delegator)) != 1 {
+		t.Errorf("Unexpected unbonding entry: %+v", entry)
+	}
+	if _, err := node.Undelegate(delegator, genesis.info.Address, delegation.Shares); err == nil {
+		t.Errorf("Undelegated more shares than delegated")
+	}
+
+	heights := make(chan int64, 16)
+	node.RegisterCommitListener(func(block *types.Block, _ *types.Commit) {
+		heights <- block.Header.Height
+	})
+	if err := node.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start consensus: %v", err)
+	}
+	if err := node.AddValidator(newcomer.info); err != nil {
+		t.Fatalf("Validator rejected: %v", err)
+	}
+
+	power := consensus.StakeToPower(consensus.MinStake)
+	for height := range heights {
+		val, err := node.GetValidator(genesis.info.Address)
+		if err != nil {
+			t.Fatalf("Genesis validator missing: %v", err)
+		}
+		_, joinErr := node.GetValidator(newcomer.info.Address)
+
+		if height < 3 {
+			if joinErr == nil {
+				t.Fatalf("Validator joined at height %d before the epoch boundary", height)
+			}
+			if val.Power != power {
+				t.Fatalf("Power changed at height %d before the epoch boundary", height)
+			}
+			continue
+		}
+
+		if joinErr != nil {
+			t.Errorf("Validator did not join at the epoch boundary: %v", joinErr)
+		}
+		if val.Power != power*3/2 {
+			t.Errorf("Expected power %d after the epoch boundary, got %d", power*3/2, val.Power)
+		}
+		break
+	}
+}
+
+// TestStakeBeyondUint64 tests that stakes above 2^64 bond, delegate and
+// unbond exactly, and that voting power stays bounded
+func TestStakeBeyondUint64(t *testing.T) {
+	vals := newTestValidators(t, 2)
+	node := configureTestNode(t, nil, nil)
+	minStake := new(big.Int).Set(consensus.MinStake)
+
+	val := vals[0].info
+	val.Stake = consensus.MinStake
+	if err := node.AddValidator(val); err != nil {
+		t.Fatalf("Validator with the minimum stake rejected: %v", err)
+	}
+	below := vals[1].info
+	below.Stake = new(big.Int).Sub(consensus.MinStake, big.NewInt(1))
+	if err := node.AddValidator(below); err == nil {
+		t.Errorf("Validator below the minimum stake accepted")
+	}
+
+	// 10^40 base units is far beyond uint64
+	amount, _ := new(big.Int).SetString("10000000000000000000000000000000000000000", 10)
+	delegator := []byte("delegator")
+	delegation, err := node.Delegate(delegator, val.Address, amount)
+	if err != nil {
+		t.Fatalf("Delegation failed: %v", err)
+	}
+	if delegation.Shares.Cmp(amount) != 0 {
+		t.Errorf("Expected %s shares, got %s", amount, delegation.Shares)
+	}
+	bonded, err := node.GetValidator(val.Address)
+	if err != nil {
+		t.Fatalf("Validator missing: %v", err)
+	}
+	if want := new(big.Int).Add(minStake, amount); bonded.Stake.Cmp(want) != 0 || bonded.Power != consensus.StakeToPower(minStake) {
+		t.Errorf("Unexpected stake %s and power %d after delegating", bonded.Stake, bonded.Power)
+	}
+	if consensus.MinStake.Cmp(minStake) != 0 {
+		t.Fatalf("MinStake modified to %s", consensus.MinStake)
+	}
+
+	entry, err := node.Undelegate(delegator, val.Address, delegation.Shares)
+	if err != nil {
+		t.Fatalf("Undelegation failed: %v", err)
+	}
+	if entry.Amount.Cmp(amount) != 0 {
+		t.Errorf("Unbonding %s, want %s", entry.Amount, amount)
+	}
+
+	// Power is whole ZEN, capped so any realistic set sums without overflow
+	if power := consensus.StakeToPower(minStake); power != 1000 {
+		t.Errorf("MinStake has power %d, want 1000", power)
+	}
+	if power := consensus.StakeToPower(amount); power != consensus.MaxValidatorPower {
+		t.Errorf("Huge stake has power %d, want the cap", power)
+	}
+	capped := make([]consensus.Validator, 1024)
+	for i := range capped {
+		capped[i].Power = math.MaxInt64
+	}
+	if total := consensus.TotalVotingPower(capped); total != 1024*consensus.MaxValidatorPower {
+		t.Errorf("Total power %d of capped validators", total)
+	}
+}
+
+// TestCommitteeShuffling tests that committees are seeded, stake-balanced and reuse small sets
+func TestCommitteeShuffling(t *testing.T) {
+	makeSet := func(n int) []consensus.Validator {
+		set := make([]consensus.Validator, n)
+		for i := range set {
+			set[i] = consensus.Validator{
+				Address: []byte{byte(i >> 8), byte(i)},
+				Power:   int64(i%10 + 1),
+			}
+		}
+		return set
+	}
+	seed := consensus.CommitteeSeed([]byte("beacon"), 1)
+
+	// A small set is reused across shards with distinct members per committee
+	small := makeSet(10)
+	committees := consensus.ShuffleCommittees(small, seed, 1)
+	if len(committees) != consensus.NumShards {
+		t.Fatalf("Expected %d committees, got %d", consensus.NumShards, len(committees))
+	}
+	minPower, maxPower := int64(math.MaxInt64), int64(0)
+	for _, committee := range committees {
+		if len(committee.Validators) != consensus.MinCommitteeSize {
+			t.Errorf("Committee %d has %d validators", committee.ID, len(committee.Validators))
+		}
+		members := make(map[string]bool)
+		for _, val := range committee.Validators {
+			if members[string(val.Address)] {
+				t.Errorf("Committee %d repeats validator %x", committee.ID, val.Address)
+			}
+			members[string(val.Address)] = true
+		}
+		if committee.Epoch != 1 || !bytes.Equal(committee.Seed, seed) {
+			t.Errorf("Committee %d does not record its seed and epoch", committee.ID)
+		}
+		if committee.TotalPower < minPower {
+			minPower = committee.TotalPower
+		}
+		if committee.TotalPower > maxPower {
+			maxPower = committee.TotalPower
+		}
+	}
+	if maxPower-minPower > 10 {
+		t.Errorf("Committees unbalanced: power from %d to %d", minPower, maxPower)
+	}
+
+	// Peers recompute the same assignment regardless of set order
+	reversed := make([]consensus.Validator, len(small))
+	for i, val := range small {
+		reversed[len(small)-1-i] = val
+	}
+	again := consensus.ShuffleCommittees(reversed, seed, 1)
+	other := consensus.ShuffleCommittees(small, consensus.CommitteeSeed([]byte("beacon"), 2), 2)
+	differs := false
+	for i := range committees {
+		for j, val := range committees[i].Validators {
+			if !bytes.Equal(val.Address, again[i].Validators[j].Address) {
+				t.Fatalf("Committee %d differs between identical shuffles", i)
+			}
+			if !bytes.Equal(val.Address, other[i].Validators[j].Address) {
+				differs = true
+			}
+		}
+	}
+	if !differs {
+		t.Errorf("Committees did not change with the epoch seed")
+	}
+
+	// A large set seats every validator exactly once
+	large := makeSet(300)
+	seats := make(map[string]int)
+	for _, committee := range consensus.ShuffleCommittees(large, seed, 1) {
+		if len(committee.Validators) < consensus.MinCommitteeSize {
+			t.Errorf("Committee %d below minimum size: %d", committee.ID, len(committee.Validators))
+		}
+		for _, val := range committee.Validators {
+			seats[string(val.Address)]++
+		}
+	}
+	for _, val := range large {
+		if seats[string(val.Address)] != 1 {
+			t.Fatalf("Validator %x holds %d seats", val.Address, seats[string(val.Address)])
+		}
+	}
+}
+
+// TestRestartRecoversState tests that a restarted node resumes from its stored height
+func TestRestartRecoversState(t *testing.T) {
+	vals := newTestValidators(t, 1)
+	dir := t.TempDir()
+
+	node := configureTestNode(t, vals, &vals[0])
+	if err := node.OpenStorage(dir); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	heights := make(chan *types.Block, 16)
+	node.RegisterCommitListener(func(block *types.Block, _ *types.Commit) {
+		select {
+		case heights <- block:
+		default:
+		}
+	})
+	if err := node.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start consensus: %v", err)
+	}
+	blocks := make(map[int64]*types.Block)
+	for len(blocks) < 3 {
+		block := <-heights
+		blocks[block.Header.Height] = block
+	}
+	node.Stop()
+
+	restarted := configureTestNode(t, vals, &vals[0])
+	if err := restarted.OpenStorage(dir); err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	height := restarted.GetStatus()["height"].(int64)
+	if height < 3 {
+		t.Fatalf("Restarted at height %d, expected at least 3", height)
+	}
+	stored, commit, err := restarted.LoadBlock(2)
+	if err != nil {
+		t.Fatalf("Failed to load block: %v", err)
+	}
+	if !bytes.Equal(stored.Header.Hash(), blocks[2].Header.Hash()) || commit.Height != 2 {
+		t.Errorf("Stored block 2 does not match the committed block")
+	}
+
+	next := make(chan int64, 1)
+	restarted.RegisterCommitListener(func(block *types.Block, _ *types.Commit) {
+		select {
+		case next <- block.Header.Height:
+		default:
+		}
+	})
+	if err := restarted.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to restart consensus: %v", err)
+	}
+	select {
+	case h := <-next:
+		if h != height+1 {
+			t.Errorf("Restarted node committed height %d, expected %d", h, height+1)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Restarted node did not commit")
+	}
+}
+
+// TestWALPreventsDoubleSign tests that a restarted validator re-sends its
+// earlier vote instead of signing a new one
+func TestWALPreventsDoubleSign(t *testing.T) {
+	vals := newTestValidators(t, 4)
+	probe := newTestNode(t, vals, nil)
+	elected, err := probe.ProposerFor(1, 0)
+	if err != nil {
+		t.Fatalf("Election failed: %v", err)
+	}
+	self := &vals[0]
+	if bytes.Equal(self.info.Address, elected) {
+		self = &vals[1]
+	}
+
+	// Alone, the validator prevotes nil after the propose timeout and stalls
+	dir := t.TempDir()
+	firstVote := func(node *consensus.Consensus) *types.Vote {
+		votes := make(chan *types.Vote, 16)
+		node.SetBroadcaster(func(msg consensus.ConsensusMessage) {
+			if msg.Vote != nil {
+				votes <- msg.Vote
+			}
+		})
+		if err := node.OpenStorage(dir); err != nil {
+			t.Fatalf("Failed to open storage: %v", err)
+		}
+		if err := node.Start(context.Background()); err != nil {
+			t.Fatalf("Failed to start consensus: %v", err)
+		}
+		select {
+		case vote := <-votes:
+			return vote
+		case <-time.After(5 * time.Second):
+			t.Fatalf("No vote cast")
+		}
+		return nil
+	}
+
+	node := configureTestNode(t, vals, self)
+	before := firstVote(node)
+	node.Stop()
+
+	after := firstVote(configureTestNode(t, vals, self))
+	if after.Height != before.Height || after.Round != before.Round || after.Type != before.Type {
+		t.Fatalf("Restarted node voted at %d/%d/%d, expected %d/%d/%d",
+			after.Height, after.Round, after.Type, before.Height, before.Round, before.Type)
+	}
+	if !bytes.Equal(after.Signature, before.Signature) {
+		t.Errorf("Restarted node signed a new vote for the same round")
+	}
+}
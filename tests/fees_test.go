@@ -0,0 +1,676 @@
+package tests
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/zennetwork/zennetwork/x/consensus"
+	"github.com/zennetwork/zennetwork/x/fees"
+	"github.com/zennetwork/zennetwork/x/tokenomics"
+)
+
+// newTestFees starts a fee system with a base fee of 1000 wei, a 20% burn
+// and room for large fees
+func newTestFees(t *testing.T, model fees.FeeModel) *fees.Fees {
+	f := fees.NewWithConfig(fees.FeeConfig{BaseFee: 1000, BurnPercent: 20, MaxTip: 100, MaxFee: 1 << 60})
+	f.SetFeeModel(model)
+	if err := f.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start fees: %v", err)
+	}
+	t.Cleanup(func() { f.Stop() })
+	return f
+}
+
+// testBlockGasLimit is the gas limit of settled test blocks
+const testBlockGasLimit = 100000000
+
+// settleTestBlock settles a block of transactions to a test proposer
+func settleTestBlock(t *testing.T, f *fees.Fees, height int64, txs ...*fees.Transaction) {
+	if _, err := f.SettleBlock(height, f.BaseFee(), testBlockGasLimit, common.BytesToAddress([]byte("proposer")), nil, txs); err != nil {
+		t.Fatalf("SettleBlock failed at height %d: %v", height, err)
+	}
+}
+
+// TestEIP1559BaseFee tests the base fee follows parent gas usage around the
+// target, bounded by the change denominator and floored at the configured
+// base fee, and that CalculateFee charges it under the EIP-1559 model
+func TestEIP1559BaseFee(t *testing.T) {
+	f := newTestFees(t, fees.Solidity)
+	parent := fees.ParentBlock{Height: 1, GasLimit: 1000000, BaseFee: 8000}
+
+	cases := []struct {
+		used uint64
+		want uint64
+	}{
+		{500000, 8000},  // At target
+		{1000000, 9000}, // Full block: +1/8
+		{750000, 8500},  // Halfway above target: +1/16
+		{0, 7000},       // Empty block: -1/8
+	}
+	for _, tc := range cases {
+		parent.GasUsed = tc.used
+		if got := f.NextBaseFee(parent); got != tc.want {
+			t.Errorf("Gas used %d: base fee %d, want %d", tc.used, got, tc.want)
+		}
+	}
+
+	// Empty blocks never push the fee under the configured base fee
+	if got := f.NextBaseFee(fees.ParentBlock{GasLimit: 1000000, BaseFee: 1050}); got != 1000 {
+		t.Errorf("Base fee fell to %d, want the 1000 floor", got)
+	}
+	if got := f.NextBaseFee(fees.ParentBlock{GasLimit: 1000000, GasUsed: 1000000}); got != 1000 {
+		t.Errorf("Genesis parent gave %d, want the configured base fee", got)
+	}
+
+	// Congestion raises the fee CalculateFee charges
+	base := uint64(1000)
+	for height := int64(1); height <= 3; height++ {
+		next, err := f.UpdateBaseFee(fees.ParentBlock{Height: height, GasLimit: 1000000, GasUsed: 1000000, BaseFee: base})
+		if err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if next <= base {
+			t.Fatalf("Full block %d left the base fee at %d", height, next)
+		}
+		base = next
+	}
+	fee, err := f.CalculateFee(21000, 10, "transfer")
+	if err != nil {
+		t.Fatalf("CalculateFee failed: %v", err)
+	}
+	if fee.BaseFee != base || f.BaseFee() != base {
+		t.Errorf("Charged base fee %d, want %d", fee.BaseFee, base)
+	}
+	if _, err := f.UpdateBaseFee(fees.ParentBlock{GasLimit: 10, GasUsed: 11, BaseFee: base}); err == nil {
+		t.Errorf("Block over its gas limit accepted")
+	}
+
+	// A larger elasticity lowers the target and a larger denominator slows
+	// the change
+	tuned := fees.NewWithConfig(fees.FeeConfig{BaseFee: 1000, MaxFee: 1 << 60, ElasticityMultiplier: 4, BaseFeeChangeDenominator: 16})
+	if got := tuned.NextBaseFee(fees.ParentBlock{GasLimit: 1000000, GasUsed: 500000, BaseFee: 16000}); got != 17000 {
+		t.Errorf("Tuned base fee %d, want 17000", got)
+	}
+
+	// Other models keep the configured base fee
+	static := newTestFees(t, fees.Priority)
+	static.UpdateBaseFee(fees.ParentBlock{GasLimit: 1000000, GasUsed: 1000000, BaseFee: 5000})
+	if fee, err := static.CalculateFee(21000, 0, "transfer"); err != nil || fee.BaseFee != 1000 {
+		t.Errorf("Priority model charged %+v, %v", fee, err)
+	}
+}
+
+// TestGasProportionalFees tests fees scale with gas at the base fee plus
+// tip, unused gas is refunded and opcode class surcharges are opt-in
+func TestGasProportionalFees(t *testing.T) {
+	f := newTestFees(t, fees.Priority)
+
+	transfer, err := f.CalculateFee(fees.TransferGas, 10, "transfer")
+	if err != nil {
+		t.Fatalf("CalculateFee failed: %v", err)
+	}
+	call, err := f.CalculateFee(5000000, 10, "contract_call")
+	if err != nil {
+		t.Fatalf("CalculateFee failed: %v", err)
+	}
+	if transfer.Total != 21000*1010 || call.Total != 5000000*1010 {
+		t.Errorf("Transfer costs %d, 5M gas call %d", transfer.Total, call.Total)
+	}
+
+	// 20% of the base fee burns; the tip goes to the validator
+	if transfer.Burned != 21000*200 || transfer.Validator != transfer.Total-transfer.Burned {
+		t.Errorf("Burned %d, validator %d", transfer.Burned, transfer.Validator)
+	}
+
+	// The call used 1.2M of its 5M gas
+	settled, err := f.SettleFee(1, call, 1200000)
+	if err != nil {
+		t.Fatalf("SettleFee failed: %v", err)
+	}
+	if settled.Total != 1200000*1010 || settled.Refund != 3800000*1010 || settled.Burned != 1200000*200 {
+		t.Errorf("Settled %+v", settled)
+	}
+	if _, err := f.SettleFee(1, call, 5000001); err == nil {
+		t.Errorf("Settled more gas than the limit")
+	}
+
+	// Surcharges add a percent of the base fee for their class only
+	config := f.GetConfig()
+	config.Surcharges = fees.DefaultSurcharges()
+	if err := f.SetFeeConfig(config); err != nil {
+		t.Fatalf("SetFeeConfig failed: %v", err)
+	}
+	deploy, err := f.CalculateFee(100000, 0, "contract_deploy")
+	if err != nil {
+		t.Fatalf("CalculateFee failed: %v", err)
+	}
+	if deploy.Surcharge != 2000 || deploy.Total != 100000*3000 || deploy.Burned != 100000*600 {
+		t.Errorf("Deploy %+v", deploy)
+	}
+	if plain, _ := f.CalculateFee(100000, 0, "transfer"); plain.Total != 100000*1000 {
+		t.Errorf("Transfer surcharged: %+v", plain)
+	}
+
+	// Admission checks a prepaid fee covers its class at current prices
+	if err := f.CheckFee(deploy, "contract_deploy"); err != nil {
+		t.Errorf("Deploy fee rejected: %v", err)
+	}
+	if plain, _ := f.CalculateFee(100000, 0, "transfer"); f.CheckFee(plain, "contract_deploy") == nil {
+		t.Errorf("Deploy without its surcharge admitted")
+	}
+
+	if _, err := f.CalculateFee(1<<60, 100, "transfer"); err == nil {
+		t.Errorf("Overflowing fee calculated")
+	}
+}
+
+// TestMultiDimensionalFees tests every resource is priced at its own base
+// fee that follows its own target, and the breakdown reaches callers and
+// fee stats
+func TestMultiDimensionalFees(t *testing.T) {
+	f := newTestFees(t, fees.MultiDimensional)
+	config := f.GetConfig()
+	config.Dimensions = [fees.NumResources]fees.DimensionParams{
+		fees.ResourceGas:           {Target: 1000000},
+		fees.ResourceStateBytes:    {BaseFee: 50, Target: 100},
+		fees.ResourceCalldataBytes: {BaseFee: 10, Target: 1000},
+		fees.ResourceCrossShard:    {BaseFee: 5000, Target: 10},
+	}
+	if err := f.SetFeeConfig(config); err != nil {
+		t.Fatalf("SetFeeConfig failed: %v", err)
+	}
+
+	usage := fees.Resources{21000, 64, 200, 2}
+	fee, err := f.CalculateResourceFee(usage, 10, "transfer")
+	if err != nil {
+		t.Fatalf("CalculateResourceFee failed: %v", err)
+	}
+	want := []uint64{21000 * 1000, 64 * 50, 200 * 10, 2 * 5000}
+	if len(fee.Dimensions) != len(want) {
+		t.Fatalf("Breakdown has %d resources, want %d", len(fee.Dimensions), len(want))
+	}
+	for i, dim := range fee.Dimensions {
+		if dim.Resource != fees.Resource(i) || dim.Units != usage[i] || dim.Amount != want[i] {
+			t.Errorf("Resource %s: %+v", fees.Resource(i), dim)
+		}
+	}
+	if fee.Total != 21000*1010+15200 || fee.Burned != (21000000+15200)/5 {
+		t.Errorf("Total %d, burned %d", fee.Total, fee.Burned)
+	}
+
+	// Refunds only return unused gas
+	settled, err := f.SettleFee(1, fee, 10000)
+	if err != nil {
+		t.Fatalf("SettleFee failed: %v", err)
+	}
+	if settled.Total != 10000*1010+15200 || settled.Refund != 11000*1010 || settled.Dimensions[0].Amount != 10000*1000 {
+		t.Errorf("Settled %+v", settled)
+	}
+
+	settleTestBlock(t, f, 1, &fees.Transaction{GasLimit: 21000, GasUsed: 10000, Fee: *settled, TxType: "transfer"})
+	stats := f.GetFeeStats()
+	if stats.Dimensions["execution_gas"] != 10000*1000 || stats.Dimensions["state_bytes"] != 3200 ||
+		stats.Dimensions["cross_shard_messages"] != 10000 {
+		t.Errorf("Stats breakdown %v", stats.Dimensions)
+	}
+
+	// Full gas and cross-shard use raise their fees, empty state use keeps
+	// its floor and calldata at target holds
+	next, err := f.UpdateDimensionFees(fees.Resources{2000000, 0, 1000, 20})
+	if err != nil {
+		t.Fatalf("UpdateDimensionFees failed: %v", err)
+	}
+	if next != (fees.Resources{1125, 50, 10, 5625}) || f.DimensionBaseFees() != next {
+		t.Errorf("Next fees %v", next)
+	}
+	if fee, err := f.CalculateFee(21000, 0, "transfer"); err != nil || fee.BaseFee != 1125 {
+		t.Errorf("Gas priced at %+v, %v", fee, err)
+	}
+	if _, err := f.UpdateDimensionFees(fees.Resources{0, 201, 0, 0}); err == nil {
+		t.Errorf("Block over its state growth limit accepted")
+	}
+
+	if _, err := newTestFees(t, fees.Priority).CalculateResourceFee(usage, 0, "transfer"); err == nil {
+		t.Errorf("Resource fee priced outside the multi-dimensional model")
+	}
+}
+
+// TestLocalFeeMarkets tests a hot account raises the priority fee floor of
+// its own shard and itself only, estimates include the floor of touched
+// accounts and the floors clear once congestion ends
+func TestLocalFeeMarkets(t *testing.T) {
+	f := newTestFees(t, fees.Priority)
+	config := f.GetConfig()
+	config.LocalMarkets = fees.LocalFeeParams{
+		Shards:              64,
+		TrackHotAccounts:    true,
+		ShardGasTarget:      1000000,
+		HotAccountGasTarget: 500000,
+		FloorStep:           100,
+	}
+	if err := f.SetFeeConfig(config); err != nil {
+		t.Fatalf("SetFeeConfig failed: %v", err)
+	}
+
+	hot := common.BytesToAddress([]byte("hot defi contract"))
+	var neighbour, cold common.Address
+	for i := 0; neighbour == (common.Address{}) || cold == (common.Address{}); i++ {
+		addr := common.BytesToAddress([]byte{byte(i), byte(i >> 8)})
+		if fees.AccountShard(addr, 64) == fees.AccountShard(hot, 64) {
+			neighbour = addr
+		} else {
+			cold = addr
+		}
+	}
+
+	// Two congested blocks: the floors start at the step, then rise by the
+	// share of the target exceeded over the change denominator
+	for i := 0; i < 2; i++ {
+		f.UpdateLocalFees(map[common.Address]uint64{hot: 2000000})
+	}
+	if f.LocalFeeFloor(hot) != 137 || f.LocalFeeFloor(neighbour) != 112 || f.LocalFeeFloor(cold) != 0 {
+		t.Errorf("Floors hot %d, neighbour %d, cold %d", f.LocalFeeFloor(hot), f.LocalFeeFloor(neighbour), f.LocalFeeFloor(cold))
+	}
+
+	hotFee, err := f.EstimateFee(fees.TransferGas, "transfer", cold, hot)
+	if err != nil {
+		t.Fatalf("EstimateFee failed: %v", err)
+	}
+	coldFee, err := f.EstimateFee(fees.TransferGas, "transfer", cold)
+	if err != nil {
+		t.Fatalf("EstimateFee failed: %v", err)
+	}
+	if hotFee != 21000*1137 || coldFee != 21000*1000 {
+		t.Errorf("Estimates hot %d, cold %d", hotFee, coldFee)
+	}
+	if fee, _ := f.CalculateFee(fees.TransferGas, 0, "transfer"); fee.PriorityFee != 0 {
+		t.Errorf("Fee without touched accounts paid priority %d", fee.PriorityFee)
+	}
+
+	// Quiet blocks decay the floors until they clear
+	for i := 0; i < 3; i++ {
+		f.UpdateLocalFees(nil)
+	}
+	if f.LocalFeeFloor(hot, neighbour) != 0 || len(f.HotAccounts()) != 0 {
+		t.Errorf("Floors left after congestion: %d, hot accounts %v", f.LocalFeeFloor(hot, neighbour), f.HotAccounts())
+	}
+}
+
+// TestFeeSettlement tests a block's fees are credited to its proposer and
+// committee rather than the senders, burns reach tokenomics exactly and
+// disabling the burn pays the whole fee to validators
+func TestFeeSettlement(t *testing.T) {
+	f := newTestFees(t, fees.Priority)
+	config := f.GetConfig()
+	config.CommitteeSharePercent = 30
+	if err := f.SetFeeConfig(config); err != nil {
+		t.Fatalf("SetFeeConfig failed: %v", err)
+	}
+	supply := tokenomics.New()
+	f.SetBurner(supply)
+
+	transfer, err := f.CalculateFee(fees.TransferGas, 10, "transfer")
+	if err != nil {
+		t.Fatalf("CalculateFee failed: %v", err)
+	}
+	call, err := f.CalculateFee(100000, 0, "contract_call")
+	if err != nil {
+		t.Fatalf("CalculateFee failed: %v", err)
+	}
+	if call, err = f.SettleFee(1, call, 50000); err != nil {
+		t.Fatalf("SettleFee failed: %v", err)
+	}
+	sender := common.BytesToAddress([]byte("sender"))
+	proposer := common.BytesToAddress([]byte("proposer"))
+	committee := []common.Address{common.BytesToAddress([]byte("member 1")), common.BytesToAddress([]byte("member 2"))}
+	txs := []*fees.Transaction{{From: sender, Fee: *transfer}, {From: sender, Fee: *call}}
+
+	settlement, err := f.SettleBlock(1, f.BaseFee(), testBlockGasLimit, proposer, committee, txs)
+	if err != nil {
+		t.Fatalf("SettleBlock failed: %v", err)
+	}
+	if settlement.Total.Uint64() != 71210000 || settlement.Burned.Uint64() != 14200000 || settlement.Validators.Uint64() != 57010000 {
+		t.Errorf("Settled total %s, burned %s, validators %s", settlement.Total, settlement.Burned, settlement.Validators)
+	}
+	if supply.GetTotalBurned().Uint64() != 14200000 {
+		t.Errorf("Tokenomics burned %s", supply.GetTotalBurned())
+	}
+	revenue := f.GetRevenueSplit()
+	if revenue[proposer].Uint64() != 39907000 || revenue[committee[0]].Uint64() != 8551500 || revenue[committee[1]].Uint64() != 8551500 || revenue[sender] != nil {
+		t.Errorf("Revenue split %v", revenue)
+	}
+
+	// With burning off fees burn nothing, and a fee computed earlier pays
+	// its burn share to validators
+	f.EnableBurn(false)
+	if fee, _ := f.CalculateFee(fees.TransferGas, 0, "transfer"); fee.Burned != 0 || fee.Validator != fee.Total {
+		t.Errorf("Fee burned %d with burning disabled", fee.Burned)
+	}
+	settlement, err = f.SettleBlock(2, f.BaseFee(), testBlockGasLimit, proposer, nil, txs[:1])
+	if err != nil {
+		t.Fatalf("SettleBlock failed: %v", err)
+	}
+	if settlement.Burned.Sign() != 0 || f.GetRevenueSplit()[proposer].Uint64() != 39907000+21210000 || supply.GetTotalBurned().Uint64() != 14200000 {
+		t.Errorf("Burned %s with burning disabled", settlement.Burned)
+	}
+}
+
+func TestFeeStatistics(t *testing.T) {
+	f := newTestFees(t, fees.Priority)
+	now := time.Now()
+
+	// Ten transfers in the last minute, one older call and one old deploy
+	txs := make([]*fees.Transaction, 0)
+	for i := 1; i <= 10; i++ {
+		txs = append(txs, &fees.Transaction{TxType: "transfer", Timestamp: now.Add(-10 * time.Second).Unix(), Fee: fees.Fee{Total: uint64(i) * 100, Burned: uint64(i) * 10}})
+	}
+	txs = append(txs, &fees.Transaction{TxType: "contract_call", Timestamp: now.Add(-30 * time.Minute).Unix(), Fee: fees.Fee{Total: 5000}})
+	txs = append(txs, &fees.Transaction{TxType: "contract_deploy", Timestamp: now.Add(-2 * time.Hour).Unix(), Fee: fees.Fee{Total: 20000}})
+	settleTestBlock(t, f, 1, txs...)
+
+	// Checking a fee does not count it again
+	if err := f.ProcessTransaction(&fees.Transaction{Fee: fees.Fee{Total: 100, Validator: 100}}); err != nil {
+		t.Errorf("ProcessTransaction failed: %v", err)
+	}
+	stats := f.GetFeeStats()
+	if stats.TotalTx != 12 || stats.TotalFees.Uint64() != 5500+5000+20000 {
+		t.Errorf("Totals %d tx, %d fees", stats.TotalTx, stats.TotalFees)
+	}
+	// Sorted: 100..1000, 5000, 20000; the median averages 600 and 700
+	if stats.MedianFee != 650 || stats.P10 != 200 || stats.P50 != 600 || stats.P90 != 5000 || stats.P99 != 20000 {
+		t.Errorf("Median %d, p10 %d, p50 %d, p90 %d, p99 %d", stats.MedianFee, stats.P10, stats.P50, stats.P90, stats.P99)
+	}
+
+	minute, hour, day := stats.Windows["1m"], stats.Windows["1h"], stats.Windows["24h"]
+	if minute.Count != 10 || hour.Count != 11 || day.Count != 12 {
+		t.Errorf("Window counts %d, %d, %d", minute.Count, hour.Count, day.Count)
+	}
+	if minute.Total != 5500 || minute.Burned != 550 || minute.Median != 550 || minute.P90 != 900 || minute.Max != 1000 {
+		t.Errorf("Minute window %+v", minute)
+	}
+	if minute.FeeTPS < 5500.0/60 || minute.FeeTPS > 5500.0/9 {
+		t.Errorf("Minute fee rate %f", minute.FeeTPS)
+	}
+
+	if transfers := stats.ByType["transfer"]; transfers.Count != 10 || transfers.Total != 5500 || transfers.Min != 100 {
+		t.Errorf("Transfer stats %+v", transfers)
+	}
+	if calls := stats.ByType["contract_call"]; calls.Count != 1 || calls.Median != 5000 {
+		t.Errorf("Call stats %+v", calls)
+	}
+
+	// Stats are cached until the next settled block; callers get copies
+	delete(stats.Windows, "1m")
+	if again := f.GetFeeStats(); again.Windows["1m"].Count != 10 {
+		t.Errorf("Cached stats changed by a caller: %+v", again.Windows)
+	}
+
+	// Memory is bounded: old samples leave the distribution, not the totals
+	txs = make([]*fees.Transaction, fees.DefaultStatsCapacity)
+	for i := range txs {
+		txs[i] = &fees.Transaction{TxType: "transfer", Fee: fees.Fee{Total: 1}}
+	}
+	settleTestBlock(t, f, 2, txs...)
+	stats = f.GetFeeStats()
+	if stats.MaxFee != 1 || stats.Windows["24h"].Count != fees.DefaultStatsCapacity || stats.TotalTx != 12+fees.DefaultStatsCapacity {
+		t.Errorf("Max %d, day count %d, total %d after overflow", stats.MaxFee, stats.Windows["24h"].Count, stats.TotalTx)
+	}
+}
+
+func TestFeeHistory(t *testing.T) {
+	f := newTestFees(t, fees.Solidity)
+	if tip := f.SuggestTip(); tip != 0 {
+		t.Errorf("Suggested tip %d without history", tip)
+	}
+	proposer := common.BytesToAddress([]byte("proposer"))
+
+	blocks := [][]*fees.Transaction{
+		{{Fee: fees.Fee{Tip: 50, GasUsed: 79000}}, {Fee: fees.Fee{Tip: 10, GasUsed: 21000}}},
+		{},
+		{{Fee: fees.Fee{Tip: 100, GasUsed: 21000}}},
+	}
+	for i, txs := range blocks {
+		// Each block's own base fee goes into the history
+		if _, err := f.SettleBlock(int64(i+1), 1000+uint64(i)*100, testBlockGasLimit, proposer, nil, txs); err != nil {
+			t.Fatalf("SettleBlock failed: %v", err)
+		}
+	}
+
+	history, err := f.FeeHistory(2, -1, []float64{10, 90})
+	if err != nil {
+		t.Fatalf("FeeHistory failed: %v", err)
+	}
+	encoded, _ := json.Marshal(history)
+	want := `{"oldestBlock":"0x2","reward":[["0x0","0x0"],["0x64","0x64"]],"baseFeePerGas":["0x44c","0x4b0","0x41b"],"gasUsedRatio":[0,0.00021]}`
+	if string(encoded) != want {
+		t.Errorf("Fee history %s, want %s", encoded, want)
+	}
+
+	// Rewards are tips at percentiles of the block's gas, not its txs
+	history, err = f.FeeHistory(10, 1, []float64{0, 10, 25, 100})
+	if err != nil {
+		t.Fatalf("FeeHistory failed: %v", err)
+	}
+	encoded, _ = json.Marshal(history)
+	if !strings.Contains(string(encoded), `"reward":[["0xa","0xa","0x32","0x32"]]`) || len(history.BaseFee) != 2 {
+		t.Errorf("Fee history of block 1: %s", encoded)
+	}
+
+	if _, err := f.FeeHistory(1, 4, nil); err == nil {
+		t.Error("Expected an error for an unsettled block")
+	}
+	if _, err := f.FeeHistory(1, -1, []float64{90, 10}); err == nil {
+		t.Error("Expected an error for descending percentiles")
+	}
+
+	// The lowest tips of recent blocks are 10, 50 and 100
+	if tip := f.SuggestTip(); tip != 50 {
+		t.Errorf("Suggested tip %d, want 50", tip)
+	}
+	if encoded, _ := json.Marshal(f.MaxPriorityFeePerGas()); string(encoded) != `"0x32"` {
+		t.Errorf("maxPriorityFeePerGas %s", encoded)
+	}
+}
+
+func TestFeeSponsorship(t *testing.T) {
+	f := newTestFees(t, fees.Priority)
+	sponsor := common.BytesToAddress([]byte("sponsor"))
+	user := common.BytesToAddress([]byte("user"))
+	dapp := common.BytesToAddress([]byte("dapp"))
+	other := common.BytesToAddress([]byte("other"))
+	proposer := common.BytesToAddress([]byte("proposer"))
+
+	if _, err := f.CalculateSponsoredFee(fees.TransferGas, 0, "transfer", sponsor, user, dapp); err == nil {
+		t.Error("Expected an error without an allowance")
+	}
+	if err := f.GrantAllowance(fees.Allowance{Sponsor: sponsor, User: sponsor, Limit: 1}); err == nil {
+		t.Error("Expected an error for a self-sponsorship")
+	}
+	allowance := fees.Allowance{Sponsor: sponsor, User: user, Limit: 50000000, Contracts: []common.Address{dapp}}
+	if err := f.GrantAllowance(allowance); err != nil {
+		t.Fatalf("GrantAllowance failed: %v", err)
+	}
+
+	// A transfer costs 21000 gas at 1000 wei
+	fee, err := f.CalculateSponsoredFee(fees.TransferGas, 0, "transfer", sponsor, user, dapp)
+	if err != nil {
+		t.Fatalf("CalculateSponsoredFee failed: %v", err)
+	}
+	if _, err := f.CalculateSponsoredFee(fees.TransferGas, 0, "transfer", sponsor, user, other); err == nil {
+		t.Error("Expected an error for a contract outside the allowance")
+	}
+
+	sponsored := &fees.Transaction{From: user, To: dapp, FeePayer: sponsor, Fee: *fee}
+	own := &fees.Transaction{From: user, To: other, Fee: *fee}
+	settlement, err := f.SettleBlock(1, f.BaseFee(), testBlockGasLimit, proposer, nil, []*fees.Transaction{sponsored, own, sponsored})
+	if err != nil {
+		t.Fatalf("SettleBlock failed: %v", err)
+	}
+	if len(settlement.Debits) != 2 {
+		t.Fatalf("Debits %+v", settlement.Debits)
+	}
+	if d := settlement.Debits[0]; d.Account != sponsor || !d.Sponsored || d.Amount.Uint64() != 42000000 {
+		t.Errorf("Sponsor debit %+v", d)
+	}
+	if d := settlement.Debits[1]; d.Account != user || d.Sponsored || d.Amount.Uint64() != 21000000 {
+		t.Errorf("Sender debit %+v", d)
+	}
+	if got, _ := f.GetAllowance(sponsor, user); got.Spent != 42000000 {
+		t.Errorf("Allowance spent %d, want 42000000", got.Spent)
+	}
+
+	// Admission turns away a fee the allowance cannot cover; one that got
+	// into a block anyway is charged to its sender alone
+	if err := f.CheckAllowance(sponsor, user, dapp, fee.Total); err == nil {
+		t.Error("Expected an error for an exhausted allowance")
+	}
+	settlement, err = f.SettleBlock(2, f.BaseFee(), testBlockGasLimit, proposer, nil, []*fees.Transaction{sponsored, own})
+	if err != nil {
+		t.Fatalf("SettleBlock failed: %v", err)
+	}
+	if len(settlement.Debits) != 1 || settlement.Debits[0].Account != user || settlement.Debits[0].Amount.Uint64() != 42000000 {
+		t.Errorf("Overdrawing block debits %+v", settlement.Debits)
+	}
+	if got, _ := f.GetAllowance(sponsor, user); got.Spent != 42000000 {
+		t.Errorf("Overdrawing block spent %d", got.Spent)
+	}
+
+	// Expired allowances pay nothing; restored ones keep what they spent
+	allowance.Spent, allowance.Expiry = 42000000, time.Now().Add(-time.Minute).Unix()
+	if err := f.GrantAllowance(allowance); err != nil {
+		t.Fatalf("GrantAllowance failed: %v", err)
+	}
+	expired := &fees.Transaction{From: user, To: dapp, FeePayer: sponsor, Fee: fees.Fee{Total: 1}}
+	settlement, err = f.SettleBlock(3, f.BaseFee(), testBlockGasLimit, proposer, nil, []*fees.Transaction{expired})
+	if err != nil || settlement.Debits[0].Account != user {
+		t.Errorf("Expired allowance paid for a tx: %v", err)
+	}
+	if stored := f.Allowances(); len(stored) != 1 || stored[0].Spent != 42000000 {
+		t.Errorf("Stored allowances %+v", stored)
+	}
+	if err := f.RevokeAllowance(sponsor, user); err != nil {
+		t.Fatalf("RevokeAllowance failed: %v", err)
+	}
+	if _, ok := f.GetAllowance(sponsor, user); ok {
+		t.Error("Allowance survived revocation")
+	}
+}
+
+func TestFeeGovernance(t *testing.T) {
+	f := newTestFees(t, fees.Priority)
+	proposer := common.BytesToAddress([]byte("proposer"))
+	keys := make([]ed25519.PrivateKey, 3)
+	addrs := make([]common.Address, 3)
+	for i := range keys {
+		pub, key, _ := ed25519.GenerateKey(nil)
+		keys[i], addrs[i] = key, common.BytesToAddress(consensus.ValidatorAddress(pub))
+	}
+	a, b, c := keys[0], keys[1], keys[2]
+	validators := map[common.Address]uint64{addrs[0]: 40, addrs[1]: 30, addrs[2]: 30}
+	vote := func(id uint64, key ed25519.PrivateKey) error {
+		proposal := f.FeeProposals()[id-1]
+		return f.VoteFeeProposal(id, key.Public().(ed25519.PublicKey), ed25519.Sign(key, proposal.SignBytes()))
+	}
+
+	f.ActivateFeeProposals(1, validators)
+	config := f.GetConfig()
+	config.BaseFee, config.BurnPercent = 2000, 50
+	if err := f.SetFeeConfig(config); err == nil {
+		t.Error("Expected SetFeeConfig to need a proposal past genesis")
+	}
+	if _, err := f.SubmitFeeProposal(proposer, config, 1, "too late"); err == nil {
+		t.Error("Expected an error for a past activation height")
+	}
+	bad := config
+	bad.BurnPercent = 101
+	if _, err := f.SubmitFeeProposal(proposer, bad, 10, "invalid"); err == nil {
+		t.Error("Expected an error for an invalid config")
+	}
+
+	// 70 of 100 power passes, 40 does not
+	passed, err := f.SubmitFeeProposal(proposer, config, 5, "double the base fee")
+	if err != nil {
+		t.Fatalf("SubmitFeeProposal failed: %v", err)
+	}
+	rejected, _ := f.SubmitFeeProposal(proposer, fees.FeeConfig{BaseFee: 9000, MaxTip: 100, MaxFee: 1 << 60}, 5, "too steep")
+	vote(passed, a)
+	vote(passed, b)
+	vote(rejected, a)
+	if err := vote(passed, a); err == nil {
+		t.Error("Expected an error for a repeated vote")
+	}
+	proposals := f.FeeProposals()
+	if err := f.VoteFeeProposal(rejected, c.Public().(ed25519.PublicKey), ed25519.Sign(c, proposals[passed-1].SignBytes())); err == nil {
+		t.Error("Expected an error for a vote signed for another proposal")
+	}
+	if err := f.VoteFeeProposal(rejected, b.Public().(ed25519.PublicKey), ed25519.Sign(c, proposals[rejected-1].SignBytes())); err == nil {
+		t.Error("Expected an error for a vote signed with another key")
+	}
+
+	// Nothing changes before the activation height
+	if activated := f.ActivateFeeProposals(4, validators); len(activated) != 0 || f.GetConfig().BaseFee != 1000 {
+		t.Fatalf("Activated %v before height 5", activated)
+	}
+	activated := f.ActivateFeeProposals(5, validators)
+	if len(activated) != 1 || activated[0].Version != 1 || activated[0].Height != 5 || activated[0].ProposalID != passed {
+		t.Fatalf("Activated %+v", activated)
+	}
+	if proposals := f.FeeProposals(); proposals[0].Status != fees.ProposalActivated || proposals[1].Status != fees.ProposalRejected {
+		t.Errorf("Proposal statuses %s, %s", proposals[0].Status, proposals[1].Status)
+	}
+	if history := f.FeeConfigHistory(); len(history) != 2 || history[0].Config.BaseFee != 1000 || history[1].Config.BaseFee != 2000 {
+		t.Errorf("Config history %+v", history)
+	}
+
+	// Fees follow the config in force at each height
+	before, err := f.CalculateFeeAt(4, fees.TransferGas, 0, "transfer")
+	if err != nil {
+		t.Fatalf("CalculateFeeAt failed: %v", err)
+	}
+	after, err := f.CalculateFeeAt(5, fees.TransferGas, 0, "transfer")
+	if err != nil {
+		t.Fatalf("CalculateFeeAt failed: %v", err)
+	}
+	if before.Total != 21000000 || before.Burned != 4200000 || after.Total != 42000000 || after.Burned != 21000000 {
+		t.Errorf("Fees at heights 4 and 5: %+v, %+v", before, after)
+	}
+	if current, _ := f.CalculateFee(fees.TransferGas, 0, "transfer"); current.Total != after.Total {
+		t.Errorf("Current fee %d, want %d", current.Total, after.Total)
+	}
+
+	// A proposal tallied late still comes into force at its activation height
+	late, _ := f.SubmitFeeProposal(proposer, config, 7, "tallied late")
+	vote(late, a)
+	vote(late, c)
+	if activated := f.ActivateFeeProposals(9, validators); len(activated) != 1 || activated[0].Height != 7 {
+		t.Fatalf("Activated %+v", activated)
+	}
+
+	// Burn changes are versioned too, and settling charges under the block's
+	// version rather than the live one
+	f.EnableBurn(false)
+	if history := f.FeeConfigHistory(); len(history) != 4 || !history[2].BurnEnabled || history[3].BurnEnabled || history[3].Height != 9 {
+		t.Errorf("Config history %+v", history)
+	}
+	if settled, err := f.SettleFee(4, before, fees.TransferGas); err != nil || settled.Burned != 4200000 {
+		t.Errorf("Settled at height 4 under the live config: %+v, %v", settled, err)
+	}
+
+	// Base fees of blocks past the fee history window still recompute
+	dynamic := newTestFees(t, fees.Solidity)
+	proposer = common.BytesToAddress([]byte("validator"))
+	for height := int64(1); height <= fees.DefaultFeeHistoryBlocks+1; height++ {
+		if _, err := dynamic.SettleBlock(height, 1000+uint64(height), testBlockGasLimit, proposer, nil, nil); err != nil {
+			t.Fatalf("SettleBlock failed: %v", err)
+		}
+	}
+	if old, err := dynamic.CalculateFeeAt(1, fees.TransferGas, 0, "transfer"); err != nil || old.BaseFee != 1001 {
+		t.Errorf("Fee of block 1: %+v, %v", old, err)
+	}
+}
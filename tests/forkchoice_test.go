@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	"github.com/tendermint/tendermint/types"
+
+	"github.com/zennetwork/zennetwork/x/consensus"
+)
+
+// newForkBlock builds an unsigned block on a parent, nil for genesis
+func newForkBlock(parent *types.Block, height int64, nonce time.Duration) *types.Block {
+	header := &types.Header{Height: height, Time: time.Unix(0, 0).Add(nonce)}
+	if parent != nil {
+		header.LastBlockID = types.BlockID{Hash: parent.Header.Hash()}
+	}
+	return &types.Block{Header: header}
+}
+
+// newForkVote builds a vote for a block, nil hash for a nil vote
+func newForkVote(validator string, height int64, round int32, hash []byte) *types.Vote {
+	return &types.Vote{
+		Type:             tmproto.PrevoteType,
+		Height:           height,
+		Round:            round,
+		BlockID:          types.BlockID{Hash: hash},
+		ValidatorAddress: []byte(validator),
+	}
+}
+
+// TestForkChoiceReorgs tests the heaviest attested branch wins, latest
+// votes replace earlier ones, switching branches emits reorg events and
+// finalization prunes the losing branches
+func TestForkChoiceReorgs(t *testing.T) {
+	fc := consensus.NewForkChoice(nil)
+	a1 := newForkBlock(nil, 1, 1)
+	b1 := newForkBlock(nil, 1, 2)
+	a2 := newForkBlock(a1, 2, 3)
+
+	for _, block := range []*types.Block{a1, a2, b1} {
+		if reorg, err := fc.AddBlock(block); err != nil || reorg != nil {
+			t.Fatalf("Adding block %d: reorg %v, err %v", block.Header.Height, reorg, err)
+		}
+		if block == a2 {
+			fc.Attest(newForkVote("v1", 1, 0, a1.Header.Hash()), 10)
+		}
+	}
+	if fc.Head() != a2 {
+		t.Fatalf("Head at height %d, want the attested branch", fc.Head().Header.Height)
+	}
+	if _, err := fc.AddBlock(newForkBlock(newForkBlock(nil, 1, 9), 2, 9)); err == nil {
+		t.Errorf("Block with an unknown parent added")
+	}
+
+	// More power on the other branch rolls back both blocks of the first
+	reorg := fc.Attest(newForkVote("v2", 1, 0, b1.Header.Hash()), 15)
+	if reorg == nil || reorg.OldHead != a2 || reorg.NewHead != b1 || reorg.CommonAncestor != nil {
+		t.Fatalf("Expected a reorg from a2 to b1, got %+v", reorg)
+	}
+	if reorg.Depth() != 2 || reorg.RolledBack[0] != a2 || reorg.RolledBack[1] != a1 ||
+		len(reorg.Applied) != 1 || reorg.Applied[0] != b1 {
+		t.Errorf("Reorg rolled back %d and applied %d blocks", reorg.Depth(), len(reorg.Applied))
+	}
+
+	// A vote no newer than the validator's last one is ignored
+	if reorg := fc.Attest(newForkVote("v2", 1, 0, a1.Header.Hash()), 15); reorg != nil || fc.Weight(b1.Header.Hash()) != 15 {
+		t.Errorf("Stale vote moved weight: b1 has %d", fc.Weight(b1.Header.Hash()))
+	}
+
+	// A later nil vote withdraws the weight and the head returns
+	reorg = fc.Attest(newForkVote("v2", 1, 1, nil), 15)
+	if reorg == nil || reorg.NewHead != a2 || reorg.Depth() != 1 {
+		t.Fatalf("Expected a reorg back to a2, got %+v", reorg)
+	}
+	if fc.Weight(a1.Header.Hash()) != 10 || fc.Weight(b1.Header.Hash()) != 0 {
+		t.Errorf("Weights a1=%d b1=%d", fc.Weight(a1.Header.Hash()), fc.Weight(b1.Header.Hash()))
+	}
+
+	// Finalizing the other branch prunes the head's branch
+	reorg, err := fc.Finalize(b1)
+	if err != nil || reorg == nil || reorg.OldHead != a2 || reorg.NewHead != b1 {
+		t.Fatalf("Expected finalization to reorg to b1, got %+v, %v", reorg, err)
+	}
+	if fc.Root() != b1 || fc.Head() != b1 || fc.Has(a1.Header.Hash()) || fc.Has(a2.Header.Hash()) {
+		t.Errorf("Losing branch kept after finalization")
+	}
+	if reorg := fc.Attest(newForkVote("v1", 1, 2, a1.Header.Hash()), 10); reorg != nil {
+		t.Errorf("Vote at a finalized height moved the head")
+	}
+	if _, err := fc.AddBlock(newForkBlock(a1, 2, 4)); err == nil {
+		t.Errorf("Block on a pruned branch added")
+	}
+}
+
+// TestForkChoiceFollowsNetwork tests every node's fork choice head stays
+// on the committed chain while the network makes progress
+func TestForkChoiceFollowsNetwork(t *testing.T) {
+	vals := newTestValidators(t, 3)
+	nodes, commits := startTestNetwork(t, vals, []int{0, 1, 2}, func(node *consensus.Consensus) {
+		if err := node.OpenStorage(t.TempDir()); err != nil {
+			t.Fatalf("Failed to open storage: %v", err)
+		}
+	})
+	waitForCommits(t, vals, commits, len(nodes), 3)
+
+	for i, node := range nodes {
+		// The network keeps going; skip a node that committed meanwhile
+		height := node.Height()
+		head := node.Head()
+		if node.Height() != height {
+			continue
+		}
+		block, _, err := node.LoadBlock(height)
+		if err != nil {
+			t.Fatalf("Node %d has no block at its height: %v", i, err)
+		}
+		switch head.Header.Height {
+		case height:
+			if !bytes.Equal(head.Header.Hash(), block.Header.Hash()) {
+				t.Errorf("Node %d head is not its committed block", i)
+			}
+		case height + 1:
+			if !bytes.Equal(head.Header.LastBlockID.Hash, block.Header.Hash()) {
+				t.Errorf("Node %d head does not extend its committed block", i)
+			}
+		default:
+			t.Errorf("Node %d head at height %d, committed %d", i, head.Header.Height, height)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tendermint/tendermint/types"
+
+	"github.com/zennetwork/zennetwork/x/consensus"
+	"github.com/zennetwork/zennetwork/x/lightclient"
+)
+
+// testProvider serves light blocks from memory and records the heights asked for
+type testProvider struct {
+	blocks  map[int64]*lightclient.LightBlock
+	fetched []int64
+}
+
+func (p *testProvider) LightBlock(_ context.Context, height int64) (*lightclient.LightBlock, error) {
+	if height == 0 {
+		for h := range p.blocks {
+			if h > height {
+				height = h
+			}
+		}
+	}
+	p.fetched = append(p.fetched, height)
+	lb, ok := p.blocks[height]
+	if !ok {
+		return nil, lightclient.ErrLightBlockNotFound
+	}
+	return lb, nil
+}
+
+// newTestLightBlock signs a header on top of prev with the given validators
+func newTestLightBlock(signers []testValidator, prev *lightclient.LightBlock, height int64, start time.Time) *lightclient.LightBlock {
+	validators := make([]consensus.Validator, len(signers))
+	for i, v := range signers {
+		validators[i] = v.info
+	}
+	header := &types.Header{
+		ChainID:        consensus.DefaultChainID,
+		Height:         height,
+		Time:           start.Add(time.Duration(height) * time.Second),
+		ValidatorsHash: consensus.ValidatorSetHash(validators),
+	}
+	if prev != nil {
+		header.LastBlockID = types.BlockID{Hash: prev.Hash()}
+	}
+	commit := signTestCommit(signers, &types.Block{Header: header})
+	return &lightclient.LightBlock{Header: header, Commit: commit, Validators: validators}
+}
+
+// TestLightClientBisectionAndForks tests skipping verification across
+// validator set rotations, backwards verification, faulty witnesses and
+// fork detection on a chain whose set rotates by half every 5 heights
+func TestLightClientBisectionAndForks(t *testing.T) {
+	ctx := context.Background()
+	vals := newTestValidators(t, 8)
+	start := time.Now().Add(-time.Hour)
+
+	chain := make(map[int64]*lightclient.LightBlock)
+	for h := int64(1); h <= 15; h++ {
+		w := (h - 1) / 5
+		chain[h] = newTestLightBlock(vals[2*w:2*w+4], chain[h-1], h, start)
+	}
+	now := chain[15].Header.Time.Add(time.Minute)
+	options := lightclient.TrustOptions{Period: time.Hour * 24, Height: 1, Hash: chain[1].Hash()}
+
+	// The first and last sets share no validator, so 1 -> 15 needs a pivot
+	err := lightclient.Verify(chain[1], chain[15], options.Period, now, time.Second, lightclient.DefaultTrustLevel)
+	if !errors.Is(err, lightclient.ErrNotEnoughTrust) {
+		t.Fatalf("Disjoint validator sets verified directly: %v", err)
+	}
+	err = lightclient.Verify(chain[1], chain[2], time.Minute, now, time.Second, lightclient.DefaultTrustLevel)
+	if !errors.Is(err, lightclient.ErrOldHeaderExpired) {
+		t.Errorf("Expired trusted header accepted: %v", err)
+	}
+
+	// A witness whose header at 15 is signed by strangers gets dropped
+	strangers := newTestValidators(t, 4)
+	faultyBlocks := make(map[int64]*lightclient.LightBlock)
+	for h, lb := range chain {
+		faultyBlocks[h] = lb
+	}
+	faultyBlocks[15] = newTestLightBlock(strangers, chain[14], 15, start.Add(time.Millisecond))
+
+	primary := &testProvider{blocks: chain}
+	client, err := lightclient.NewClient(ctx, options, primary, []lightclient.Provider{
+		&testProvider{blocks: chain},
+		&testProvider{blocks: faultyBlocks},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	lb, err := client.VerifyLightBlockAtHeight(ctx, 15, now)
+	if err != nil {
+		t.Fatalf("Verification failed: %v", err)
+	}
+	if !bytes.Equal(lb.Hash(), chain[15].Hash()) {
+		t.Errorf("Verified the wrong header")
+	}
+	if _, err := client.TrustedLightBlock(8); err != nil {
+		t.Errorf("Bisection did not go through the pivot: fetched %v", primary.fetched)
+	}
+	if client.Witnesses() != 1 {
+		t.Errorf("Faulty witness kept: %d witnesses", client.Witnesses())
+	}
+
+	// Lower heights follow the hash links down from a trusted header
+	lb, err = client.VerifyLightBlockAtHeight(ctx, 5, now)
+	if err != nil || !bytes.Equal(lb.Hash(), chain[5].Hash()) {
+		t.Errorf("Backwards verification failed: %v", err)
+	}
+
+	// A witness with a properly signed conflicting header proves a fork
+	forkBlocks := make(map[int64]*lightclient.LightBlock)
+	for h, lb := range chain {
+		forkBlocks[h] = lb
+	}
+	forkBlocks[15] = newTestLightBlock(vals[4:8], chain[14], 15, start.Add(time.Millisecond))
+
+	client, err = lightclient.NewClient(ctx, options, &testProvider{blocks: chain},
+		[]lightclient.Provider{&testProvider{blocks: forkBlocks}})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	_, err = client.VerifyLightBlockAtHeight(ctx, 15, now)
+	var fork *lightclient.ForkError
+	if !errors.As(err, &fork) || !bytes.Equal(fork.Witness.Hash(), forkBlocks[15].Hash()) {
+		t.Fatalf("Fork not detected: %v", err)
+	}
+	if client.LatestTrusted().Height() != 1 {
+		t.Errorf("Client trusted a forked header")
+	}
+}
+
+// TestLightClientFollowsNode tests a light client following a live network
+// through the RPC stand-in, cross-checked against another node
+func TestLightClientFollowsNode(t *testing.T) {
+	ctx := context.Background()
+	vals := newTestValidators(t, 3)
+	nodes, commits := startTestNetwork(t, vals, []int{0, 1, 2}, func(node *consensus.Consensus) {
+		if err := node.OpenStorage(t.TempDir()); err != nil {
+			t.Fatalf("Failed to open storage: %v", err)
+		}
+	})
+	waitForCommits(t, vals, commits, len(nodes), 3)
+
+	server := lightclient.NewServer("127.0.0.1:0", lightclient.NewNodeProvider(nodes[0]))
+	if err := server.Start(ctx); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	t.Cleanup(func() { server.Stop() })
+	primary := lightclient.NewHTTPProvider("http://" + server.Addr())
+
+	root, err := primary.LightBlock(ctx, 1)
+	if err != nil {
+		t.Fatalf("Failed to fetch height 1: %v", err)
+	}
+	options := lightclient.TrustOptions{Period: time.Hour, Height: 1, Hash: root.Hash()}
+	client, err := lightclient.NewClient(ctx, options, primary,
+		[]lightclient.Provider{lightclient.NewNodeProvider(nodes[1])})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	lb, err := client.Update(ctx, time.Now())
+	if err != nil || lb == nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if lb.Height() < 3 {
+		t.Errorf("Light client at height %d, want at least 3", lb.Height())
+	}
+	block, _, err := nodes[2].LoadBlock(lb.Height())
+	if err == nil && !bytes.Equal(block.Header.Hash(), lb.Hash()) {
+		t.Errorf("Light client verified a header the network did not commit")
+	}
+	if _, err := primary.LightBlock(ctx, lb.Height()+1000); !errors.Is(err, lightclient.ErrLightBlockNotFound) {
+		t.Errorf("Missing height served: %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tendermint/tendermint/types"
+
+	"github.com/zennetwork/zennetwork/x/consensus"
+	"github.com/zennetwork/zennetwork/x/fees"
+	"github.com/zennetwork/zennetwork/x/mempool"
+)
+
+// newTestTx encodes a transaction from a one-byte sender address
+func newTestTx(t *testing.T, sender byte, nonce, tip, gas uint64) []byte {
+	tx := &mempool.Tx{
+		From:     common.BytesToAddress([]byte{sender}),
+		Nonce:    nonce,
+		GasLimit: gas,
+		Fee:      fees.Fee{BaseFee: mempool.DefaultMinBaseFee, Tip: tip, GasLimit: gas, Total: gas * (mempool.DefaultMinBaseFee + tip)},
+	}
+	bz, err := tx.Encode()
+	if err != nil {
+		t.Fatalf("Failed to encode tx: %v", err)
+	}
+	return bz
+}
+
+// TestMempoolOrderingAndLimits checks nonce ordering, tip priority,
+// replace-by-fee, eviction and the commit update
+func TestMempoolOrderingAndLimits(t *testing.T) {
+	config := mempool.DefaultConfig()
+	config.MaxTxs = 5
+	pool := mempool.NewWithConfig(config)
+
+	a1 := newTestTx(t, 0xa, 1, 5, 21000)
+	a0 := newTestTx(t, 0xa, 0, 5, 21000)
+	a3 := newTestTx(t, 0xa, 3, 50, 21000) // Nonce gap, not ready
+	b0 := newTestTx(t, 0xb, 0, 10, 21000)
+	for _, tx := range [][]byte{a1, a0, a3, b0} {
+		if err := pool.Insert(tx); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := pool.Insert(b0); !errors.Is(err, mempool.ErrTxExists) {
+		t.Errorf("Duplicate tx accepted: %v", err)
+	}
+	if err := pool.Insert(newTestTx(t, 0xc, 0, 1, config.MaxGas+1)); !errors.Is(err, mempool.ErrInvalidGas) {
+		t.Errorf("Tx above the gas limit accepted: %v", err)
+	}
+	underpaid, err := mempool.DecodeTx(newTestTx(t, 0xc, 0, 1, 21000))
+	if err != nil {
+		t.Fatalf("Failed to decode tx: %v", err)
+	}
+	underpaid.Fee.Total--
+	if bz, _ := underpaid.Encode(); !errors.Is(pool.Insert(bz), mempool.ErrFeeMismatch) {
+		t.Errorf("Tx whose fee total does not match its gas limit accepted")
+	}
+
+	// Highest tip first, each sender in nonce order, stopping at gaps
+	want := [][]byte{b0, a0, a1}
+	reaped := pool.ReapMaxGas(mempool.DefaultMaxGas)
+	if len(reaped) != len(want) {
+		t.Fatalf("Reaped %d txs, want %d", len(reaped), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(reaped[i], want[i]) {
+			t.Errorf("Reaped tx %d out of order", i)
+		}
+	}
+	if got := pool.ReapMaxGas(42000); len(got) != 2 {
+		t.Errorf("Reaped %d txs within 42000 gas, want 2", len(got))
+	}
+
+	// Replacing a nonce needs a PriceBump higher tip
+	if err := pool.Insert(newTestTx(t, 0xb, 0, 10, 30000)); !errors.Is(err, mempool.ErrUnderpriced) {
+		t.Errorf("Underpriced replacement accepted: %v", err)
+	}
+	b0Bumped := newTestTx(t, 0xb, 0, 11, 21000)
+	if err := pool.Insert(b0Bumped); err != nil {
+		t.Fatalf("Replacement rejected: %v", err)
+	}
+	if pool.Has(mempool.TxHash(b0)) || pool.Size() != 4 {
+		t.Errorf("Replaced tx still pending (size %d)", pool.Size())
+	}
+
+	// A full pool evicts the cheapest tail, never for a cheaper tx
+	c0 := newTestTx(t, 0xc, 0, 1, 21000)
+	if err := pool.Insert(c0); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := pool.Insert(newTestTx(t, 0xd, 0, 1, 21000)); !errors.Is(err, mempool.ErrMempoolFull) {
+		t.Errorf("Full pool accepted a cheap tx: %v", err)
+	}
+	d0 := newTestTx(t, 0xd, 0, 20, 21000)
+	if err := pool.Insert(d0); err != nil {
+		t.Fatalf("Full pool rejected a better tx: %v", err)
+	}
+	if pool.Has(mempool.TxHash(c0)) || !pool.Has(mempool.TxHash(d0)) {
+		t.Errorf("Eviction did not drop the lowest tip")
+	}
+
+	// Committing a0 and a different b0 drops both senders' stale nonces
+	pool.Update(1, [][]byte{a0, b0})
+	if pool.Has(mempool.TxHash(a0)) || pool.Has(mempool.TxHash(b0Bumped)) {
+		t.Errorf("Committed nonces still pending")
+	}
+	if err := pool.Insert(a0); !errors.Is(err, mempool.ErrNonceTooLow) {
+		t.Errorf("Committed tx re-entered the pool: %v", err)
+	}
+	if pool.NextNonce(common.BytesToAddress([]byte{0xa})) != 1 || pool.Size() != 3 {
+		t.Errorf("Unexpected pool after update: size %d", pool.Size())
+	}
+}
+
+// TestProposalsReapMempool checks blocks include mempool txs and the pool
+// drains as they commit
+func TestProposalsReapMempool(t *testing.T) {
+	vals := newTestValidators(t, 1)
+	node := configureTestNode(t, vals, &vals[0])
+	pool := mempool.New()
+	node.SetMempool(pool)
+
+	txs := [][]byte{newTestTx(t, 1, 0, 3, 21000), newTestTx(t, 1, 1, 3, 21000), newTestTx(t, 2, 0, 9, 21000)}
+	for _, tx := range txs {
+		if err := pool.Insert(tx); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	blocks := make(chan *types.Block, 64)
+	node.RegisterCommitListener(func(block *types.Block, _ *types.Commit) {
+		if len(block.Data.Txs) > 0 {
+			blocks <- block
+		}
+	})
+	if err := node.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start consensus: %v", err)
+	}
+
+	// Shard blocks keep the mempool order, the block lists them by shard
+	reaped := [][]byte{txs[2], txs[0], txs[1]}
+	want := make([][]byte, 0, len(reaped))
+	for shard := uint64(0); shard < consensus.NumShards; shard++ {
+		for _, tx := range reaped {
+			if consensus.TxShard(tx) == shard {
+				want = append(want, tx)
+			}
+		}
+	}
+
+	select {
+	case block := <-blocks:
+		if len(block.Data.Txs) != len(want) {
+			t.Fatalf("Block has %d txs, want %d", len(block.Data.Txs), len(want))
+		}
+		for i := range want {
+			if !bytes.Equal(block.Data.Txs[i], want[i]) {
+				t.Errorf("Block tx %d out of order", i)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("No block with transactions committed")
+	}
+
+	if pool.Size() != 0 {
+		t.Errorf("Committed txs still pending: %d", pool.Size())
+	}
+	select {
+	case block := <-blocks:
+		t.Errorf("Txs committed twice at height %d", block.Header.Height)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+// TestMempoolFeePayer checks a sponsored tx is admitted only with its fee
+// payer's signature over the tx as sent
+func TestMempoolFeePayer(t *testing.T) {
+	pool := mempool.New()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	tx, err := mempool.DecodeTx(newTestTx(t, 0xa, 0, 1, 21000))
+	if err != nil {
+		t.Fatalf("Failed to decode tx: %v", err)
+	}
+	tx.To = common.BytesToAddress([]byte("dapp"))
+	tx.FeePayer = crypto.PubkeyToAddress(key.PublicKey)
+
+	insert := func(tx *mempool.Tx) error {
+		bz, err := tx.Encode()
+		if err != nil {
+			t.Fatalf("Failed to encode tx: %v", err)
+		}
+		return pool.Insert(bz)
+	}
+	if err := insert(tx); !errors.Is(err, mempool.ErrFeePayer) {
+		t.Errorf("Tx naming a fee payer without its signature accepted: %v", err)
+	}
+	if err := tx.SignFeePayer(key); err != nil {
+		t.Fatalf("SignFeePayer failed: %v", err)
+	}
+	tampered := *tx
+	tampered.From = common.BytesToAddress([]byte{0xb})
+	if err := insert(&tampered); !errors.Is(err, mempool.ErrFeePayer) {
+		t.Errorf("Fee payer signature reused for another sender: %v", err)
+	}
+	if err := insert(tx); err != nil {
+		t.Errorf("Signed sponsored tx rejected: %v", err)
+	}
+}
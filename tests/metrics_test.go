@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zennetwork/zennetwork/x/consensus"
+	"github.com/zennetwork/zennetwork/x/service"
+)
+
+// TestConsensusMetrics tests the rolling window averages what nodes measure
+// committing blocks and that the metrics reach status and Prometheus
+func TestConsensusMetrics(t *testing.T) {
+	metrics := consensus.NewMetrics(2)
+	metrics.Record(consensus.HeightMetrics{Height: 1, Txs: 100, Finality: time.Second, Rounds: 3})
+	metrics.Record(consensus.HeightMetrics{Height: 2, Txs: 30, Interval: time.Second, Finality: time.Second, Rounds: 1})
+	metrics.Record(consensus.HeightMetrics{Height: 3, Txs: 60, Interval: 2 * time.Second, Finality: 2 * time.Second, Rounds: 2})
+
+	// Height 1 fell out of the window but still counts toward the totals
+	snap := metrics.Snapshot()
+	if snap.Height != 3 || snap.TPS != 30 || snap.BlockInterval != 1500*time.Millisecond ||
+		snap.Finality != 1500*time.Millisecond || snap.Rounds != 1.5 {
+		t.Errorf("Unexpected snapshot %+v", snap)
+	}
+	if snap.TotalTxs != 190 || snap.TotalBlocks != 3 {
+		t.Errorf("Totals %d txs, %d blocks", snap.TotalTxs, snap.TotalBlocks)
+	}
+
+	vals := newTestValidators(t, 3)
+	nodes, commits := startTestNetwork(t, vals, []int{0, 1, 2})
+	waitForCommits(t, vals, commits, len(nodes), 3)
+
+	measured := nodes[0].Metrics().Snapshot()
+	if measured.TotalBlocks < 3 || measured.BlockInterval <= 0 || measured.Finality <= 0 || measured.Rounds < 1 {
+		t.Errorf("Node measured %+v", measured)
+	}
+	status := nodes[0].GetStatus()
+	if status["block_time"].(int64) == int64(consensus.BlockTime) || status["rounds"].(float64) < 1 {
+		t.Errorf("Status reports block time %v, rounds %v", status["block_time"], status["rounds"])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := service.NewMetricsServer("127.0.0.1:0", nodes[0].Metrics())
+	if err := server.Start(ctx); err != nil {
+		t.Fatalf("Failed to start metrics server: %v", err)
+	}
+	t.Cleanup(func() { server.Stop() })
+
+	resp, err := http.Get("http://" + server.Addr() + service.MetricsPath)
+	if err != nil {
+		t.Fatalf("Failed to scrape metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read metrics: %v", err)
+	}
+	for _, name := range []string{"zen_consensus_height", "zen_consensus_txs_total", "zen_consensus_blocks_total",
+		"zen_consensus_tps", "zen_consensus_block_interval_seconds", "zen_consensus_finality_seconds", "zen_consensus_rounds"} {
+		if !strings.Contains(string(body), name+" ") {
+			t.Errorf("Metric %s not exported", name)
+		}
+	}
+}
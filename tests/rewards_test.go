@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/zennetwork/zennetwork/x/consensus"
+	"github.com/zennetwork/zennetwork/x/halving"
+)
+
+// fixedRewarder mints the same reward at every height
+type fixedRewarder uint64
+
+func (r fixedRewarder) CalculateReward(int64, []byte) (*big.Int, error) {
+	return new(big.Int).SetUint64(uint64(r)), nil
+}
+
+// TestRewardDistribution checks block rewards and fees are split exactly
+// between the proposer, the signers and delegators, identically on every node
+func TestRewardDistribution(t *testing.T) {
+	const blockReward, fees = 1000000000000000007, 1001
+	vals := newTestValidators(t, 3)
+	vals[0].info.Commission = 1000 // 10%
+	operator, delegator := vals[0].info.Address, []byte("delegator")
+
+	nodes, commits := startTestNetwork(t, vals, []int{0, 1, 2}, func(node *consensus.Consensus) {
+		node.SetRewardSources(fixedRewarder(blockReward), func([][]byte) *big.Int { return big.NewInt(fees) })
+		for _, account := range [][]byte{operator, delegator} {
+			if _, err := node.Delegate(account, operator, consensus.MinStake); err != nil {
+				t.Fatalf("Delegation failed: %v", err)
+			}
+		}
+	})
+	waitForCommits(t, vals, commits, len(nodes), 3)
+	for _, node := range nodes {
+		node.Stop()
+	}
+
+	// Every node keeps the same audit trail
+	for height := int64(1); height <= 3; height++ {
+		var want []byte
+		for i, node := range nodes {
+			record, err := node.GetRewardRecord(height)
+			if err != nil {
+				t.Fatalf("Node %d has no reward record at height %d: %v", i, height, err)
+			}
+			got, _ := json.Marshal(record)
+			if want != nil && !bytes.Equal(got, want) {
+				t.Errorf("Nodes disagree on the rewards at height %d", height)
+			}
+			want = got
+		}
+	}
+
+	node := nodes[0]
+	height := node.GetStatus()["height"].(int64)
+	allocated, delegated := new(big.Int), new(big.Int)
+	for h := int64(1); h <= height; h++ {
+		record, err := node.GetRewardRecord(h)
+		if err != nil {
+			t.Fatalf("No reward record at height %d: %v", h, err)
+		}
+		if record.Total.Cmp(big.NewInt(0).Add(big.NewInt(blockReward), big.NewInt(fees))) != 0 {
+			t.Fatalf("Total %s at height %d", record.Total, h)
+		}
+
+		// Allocations and payouts both account for every unit
+		allocs, payouts, signers := new(big.Int), new(big.Int), 0
+		wantDelegated := new(big.Int)
+		for _, alloc := range record.Allocations {
+			allocs.Add(allocs, alloc.Amount)
+			switch alloc.Source {
+			case consensus.AllocationProposerBonus:
+				bonus := new(big.Int).Div(new(big.Int).Mul(record.Total, big.NewInt(500)), big.NewInt(10000))
+				if alloc.Amount.Cmp(bonus) != 0 || !bytes.Equal(alloc.Validator, record.Proposer) {
+					t.Errorf("Proposer bonus %s at height %d, want %s", alloc.Amount, h, bonus)
+				}
+			case consensus.AllocationSigner:
+				signers++
+			}
+			if bytes.Equal(alloc.Validator, operator) {
+				allocated.Add(allocated, alloc.Amount)
+				rest := new(big.Int).Sub(alloc.Amount, new(big.Int).Div(new(big.Int).Mul(alloc.Amount, big.NewInt(1000)), big.NewInt(10000)))
+				wantDelegated.Add(wantDelegated, rest.Div(rest, big.NewInt(2)))
+			}
+		}
+		gotDelegated := new(big.Int)
+		for _, payout := range record.Payouts {
+			payouts.Add(payouts, payout.Amount)
+			if bytes.Equal(payout.Recipient, delegator) {
+				gotDelegated.Add(gotDelegated, payout.Amount)
+			}
+		}
+		if allocs.Cmp(record.Total) != 0 || payouts.Cmp(record.Total) != 0 {
+			t.Errorf("Height %d allocates %s and pays %s of %s", h, allocs, payouts, record.Total)
+		}
+		if h > 1 && signers == 0 {
+			t.Errorf("No signer rewards at height %d", h)
+		}
+		if gotDelegated.Cmp(wantDelegated) != 0 {
+			t.Errorf("Delegator paid %s at height %d, want %s", gotDelegated, h, wantDelegated)
+		}
+		delegated.Add(delegated, gotDelegated)
+	}
+
+	if got := node.GetRewards(delegator); got.Cmp(delegated) != 0 {
+		t.Errorf("Delegator balance %s, want %s", got, delegated)
+	}
+	if withdrawn := node.WithdrawRewards(delegator); withdrawn.Cmp(delegated) != 0 || node.GetRewards(delegator).Sign() != 0 {
+		t.Errorf("Withdrew %s of %s", withdrawn, delegated)
+	}
+	val, err := node.GetValidator(operator)
+	if err != nil {
+		t.Fatalf("Validator missing: %v", err)
+	}
+	if val.Reward.Cmp(allocated) != 0 {
+		t.Errorf("Validator reward %s, want %s", val.Reward, allocated)
+	}
+}
+
+// TestHalvingRewardPaidOnce checks the 1000 ZEN reward, past uint64, is
+// exact and that re-applying a height does not debit the pool twice
+func TestHalvingRewardPaidOnce(t *testing.T) {
+	h := halving.New()
+	if err := h.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start halving: %v", err)
+	}
+	want, _ := new(big.Int).SetString("1000000000000000000000", 10)
+
+	first, err := h.CalculateReward(1, nil)
+	if err != nil {
+		t.Fatalf("CalculateReward failed: %v", err)
+	}
+	again, err := h.CalculateReward(1, nil)
+	if err != nil {
+		t.Fatalf("CalculateReward failed on re-apply: %v", err)
+	}
+	if first.Cmp(want) != 0 || again.Cmp(first) != 0 {
+		t.Errorf("Block 1 rewarded %s then %s, want %s", first, again, want)
+	}
+	if phase := h.GetCurrentPhase(); phase.TotalDistributed.Cmp(want) != 0 {
+		t.Errorf("Distributed %s after paying one block", phase.TotalDistributed)
+	}
+}
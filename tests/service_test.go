@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tendermint/tendermint/types"
+	"go.uber.org/goleak"
+
+	"github.com/zennetwork/zennetwork/x/security"
+	"github.com/zennetwork/zennetwork/x/service"
+)
+
+// TestServicesRestartWithoutLeaks starts and stops modules repeatedly,
+// alternating between Stop and cancelling their context, and checks no
+// goroutine outlives them
+func TestServicesRestartWithoutLeaks(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	vals := newTestValidators(t, 1)
+	node := configureTestNode(t, vals, &vals[0])
+	commits := make(chan int64, 64)
+	node.RegisterCommitListener(func(block *types.Block, _ *types.Commit) {
+		select {
+		case commits <- block.Header.Height:
+		default:
+		}
+	})
+	services := []service.Service{node, security.New()}
+
+	var last int64
+	for i := 0; i < 4; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		for _, svc := range services {
+			if err := svc.Start(ctx); err != nil {
+				t.Fatalf("Start %d failed: %v", i, err)
+			}
+		}
+		if err := services[1].Start(ctx); err == nil {
+			t.Errorf("Running service started twice")
+		}
+
+		// The chain keeps growing across restarts
+		select {
+		case height := <-commits:
+			if height <= last {
+				t.Errorf("Committed height %d after %d", height, last)
+			}
+			last = height
+		case <-time.After(5 * time.Second):
+			t.Fatalf("No block committed after start %d", i)
+		}
+
+		if i%2 == 1 {
+			cancel()
+		}
+		for j := len(services) - 1; j >= 0; j-- {
+			if err := services[j].Stop(); err != nil {
+				t.Errorf("Stop %d failed: %v", i, err)
+			}
+		}
+		cancel()
+
+		// Drain commits made while stopping
+		for len(commits) > 0 {
+			last = <-commits
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/tendermint/tendermint/types"
+
+	"github.com/zennetwork/zennetwork/x/consensus"
+	"github.com/zennetwork/zennetwork/x/mempool"
+)
+
+// shardTestTxs generates random transactions that land in shards the
+// validator produces at a height
+func shardTestTxs(t *testing.T, node *consensus.Consensus, producer testValidator, height int64, count int) [][]byte {
+	txs := make([][]byte, 0, count)
+	for len(txs) < count {
+		tx := generateTestTxs(1)[0]
+		addr, err := node.ShardProducer(consensus.TxShard(tx), height)
+		if err != nil {
+			t.Fatalf("No shard producer: %v", err)
+		}
+		if bytes.Equal(addr, producer.info.Address) {
+			txs = append(txs, tx)
+		}
+	}
+	return txs
+}
+
+// TestShardBlocksFromCommittees tests committee members produce shard
+// blocks that beacon blocks carry and commit to, and that peers reject a
+// beacon block whose transactions or shard blocks were tampered with
+func TestShardBlocksFromCommittees(t *testing.T) {
+	vals := newTestValidators(t, 4)
+
+	// Every node holds the same transactions, as after mempool gossip
+	txs := make([][]byte, 12)
+	for i := range txs {
+		txs[i] = newTestTx(t, byte(i+1), 0, 5, 21000)
+	}
+	pools := make([]*mempool.Mempool, 0, len(vals))
+	nodes, commits := startTestNetwork(t, vals, []int{0, 1, 2, 3}, func(node *consensus.Consensus) {
+		pool := mempool.New()
+		for _, tx := range txs {
+			if err := pool.Insert(tx); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+		node.SetMempool(pool)
+		pools = append(pools, pool)
+	})
+
+	// Collect node 0's chain until every transaction committed
+	chain := make(map[int64]testCommit)
+	committed := 0
+	timeout := time.After(20 * time.Second)
+	for committed < len(txs) {
+		select {
+		case c := <-commits:
+			if c.node == 0 {
+				chain[c.block.Header.Height] = c
+				committed += len(c.block.Data.Txs)
+			}
+		case <-timeout:
+			t.Fatalf("Only %d of %d transactions committed", committed, len(txs))
+		}
+	}
+
+	producers := make(map[string]bool)
+	var sharded testCommit
+	for height := int64(1); height <= int64(len(chain)); height++ {
+		block := chain[height].block
+		shards, err := consensus.ShardBlocksOf(block)
+		if err != nil {
+			t.Fatalf("Height %d: %v", height, err)
+		}
+		for _, sb := range shards {
+			want, err := nodes[0].ShardProducer(sb.ShardID, height)
+			if err != nil || !bytes.Equal(sb.Producer, want) {
+				t.Errorf("Shard %d block at height %d not from its committee's producer", sb.ShardID, height)
+			}
+			producers[string(sb.Producer)] = true
+		}
+		if len(shards) > 1 && sharded.block == nil {
+			sharded = chain[height]
+		}
+	}
+	if len(producers) < 2 {
+		t.Errorf("Shard blocks came from %d producers, want several committees", len(producers))
+	}
+	if pools[0].Size() != 0 {
+		t.Errorf("Committed txs still pending: %d", pools[0].Size())
+	}
+	if sharded.block == nil {
+		t.Fatalf("No beacon block carried more than one shard block")
+	}
+
+	// A follower replays the chain up to the sharded block
+	verifier := newTestNode(t, vals, nil)
+	height := sharded.block.Header.Height
+	for h := int64(1); h < height; h++ {
+		if err := verifier.CommitBlock(chain[h].block, chain[h].commit); err != nil {
+			t.Fatalf("Failed to commit height %d: %v", h, err)
+		}
+	}
+
+	// Dropping a transaction leaves the shard blocks uncovered; the header
+	// and its commit stay valid, so only the shard check catches it
+	dropped := *sharded.block
+	dropped.Data.Txs = dropped.Data.Txs[1:]
+	if err := verifier.CommitBlock(&dropped, sharded.commit); err == nil {
+		t.Errorf("Block with a dropped transaction committed")
+	}
+
+	// A shard block re-signed by another validator is rejected
+	shards, _ := consensus.ShardBlocksOf(sharded.block)
+	forged := *shards[0]
+	for _, v := range vals {
+		if !bytes.Equal(v.info.Address, forged.Producer) {
+			forged.Producer = v.info.Address
+			forged.Signature = ed25519.Sign(v.signKey, forged.Hash())
+			break
+		}
+	}
+	forgedBytes, err := json.Marshal(append([]*consensus.ShardBlock{&forged}, shards[1:]...))
+	if err != nil {
+		t.Fatalf("Failed to encode shard blocks: %v", err)
+	}
+	tampered := *sharded.block
+	tampered.Data.Extensions = append([]types.Extension{}, sharded.block.Data.Extensions...)
+	for i, ext := range tampered.Data.Extensions {
+		if bytes.Contains(ext.Bytes, []byte(`"shard_id"`)) {
+			tampered.Data.Extensions[i].Bytes = forgedBytes
+		}
+	}
+	if err := verifier.CommitBlock(&tampered, sharded.commit); err == nil {
+		t.Errorf("Block with a shard block from the wrong producer committed")
+	}
+
+	if err := verifier.CommitBlock(sharded.block, sharded.commit); err != nil {
+		t.Fatalf("Sharded block rejected: %v", err)
+	}
+}
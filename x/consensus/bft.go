@@ -0,0 +1,830 @@
+package consensus
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	"github.com/tendermint/tendermint/types"
+)
+
+// BFT timeout defaults
+const (
+	DefaultTimeoutPropose   = 1000 * time.Millisecond
+	DefaultTimeoutPrevote   = 500 * time.Millisecond
+	DefaultTimeoutPrecommit = 500 * time.Millisecond
+	DefaultTimeoutDelta     = 500 * time.Millisecond // Added per round
+	DefaultTimeoutCommit    = BlockTime * time.Millisecond
+	DefaultGossipInterval   = 1000 * time.Millisecond // Re-send own messages lost in transit
+)
+
+// finalityVoteRetention is how many heights of precommits are kept
+const finalityVoteRetention = 100
+
+// maxRoundLookahead is how many rounds past the current one votes are
+// accepted for. Later rounds are dropped so a faulty validator cannot make
+// us allocate vote sets for arbitrary rounds.
+const maxRoundLookahead = 16
+
+// proposalMsgDomain separates proposal signatures from other signatures
+var proposalMsgDomain = []byte("zen-proposal-msg")
+
+// roundStep is a step of the BFT round state machine
+type roundStep uint8
+
+const (
+	stepNewHeight roundStep = iota
+	stepPropose
+	stepPrevote
+	stepPrecommit
+	stepCommit
+)
+
+// String returns the step name
+func (s roundStep) String() string {
+	switch s {
+	case stepNewHeight:
+		return "new_height"
+	case stepPropose:
+		return "propose"
+	case stepPrevote:
+		return "prevote"
+	case stepPrecommit:
+		return "precommit"
+	case stepCommit:
+		return "commit"
+	}
+	return "unknown"
+}
+
+// Proposal is a block proposed for a round. A block that already gathered
+// a polka (+2/3 prevotes) in POLRound may be re-proposed in a later round.
+type Proposal struct {
+	Height    int64        `json:"height"`
+	Round     int32        `json:"round"`
+	POLRound  int32        `json:"pol_round"` // -1 for a fresh block
+	Block     *types.Block `json:"block"`
+	Proposer  []byte       `json:"proposer"`
+	Signature []byte       `json:"signature"`
+}
+
+// ConsensusMessage is a BFT message gossiped between validators
+type ConsensusMessage struct {
+	Proposal   *Proposal   `json:"proposal,omitempty"`
+	Vote       *types.Vote `json:"vote,omitempty"`
+	Evidence   *Evidence   `json:"evidence,omitempty"`
+	ShardBlock *ShardBlock `json:"shard_block,omitempty"`
+}
+
+// roundVotes holds the votes of one round
+type roundVotes struct {
+	prevotes   *voteSet
+	precommits *voteSet
+}
+
+// validatedBlock is a proposed block that passed verification
+type validatedBlock struct {
+	block     *types.Block
+	proof     PoHProof
+	vrfOutput []byte
+	evidence  []Evidence
+	shards    []*ShardBlock
+}
+
+// roundState is the BFT state of the height being decided
+type roundState struct {
+	height      int64
+	round       int32
+	step        roundStep
+	proposal    *Proposal
+	blocks      map[string]*validatedBlock // block hash -> block
+	votes       map[int32]*roundVotes
+	lockedRound int32
+	lockedBlock *types.Block
+	validRound  int32
+	validBlock  *types.Block
+
+	prevoteTimeoutScheduled   bool
+	precommitTimeoutScheduled bool
+	polkaHandled              bool
+}
+
+// timeoutInfo identifies a scheduled timeout
+type timeoutInfo struct {
+	height int64
+	round  int32
+	step   roundStep
+}
+
+// SetBroadcaster sets the function used to gossip consensus messages to peers
+func (c *Consensus) SetBroadcaster(broadcast func(ConsensusMessage)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.broadcast = broadcast
+}
+
+// SetMempool sets the mempool proposals reap transactions from
+func (c *Consensus) SetMempool(mempool Mempool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.mempool = mempool
+}
+
+// RegisterCommitListener registers a function called after every committed block
+func (c *Consensus) RegisterCommitListener(listener func(*types.Block, *types.Commit)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.commitListeners = append(c.commitListeners, listener)
+}
+
+// RegisterReorgListener registers a function called when the fork choice
+// head switches branches, before the commit events of the same update
+func (c *Consensus) RegisterReorgListener(listener func(ReorgEvent)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reorgListeners = append(c.reorgListeners, listener)
+}
+
+// HandleMessage processes a proposal, vote, evidence or shard block
+// received from a peer
+func (c *Consensus) HandleMessage(msg ConsensusMessage) error {
+	c.mu.Lock()
+	var err error
+	switch {
+	case msg.Proposal != nil:
+		if err = c.handleProposal(msg.Proposal); err == nil {
+			err = c.writeWAL(msg, false)
+		}
+	case msg.Vote != nil:
+		if err = c.handleVote(msg.Vote); err == nil {
+			err = c.writeWAL(msg, false)
+		}
+	case msg.Evidence != nil:
+		err = c.handleEvidence(msg.Evidence)
+	case msg.ShardBlock != nil:
+		err = c.handleShardBlock(msg.ShardBlock)
+	default:
+		err = fmt.Errorf("empty consensus message")
+	}
+	c.mu.Unlock()
+
+	c.flush()
+	return err
+}
+
+// flush delivers queued messages and commit events outside the lock
+func (c *Consensus) flush() {
+	c.mu.Lock()
+	outbox, commits, reorgs := c.outbox, c.committed, c.reorgs
+	c.outbox, c.committed, c.reorgs = nil, nil, nil
+	broadcast, listeners, reorgListeners := c.broadcast, c.commitListeners, c.reorgListeners
+	c.mu.Unlock()
+
+	if broadcast != nil {
+		for _, msg := range outbox {
+			broadcast(msg)
+		}
+	}
+	for _, reorg := range reorgs {
+		for _, listener := range reorgListeners {
+			listener(reorg)
+		}
+	}
+	for _, committed := range commits {
+		for _, listener := range listeners {
+			listener(committed.block, committed.commit)
+		}
+	}
+}
+
+// queueReorg queues a fork choice reorg for listeners
+func (c *Consensus) queueReorg(reorg *ReorgEvent) {
+	if reorg == nil {
+		return
+	}
+	fmt.Printf("[CONSENSUS] Fork choice reorg at height %d: depth %d, new head %x\n",
+		reorg.AncestorHeight(), reorg.Depth(), reorg.NewHead.Header.Hash())
+	c.reorgs = append(c.reorgs, *reorg)
+}
+
+// send queues a message for peers
+func (c *Consensus) send(msg ConsensusMessage) {
+	c.outbox = append(c.outbox, msg)
+}
+
+// enterNewHeight resets the round state for the next height
+func (c *Consensus) enterNewHeight(height int64) {
+	pending := c.pending
+	c.pending = nil
+
+	c.rs = roundState{
+		height:      height,
+		round:       -1,
+		step:        stepNewHeight,
+		blocks:      make(map[string]*validatedBlock),
+		votes:       make(map[int32]*roundVotes),
+		lockedRound: -1,
+		validRound:  -1,
+	}
+	c.produceShardBlocks(height)
+	c.enterNewRound(height, 0)
+	c.replayPending(pending, height)
+}
+
+// replayPending handles messages that arrived early for a height
+func (c *Consensus) replayPending(pending []ConsensusMessage, height int64) {
+	for _, msg := range pending {
+		if msg.Proposal != nil && msg.Proposal.Height == height {
+			c.handleProposal(msg.Proposal)
+		}
+		if msg.Vote != nil && msg.Vote.Height == height {
+			c.handleVote(msg.Vote)
+		}
+		if msg.ShardBlock != nil && msg.ShardBlock.Height == height {
+			c.handleShardBlock(msg.ShardBlock)
+		}
+	}
+}
+
+// enterNewRound starts a round of the current height
+func (c *Consensus) enterNewRound(height int64, round int32) {
+	rs := &c.rs
+	if rs.height != height || round <= rs.round || rs.step == stepCommit {
+		return
+	}
+
+	rs.round = round
+	rs.step = stepPropose
+	rs.proposal = nil
+	rs.prevoteTimeoutScheduled = false
+	rs.precommitTimeoutScheduled = false
+	rs.polkaHandled = false
+	c.writeRoundWAL(height, round)
+
+	c.scheduleTimeout(c.TimeoutPropose+time.Duration(round)*c.TimeoutDelta, height, round, stepPropose)
+
+	// Propose if elected for this round
+	proposer, err := c.selectProposer(height, round)
+	if err == nil && c.signKey != nil && bytes.Equal(proposer, c.selfAddress) {
+		c.propose()
+	}
+}
+
+// propose creates, signs and gossips the proposal for the current round
+func (c *Consensus) propose() {
+	rs := &c.rs
+	if c.replaying {
+		return
+	}
+
+	// Re-send a proposal signed before a restart instead of signing another
+	if rs.proposal != nil && bytes.Equal(rs.proposal.Proposer, c.selfAddress) {
+		c.send(ConsensusMessage{Proposal: rs.proposal})
+		return
+	}
+
+	block, polRound := rs.validBlock, rs.validRound
+	if block == nil {
+		var err error
+		block, err = c.produceBlock(rs.height, c.pooledShardBlocks())
+		if err != nil {
+			fmt.Printf("[CONSENSUS] Block production failed at height %d: %v\n", rs.height, err)
+			return
+		}
+		polRound = -1
+	}
+
+	proposal := &Proposal{
+		Height:   rs.height,
+		Round:    rs.round,
+		POLRound: polRound,
+		Block:    block,
+		Proposer: c.selfAddress,
+	}
+	proposal.Signature = ed25519.Sign(c.signKey, proposalMsgSignBytes(proposal))
+
+	msg := ConsensusMessage{Proposal: proposal}
+	if err := c.writeWAL(msg, true); err != nil {
+		fmt.Printf("[CONSENSUS] Proposal not sent, WAL write failed: %v\n", err)
+		return
+	}
+	c.send(msg)
+	if err := c.handleProposal(proposal); err != nil {
+		fmt.Printf("[CONSENSUS] Own proposal rejected at height %d: %v\n", rs.height, err)
+	}
+}
+
+// resumeRound signs what the replayed round still needs from this node
+// after a restart: the proposal of an open propose step it was elected
+// for, or the vote of its step. Nothing is signed during replay, and every
+// signed message is in the WAL before it is sent, so a vote missing after
+// replay was never sent.
+func (c *Consensus) resumeRound() {
+	rs := &c.rs
+	if c.signKey == nil {
+		return
+	}
+
+	switch rs.step {
+	case stepPropose:
+		proposer, err := c.selectProposer(rs.height, rs.round)
+		if err == nil && bytes.Equal(proposer, c.selfAddress) {
+			c.propose()
+		}
+	case stepPrevote:
+		if _, ok := c.prevotes(rs.round).votes[string(c.selfAddress)]; !ok {
+			rs.step = stepPropose
+			c.checkProposalPrevote()
+		}
+	case stepPrecommit:
+		if _, ok := c.precommits(rs.round).votes[string(c.selfAddress)]; !ok {
+			rs.step = stepPrevote
+			rs.polkaHandled = false
+			c.checkPolka()
+		}
+	}
+}
+
+// resumeStep re-arms the timeouts of the current step after an in-process
+// restart. Gossip re-sends what was already signed, so nothing is signed
+// again.
+func (c *Consensus) resumeStep() {
+	rs := &c.rs
+	rs.prevoteTimeoutScheduled = false
+	rs.precommitTimeoutScheduled = false
+
+	if rs.step == stepPropose {
+		c.scheduleTimeout(c.TimeoutPropose+time.Duration(rs.round)*c.TimeoutDelta, rs.height, rs.round, stepPropose)
+		c.resumeRound()
+		return
+	}
+	c.checkPrevoteTimeout()
+	c.checkPrecommitTimeout()
+}
+
+// reapTxs returns the transactions for the next proposal, up to the block
+// gas limit
+func (c *Consensus) reapTxs() [][]byte {
+	if c.mempool == nil {
+		return make([][]byte, 0)
+	}
+	return c.mempool.ReapMaxGas(c.BlockGasLimit)
+}
+
+// handleProposal verifies a proposal and records its block
+func (c *Consensus) handleProposal(proposal *Proposal) error {
+	rs := &c.rs
+	if proposal.Height == rs.height+1 {
+		c.bufferMessage(ConsensusMessage{Proposal: proposal})
+		return nil
+	}
+	if proposal.Height != rs.height || proposal.Round != rs.round || rs.proposal != nil {
+		return nil
+	}
+	if proposal.Block == nil || proposal.POLRound < -1 || proposal.POLRound >= proposal.Round {
+		return fmt.Errorf("malformed proposal")
+	}
+
+	// The proposal must come from the elected proposer of its round
+	elected, err := c.selectProposer(proposal.Height, proposal.Round)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(proposal.Proposer, elected) {
+		return fmt.Errorf("proposal from %x, elected proposer is %x", proposal.Proposer, elected)
+	}
+	val := c.ValidatorSet[c.validatorIndex(elected)]
+	if len(val.PubKey) != ed25519.PublicKeySize ||
+		!ed25519.Verify(val.PubKey, proposalMsgSignBytes(proposal), proposal.Signature) {
+		return fmt.Errorf("invalid proposal signature from %x", proposal.Proposer)
+	}
+
+	// The block itself must be valid; a fresh block must belong to this round
+	validated, err := c.validateBlock(proposal.Block)
+	if err != nil {
+		return err
+	}
+	if proposal.POLRound == -1 && validated.proof.Round != proposal.Round {
+		return fmt.Errorf("fresh proposal carries a block from round %d", validated.proof.Round)
+	}
+
+	reorg, err := c.forkChoice.AddBlock(proposal.Block)
+	if err != nil {
+		return err
+	}
+	c.queueReorg(reorg)
+
+	rs.proposal = proposal
+	rs.blocks[string(proposal.Block.Header.Hash())] = validated
+
+	c.checkProposalPrevote()
+	c.checkPolka()
+	c.checkCommit()
+	return nil
+}
+
+// checkProposalPrevote prevotes for the current proposal once it can be judged
+func (c *Consensus) checkProposalPrevote() {
+	rs := &c.rs
+	if rs.step != stepPropose || rs.proposal == nil {
+		return
+	}
+
+	hash := []byte(rs.proposal.Block.Header.Hash())
+	polRound := rs.proposal.POLRound
+
+	if polRound == -1 {
+		if rs.lockedRound == -1 || c.isLockedOn(hash) {
+			c.enterPrevote(hash)
+		} else {
+			c.enterPrevote(nil)
+		}
+		return
+	}
+
+	// A re-proposed block needs the polka it claims
+	if !c.prevotes(polRound).hasTwoThirdsFor(hash, c.totalPower()) {
+		return
+	}
+	if rs.lockedRound <= polRound || c.isLockedOn(hash) {
+		c.enterPrevote(hash)
+	} else {
+		c.enterPrevote(nil)
+	}
+}
+
+// enterPrevote signs a prevote for hash (nil to prevote nil)
+func (c *Consensus) enterPrevote(hash []byte) {
+	rs := &c.rs
+	rs.step = stepPrevote
+	c.castVote(tmproto.PrevoteType, hash)
+	c.checkPrevoteTimeout()
+	c.checkPolka()
+}
+
+// enterPrecommit signs a precommit for hash (nil to precommit nil)
+func (c *Consensus) enterPrecommit(hash []byte) {
+	rs := &c.rs
+	rs.step = stepPrecommit
+	c.castVote(tmproto.PrecommitType, hash)
+	c.checkPrecommitTimeout()
+	c.checkCommit()
+}
+
+// castVote signs a vote, records it locally and gossips it
+func (c *Consensus) castVote(msgType tmproto.SignedMsgType, hash []byte) {
+	if c.replaying {
+		return
+	}
+
+	// Re-send a vote signed before a restart instead of signing another
+	set := c.prevotes(c.rs.round)
+	if msgType == tmproto.PrecommitType {
+		set = c.precommits(c.rs.round)
+	}
+	if own, ok := set.votes[string(c.selfAddress)]; ok {
+		c.send(ConsensusMessage{Vote: own})
+		return
+	}
+
+	vote := c.signVote(msgType, c.rs.height, c.rs.round, hash)
+	if vote == nil {
+		return
+	}
+
+	msg := ConsensusMessage{Vote: vote}
+	if err := c.writeWAL(msg, true); err != nil {
+		fmt.Printf("[CONSENSUS] Vote not sent, WAL write failed: %v\n", err)
+		return
+	}
+	c.send(msg)
+	if err := c.handleVote(vote); err != nil {
+		fmt.Printf("[CONSENSUS] Own vote rejected at height %d: %v\n", vote.Height, err)
+	}
+}
+
+// handleVote verifies a vote and applies the BFT rules it may trigger
+func (c *Consensus) handleVote(vote *types.Vote) error {
+	rs := &c.rs
+	if vote.Height == rs.height+1 {
+		c.bufferMessage(ConsensusMessage{Vote: vote})
+		return nil
+	}
+	if vote.Height != rs.height || rs.step == stepCommit {
+		return nil
+	}
+	if vote.Round < 0 || (vote.Type != tmproto.PrevoteType && vote.Type != tmproto.PrecommitType) {
+		return fmt.Errorf("malformed vote")
+	}
+	if vote.Round > rs.round+maxRoundLookahead {
+		return nil
+	}
+
+	idx := c.validatorIndex(vote.ValidatorAddress)
+	if idx < 0 || int32(idx) != vote.ValidatorIndex {
+		return fmt.Errorf("vote from unknown validator %x", vote.ValidatorAddress)
+	}
+	val := c.ValidatorSet[idx]
+	if err := VerifyVoteSignature(c.ChainID, val.PubKey, vote); err != nil {
+		return err
+	}
+
+	set := c.prevotes(vote.Round)
+	if vote.Type == tmproto.PrecommitType {
+		set = c.precommits(vote.Round)
+	}
+	existing, err := set.addVote(vote, VotingPower(val))
+	if existing == nil && err != nil {
+		return err
+	}
+	if existing != nil {
+		// The conflicting vote still counts toward its block below
+		c.reportEvidence(c.newDuplicateVoteEvidence(existing, vote))
+	} else {
+		c.queueReorg(c.forkChoice.Attest(vote, VotingPower(val)))
+	}
+
+	// +1/3 of the power is already in a later round: skip ahead
+	if vote.Round > rs.round {
+		rv := c.roundVotes(vote.Round)
+		if rv.prevotes.hasOneThirdAny(c.totalPower()) || rv.precommits.hasOneThirdAny(c.totalPower()) {
+			c.enterNewRound(rs.height, vote.Round)
+		}
+	}
+
+	if vote.Type == tmproto.PrevoteType {
+		c.checkProposalPrevote()
+		c.checkPrevoteTimeout()
+		c.checkPolka()
+	} else {
+		c.checkPrecommitTimeout()
+		c.checkCommit()
+	}
+	return err
+}
+
+// checkPrevoteTimeout schedules the prevote timeout once +2/3 prevoted anything
+func (c *Consensus) checkPrevoteTimeout() {
+	rs := &c.rs
+	if rs.step != stepPrevote || rs.prevoteTimeoutScheduled ||
+		!c.prevotes(rs.round).hasTwoThirdsAny(c.totalPower()) {
+		return
+	}
+
+	rs.prevoteTimeoutScheduled = true
+	c.scheduleTimeout(c.TimeoutPrevote+time.Duration(rs.round)*c.TimeoutDelta, rs.height, rs.round, stepPrevote)
+}
+
+// checkPrecommitTimeout schedules the precommit timeout once +2/3 precommitted anything
+func (c *Consensus) checkPrecommitTimeout() {
+	rs := &c.rs
+	if rs.step < stepPrevote || rs.step == stepCommit || rs.precommitTimeoutScheduled ||
+		!c.precommits(rs.round).hasTwoThirdsAny(c.totalPower()) {
+		return
+	}
+
+	rs.precommitTimeoutScheduled = true
+	c.scheduleTimeout(c.TimeoutPrecommit+time.Duration(rs.round)*c.TimeoutDelta, rs.height, rs.round, stepPrecommit)
+}
+
+// checkPolka locks on a block with +2/3 prevotes in the current round,
+// or precommits nil once +2/3 prevoted nil
+func (c *Consensus) checkPolka() {
+	rs := &c.rs
+	if rs.step < stepPrevote || rs.step == stepCommit {
+		return
+	}
+
+	hash, ok := c.prevotes(rs.round).twoThirdsMajority(c.totalPower())
+	if !ok {
+		return
+	}
+
+	if hash == nil {
+		if rs.step == stepPrevote {
+			c.enterPrecommit(nil)
+		}
+		return
+	}
+
+	validated, known := rs.blocks[string(hash)]
+	if !known || rs.polkaHandled {
+		return
+	}
+	rs.polkaHandled = true
+
+	rs.validBlock = validated.block
+	rs.validRound = rs.round
+	if rs.step == stepPrevote {
+		rs.lockedBlock = validated.block
+		rs.lockedRound = rs.round
+		c.enterPrecommit(hash)
+	}
+}
+
+// checkCommit commits a block once +2/3 precommitted it in any round
+func (c *Consensus) checkCommit() {
+	rs := &c.rs
+	if rs.step == stepCommit {
+		return
+	}
+
+	// Scan rounds in order so every node builds the same commit
+	rounds := make([]int32, 0, len(rs.votes))
+	for round := range rs.votes {
+		rounds = append(rounds, round)
+	}
+	sort.Slice(rounds, func(i, j int) bool { return rounds[i] < rounds[j] })
+
+	total := c.totalPower()
+	for _, round := range rounds {
+		rv := rs.votes[round]
+		hash, ok := rv.precommits.twoThirdsMajority(total)
+		if !ok || hash == nil {
+			continue
+		}
+		validated, known := rs.blocks[string(hash)]
+		if !known {
+			continue
+		}
+
+		commit := makeCommit(c.ValidatorSet, rv.precommits, types.BlockID{Hash: hash})
+		c.applyBlock(validated, commit)
+		return
+	}
+}
+
+// handleTimeout applies a fired timeout if it still matches the round state
+func (c *Consensus) handleTimeout(ti timeoutInfo) {
+	c.mu.Lock()
+	rs := &c.rs
+	if ti.height == rs.height {
+		switch ti.step {
+		case stepNewHeight:
+			if rs.step == stepCommit {
+				c.enterNewHeight(rs.height + 1)
+			}
+		case stepPropose:
+			if ti.round == rs.round && rs.step == stepPropose {
+				c.enterPrevote(nil)
+			}
+		case stepPrevote:
+			if ti.round == rs.round && rs.step == stepPrevote {
+				c.enterPrecommit(nil)
+			}
+		case stepPrecommit:
+			if ti.round == rs.round && rs.step != stepCommit {
+				c.enterNewRound(rs.height, rs.round+1)
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	c.flush()
+}
+
+// scheduleTimeout replaces the pending timeout. Nothing is scheduled
+// once the engine has stopped.
+func (c *Consensus) scheduleTimeout(d time.Duration, height int64, round int32, step roundStep) {
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	if !c.routines.Running() {
+		return
+	}
+
+	ti := timeoutInfo{height, round, step}
+	c.timer = c.clock.AfterFunc(d, func() {
+		c.routines.Do(func(context.Context) { c.handleTimeout(ti) })
+	})
+}
+
+// scheduleGossip schedules the next re-send of this node's messages while
+// the engine runs
+func (c *Consensus) scheduleGossip() {
+	if c.GossipInterval <= 0 || !c.routines.Running() {
+		return
+	}
+
+	c.gossipTimer = c.clock.AfterFunc(c.GossipInterval, func() {
+		c.routines.Do(func(context.Context) {
+			c.mu.Lock()
+			c.regossip()
+			c.scheduleGossip()
+			c.mu.Unlock()
+
+			c.flush()
+		})
+	})
+}
+
+// regossip re-sends the current proposal, this node's shard blocks, every
+// vote of this height and this node's precommit for the last block. Peers
+// that lost messages still reach the round thresholds and the polka a
+// locked block needs, and votes an equivocator split between peers reach
+// everyone.
+func (c *Consensus) regossip() {
+	rs := &c.rs
+	if rs.proposal != nil {
+		c.send(ConsensusMessage{Proposal: rs.proposal})
+	}
+	for _, sb := range c.pooledShardBlocks() {
+		if bytes.Equal(sb.Producer, c.selfAddress) {
+			c.send(ConsensusMessage{ShardBlock: sb})
+		}
+	}
+
+	rounds := make([]int32, 0, len(rs.votes))
+	for round := range rs.votes {
+		rounds = append(rounds, round)
+	}
+	sort.Slice(rounds, func(i, j int) bool { return rounds[i] < rounds[j] })
+	for _, round := range rounds {
+		rv := rs.votes[round]
+		for _, set := range []*voteSet{rv.prevotes, rv.precommits} {
+			for _, val := range c.ValidatorSet {
+				if vote, ok := set.votes[string(val.Address)]; ok {
+					c.send(ConsensusMessage{Vote: vote})
+				}
+				if conflict, ok := set.conflicts[string(val.Address)]; ok {
+					c.send(ConsensusMessage{Vote: conflict})
+				}
+			}
+		}
+	}
+
+	if c.Commit != nil && c.signKey != nil {
+		for i, sig := range c.Commit.Signatures {
+			if sig.BlockIDFlag == types.BlockIDFlagCommit && bytes.Equal(sig.ValidatorAddress, c.selfAddress) {
+				c.send(ConsensusMessage{Vote: CommitVote(c.Commit, i)})
+			}
+		}
+	}
+}
+
+// bufferMessage keeps a message for the next height until it starts,
+// including messages received before Start
+func (c *Consensus) bufferMessage(msg ConsensusMessage) {
+	if len(c.pending) < len(c.ValidatorSet)*4+1 {
+		c.pending = append(c.pending, msg)
+	}
+}
+
+// roundVotes returns the vote sets of a round, creating them if needed
+func (c *Consensus) roundVotes(round int32) *roundVotes {
+	rv, ok := c.rs.votes[round]
+	if !ok {
+		rv = &roundVotes{
+			prevotes:   newVoteSet(c.rs.height, round, tmproto.PrevoteType),
+			precommits: newVoteSet(c.rs.height, round, tmproto.PrecommitType),
+		}
+		c.rs.votes[round] = rv
+	}
+	return rv
+}
+
+// prevotes returns the prevotes of a round
+func (c *Consensus) prevotes(round int32) *voteSet {
+	return c.roundVotes(round).prevotes
+}
+
+// precommits returns the precommits of a round
+func (c *Consensus) precommits(round int32) *voteSet {
+	return c.roundVotes(round).precommits
+}
+
+// isLockedOn reports whether the node is locked on the block with hash
+func (c *Consensus) isLockedOn(hash []byte) bool {
+	return c.rs.lockedBlock != nil && bytes.Equal(c.rs.lockedBlock.Header.Hash(), hash)
+}
+
+// totalPower returns the voting power of the current validator set
+func (c *Consensus) totalPower() int64 {
+	return TotalVotingPower(c.ValidatorSet)
+}
+
+// proposalMsgSignBytes returns the bytes a proposer signs for a proposal
+func proposalMsgSignBytes(proposal *Proposal) []byte {
+	buf := make([]byte, 16)
+	binary.BigEndian.PutUint64(buf[:8], uint64(proposal.Height))
+	binary.BigEndian.PutUint32(buf[8:12], uint32(proposal.Round))
+	binary.BigEndian.PutUint32(buf[12:], uint32(proposal.POLRound))
+
+	h := sha256.New()
+	h.Write(proposalMsgDomain)
+	h.Write(buf)
+	if proposal.Block != nil && proposal.Block.Header != nil {
+		h.Write(proposal.Block.Header.Hash())
+	}
+	return h.Sum(nil)
+}
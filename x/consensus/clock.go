@@ -0,0 +1,36 @@
+package consensus
+
+import "time"
+
+// Clock supplies the time and round timers of the engine. The simulator
+// replaces the wall clock with a virtual one to replay runs exactly.
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f once d has elapsed, unless the timer is stopped
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a pending AfterFunc call
+type Timer interface {
+	// Stop cancels the call, reporting whether it was still pending
+	Stop() bool
+}
+
+// wallClock is the system clock
+type wallClock struct{}
+
+func (wallClock) Now() time.Time {
+	return time.Now()
+}
+
+func (wallClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// SetClock replaces the clock. It must be set before Start.
+func (c *Consensus) SetClock(clock Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.clock = clock
+}
@@ -0,0 +1,198 @@
+package consensus
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// Committee layout
+const (
+	NumShards        = 64 // One committee per shard
+	MinCommitteeSize = 4  // Validators reused across shards below NumShards*MinCommitteeSize
+)
+
+// committeeSeedDomain separates committee seeds from other randomness
+var committeeSeedDomain = []byte("zen-committee-shuffle")
+
+// CommitteeSeed derives the shuffling seed of an epoch from the VRF beacon
+// at its boundary block
+func CommitteeSeed(beacon []byte, epoch uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, epoch)
+
+	h := sha256.New()
+	h.Write(committeeSeedDomain)
+	h.Write(beacon)
+	h.Write(buf)
+	return h.Sum(nil)
+}
+
+// ShuffleCommittees assigns validators to NumShards committees for an epoch.
+// Validators are shuffled by the seed, then placed from the highest voting
+// power down into the smallest committee, breaking size ties by the least
+// power, so committees carry similar stake. With fewer than
+// NumShards*MinCommitteeSize validators every committee gets
+// MinCommitteeSize distinct members and validators serve several shards;
+// otherwise each validator serves exactly one shard.
+func ShuffleCommittees(validators []Validator, seed []byte, epoch uint64) []Committee {
+	committees := make([]Committee, NumShards)
+	for i := range committees {
+		committees[i] = Committee{
+			ID:         uint64(i),
+			Validators: make([]Validator, 0),
+			Shuffled:   true,
+			Seed:       seed,
+			Epoch:      epoch,
+		}
+	}
+
+	eligible := make([]Validator, 0, len(validators))
+	for _, val := range validators {
+		if VotingPower(val) > 0 {
+			eligible = append(eligible, val)
+		}
+	}
+	n := len(eligible)
+	if n == 0 {
+		return committees
+	}
+
+	// Canonical order, then a seeded Fisher-Yates shuffle
+	sort.Slice(eligible, func(i, j int) bool {
+		return bytes.Compare(eligible[i].Address, eligible[j].Address) < 0
+	})
+	rng := newSeedStream(seed)
+	for i := n - 1; i > 0; i-- {
+		j := int(rng.next() % uint64(i+1))
+		eligible[i], eligible[j] = eligible[j], eligible[i]
+	}
+
+	// Committee capacity and the seats to hand out
+	capacity, seats := (n+NumShards-1)/NumShards, n
+	if n < NumShards*MinCommitteeSize {
+		capacity = MinCommitteeSize
+		if capacity > n {
+			capacity = n
+		}
+		seats = NumShards * capacity
+	}
+	queue := make([]Validator, seats)
+	for i := range queue {
+		queue[i] = eligible[i%n]
+	}
+	sort.SliceStable(queue, func(i, j int) bool {
+		return VotingPower(queue[i]) > VotingPower(queue[j])
+	})
+
+	// Ties between equally loaded committees go to a seeded order
+	order := make([]int, NumShards)
+	for i := range order {
+		order[i] = i
+	}
+	for i := NumShards - 1; i > 0; i-- {
+		j := int(rng.next() % uint64(i+1))
+		order[i], order[j] = order[j], order[i]
+	}
+
+	power := make([]int64, NumShards)
+	lighter := func(a, b int) bool {
+		sizeA, sizeB := len(committees[a].Validators), len(committees[b].Validators)
+		return sizeA < sizeB || (sizeA == sizeB && power[a] < power[b])
+	}
+	for _, val := range queue {
+		best := -1
+		for _, id := range order {
+			if len(committees[id].Validators) >= capacity || committeeHas(committees[id], val.Address) {
+				continue
+			}
+			if best < 0 || lighter(id, best) {
+				best = id
+			}
+		}
+		if best >= 0 {
+			committees[best].Validators = append(committees[best].Validators, val)
+			power[best] += VotingPower(val)
+		}
+	}
+
+	// A reused validator may find every open committee already holds it;
+	// fill short committees with the first validators not yet seated there
+	minSize := MinCommitteeSize
+	if minSize > n {
+		minSize = n
+	}
+	for id := range committees {
+		for _, val := range eligible {
+			if len(committees[id].Validators) >= minSize {
+				break
+			}
+			if !committeeHas(committees[id], val.Address) {
+				committees[id].Validators = append(committees[id].Validators, val)
+				power[id] += VotingPower(val)
+			}
+		}
+	}
+
+	for id := range committees {
+		committees[id].TotalPower = power[id]
+	}
+	return committees
+}
+
+// shuffleValidators assigns committees for the current epoch
+func (c *Consensus) shuffleValidators() {
+	if len(c.ValidatorSet) == 0 {
+		return
+	}
+
+	epoch := uint64(c.CurrentHeight / c.EpochLength)
+	seed := CommitteeSeed(c.vrfBeacon, epoch)
+	c.Committees = ShuffleCommittees(c.ValidatorSet, seed, epoch)
+
+	var blockHash []byte
+	if c.CurrentBlock != nil {
+		blockHash = c.CurrentBlock.Header.Hash()
+	}
+	for i := range c.Committees {
+		c.Committees[i].BlockHash = blockHash
+		c.Committees[i].PoHSequence = uint64(c.CurrentHeight) // PoH entry of the boundary block
+	}
+
+	fmt.Printf("[CONSENSUS] Created %d committees for epoch %d (%d validators/shard)\n",
+		len(c.Committees), epoch, len(c.Committees[0].Validators))
+}
+
+// committeeHas reports whether a validator sits on a committee
+func committeeHas(committee Committee, address []byte) bool {
+	for _, val := range committee.Validators {
+		if bytes.Equal(val.Address, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// seedStream expands a seed into a deterministic stream of uint64 values
+type seedStream struct {
+	seed    []byte
+	counter uint64
+}
+
+// newSeedStream creates a stream over a seed
+func newSeedStream(seed []byte) *seedStream {
+	return &seedStream{seed: seed}
+}
+
+// next returns the next value of the stream
+func (s *seedStream) next() uint64 {
+	buf := make([]byte, len(s.seed)+8)
+	copy(buf, s.seed)
+	binary.BigEndian.PutUint64(buf[len(s.seed):], s.counter)
+	s.counter++
+
+	sum := sha256.Sum256(buf)
+	return binary.BigEndian.Uint64(sum[:8])
+}
@@ -1,14 +1,20 @@
 package consensus
 
 import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/sha256"
-	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"sync"
 	"time"
 
 	"github.com/tendermint/tendermint/types"
+
+	"github.com/zennetwork/zennetwork/x/service"
 )
 
 // ConsensusType defines the hybrid PoS + PoH consensus
@@ -26,17 +32,38 @@ const (
 	FinalityTime  = 1800  // <2 seconds
 	TargetTPS     = 10000 // Base target TPS
 	MaxTPS        = 50000 // Maximum TPS with parallel execution
-	MinStake      = 1000000000000000000000 // 1000 ZEN (18 decimals)
+	DefaultBlockGasLimit = 100000000 // 100M gas per block
+	DefaultChainID       = "zennetwork-mainnet-1"
+)
+
+// Stake amounts in base units (18 decimals)
+var (
+	OneZEN   = big.NewInt(1000000000000000000)
+	MinStake = new(big.Int).Mul(big.NewInt(1000), OneZEN) // 1000 ZEN
 )
 
+// Mempool supplies transactions to proposals
+type Mempool interface {
+	// ReapMaxGas returns ordered transactions whose gas limits fit maxGas
+	ReapMaxGas(maxGas uint64) [][]byte
+	// Update removes transactions committed at height and re-checks the rest
+	Update(height int64, txs [][]byte)
+}
+
 // Validator represents a network validator
 type Validator struct {
 	Address             []byte            `json:"address"`
 	PubKey              []byte            `json:"pub_key"`
-	Stake               uint64            `json:"stake"` // in ZEN (base unit)
+	Stake               *big.Int          `json:"stake"` // in ZEN (base unit), self-bond plus delegations
+	DelegatorShares     *big.Int          `json:"delegator_shares"` // Shares issued against Stake
+	Commission          uint64            `json:"commission"`       // Basis points of rewards kept by the operator
 	Power               int64             `json:"power"`
-	Reward              uint64            `json:"reward"`
+	Reward              *big.Int          `json:"reward"` // Total allocated to the validator and its delegators
 	Slashed             bool              `json:"slashed"`
+	Jailed              bool              `json:"jailed"`       // Excluded from voting and proposing
+	JailedUntil         int64             `json:"jailed_until"` // Earliest unjail time (unix seconds)
+	Tombstoned          bool              `json:"tombstoned"`   // Permanently jailed for double signing
+	VRFPubKey           []byte            `json:"vrf_pub_key"` // Compressed P-256 key
 	VRFProof            []byte            `json:"vrf_proof"`
 	PoHSequence         uint64            `json:"poh_sequence"`
 	PoHTimestamp        int64             `json:"poh_timestamp"`
@@ -46,12 +73,18 @@ type Validator struct {
 	EcoScore            float64           `json:"eco_score"` // Green validator score
 }
 
+// ValidatorAddress derives a validator address from its ed25519 public key
+func ValidatorAddress(pubKey ed25519.PublicKey) []byte {
+	sum := sha256.Sum256(pubKey)
+	return sum[:20]
+}
+
 // SlashingEvent tracks validator violations
 type SlashingEvent struct {
 	Height    int64  `json:"height"`
 	Reason    string `json:"reason"`
-	Penalty   uint64 `json:"penalty"` // Amount slashed
-	Timestamp int64  `json:"timestamp"`
+	Penalty   *big.Int `json:"penalty"` // Amount slashed
+	Timestamp int64    `json:"timestamp"`
 }
 
 // Committee represents a consensus committee
@@ -61,15 +94,20 @@ type Committee struct {
 	Shuffled    bool        `json:"shuffled"`
 	BlockHash   []byte      `json:"block_hash"`
 	PoHSequence uint64      `json:"poh_sequence"`
+	Seed        []byte      `json:"seed"`        // Shuffling seed, see CommitteeSeed
+	Epoch       uint64      `json:"epoch"`
+	TotalPower  int64       `json:"total_power"`
 }
 
 // ProofOfHistoryEntry represents a PoH sequence entry
 type ProofOfHistoryEntry struct {
-	Index         uint64 `json:"index"`
-	Hash          []byte `json:"hash"`
-	PreviousHash  []byte `json:"previous_hash"`
-	Timestamp     int64  `json:"timestamp"`
-	EntryData     []byte `json:"entry_data"`
+	Index         uint64   `json:"index"`
+	Hash          []byte   `json:"hash"`
+	PreviousHash  []byte   `json:"previous_hash"`
+	Timestamp     int64    `json:"timestamp"`
+	EntryData     []byte   `json:"entry_data"` // Mixin of shard block hashes
+	NumHashes     uint64   `json:"num_hashes"` // Sequential hashes since PreviousHash
+	Ticks         [][]byte `json:"ticks"`      // Chain state at every tick boundary
 }
 
 // Consensus handles hybrid PoS + PoH consensus
@@ -82,8 +120,64 @@ type Consensus struct {
 	PoHSequence     []ProofOfHistoryEntry `json:"poh_sequence"`
 	Committees      []Committee     `json:"committees"`
 	ConsensusType   ConsensusType   `json:"consensus_type"`
-	BlockProducers  []uint64        `json:"block_producers"` // Shard IDs
+	BlockProducers  []uint64        `json:"block_producers"` // Shard -> validator set index producing its next block
 	FinalityVotes   map[int64][]*types.Vote `json:"finality_votes"`
+	HashesPerTick   uint64          `json:"hashes_per_tick"`
+	TicksPerEntry   uint64          `json:"ticks_per_entry"`
+	TimeoutPropose   time.Duration `json:"timeout_propose"`
+	TimeoutPrevote   time.Duration `json:"timeout_prevote"`
+	TimeoutPrecommit time.Duration `json:"timeout_precommit"`
+	TimeoutDelta     time.Duration `json:"timeout_delta"`
+	TimeoutCommit    time.Duration `json:"timeout_commit"`
+	GossipInterval   time.Duration `json:"gossip_interval"`
+	EvidenceParams   EvidenceParams `json:"evidence_params"`
+	SlashingParams   SlashingParams `json:"slashing_params"`
+	EpochLength      int64         `json:"epoch_length"`     // Blocks between validator set changes
+	UnbondingPeriod  time.Duration `json:"unbonding_period"`
+	BlockGasLimit    uint64        `json:"block_gas_limit"`
+	ChainID          string        `json:"chain_id"` // Signed into every vote
+	RewardParams     RewardParams  `json:"reward_params"`
+	poh             *PoHGenerator
+	rs              roundState
+	clock           Clock
+	timer           Timer
+	gossipTimer     Timer
+	routines        service.Routines // Round timeouts in flight
+	pending         []ConsensusMessage // Messages for the next height
+	outbox          []ConsensusMessage
+	committed       []committedBlock
+	broadcast       func(ConsensusMessage)
+	mempool         Mempool
+	rewarder        BlockRewarder
+	feeShare        FeeShare
+	rewards         map[string]*big.Int // Account -> withdrawable rewards
+	rewardLog       []RewardRecord
+	commitListeners []func(*types.Block, *types.Commit)
+	forkChoice      *ForkChoice // Candidate blocks above CurrentBlock
+	shardHeads      [][]byte    // Shard -> hash of its last committed shard block
+	shardPool       map[uint64]*ShardBlock // Shard blocks for the next height
+	shardsProduced  int64       // Last height this node produced its shard blocks for
+	reorgs          []ReorgEvent
+	reorgListeners  []func(ReorgEvent)
+	metrics         *Metrics
+	selfAddress     []byte
+	signKey         ed25519.PrivateKey
+	vrfKey          *ecdsa.PrivateKey
+	vrfBeacon       []byte // VRF output of the last committed proposer
+	lastValidators  []Validator // Validator set that decided CurrentHeight
+	pendingEvidence []Evidence
+	committedEvidence map[string]int64 // Evidence key -> height committed
+	evidenceLog     []Evidence
+	canonicalCommits map[int64]*types.Commit // LastCommits within the signing window
+	signingInfos    map[string]*signingInfo
+	unjailQueue     [][]byte
+	pendingChanges  []validatorChange // Applied at the next epoch boundary
+	delegations     map[string]*Delegation
+	unbonding       []UnbondingEntry
+	unbonded        map[string]*big.Int // Delegator -> tokens released from unbonding
+	store           *BlockStore
+	wal             *WAL
+	replaying       bool // Rebuilding the round state from the WAL
 	muFinality      sync.Mutex
 }
 
@@ -95,444 +189,681 @@ func New() *Consensus {
 		PoHSequence:     make([]ProofOfHistoryEntry, 0),
 		Committees:      make([]Committee, 0),
 		ConsensusType:   Hybrid,
-		BlockProducers:  make([]uint64, 64), // 64 shards
+		BlockProducers:  make([]uint64, NumShards),
 		FinalityVotes:   make(map[int64][]*types.Vote),
+		HashesPerTick:   DefaultHashesPerTick,
+		TicksPerEntry:   DefaultTicksPerEntry,
+		TimeoutPropose:   DefaultTimeoutPropose,
+		TimeoutPrevote:   DefaultTimeoutPrevote,
+		TimeoutPrecommit: DefaultTimeoutPrecommit,
+		TimeoutDelta:     DefaultTimeoutDelta,
+		TimeoutCommit:    DefaultTimeoutCommit,
+		GossipInterval:   DefaultGossipInterval,
+		EvidenceParams:   DefaultEvidenceParams(),
+		SlashingParams:   DefaultSlashingParams(),
+		EpochLength:      DefaultEpochLength,
+		UnbondingPeriod:  DefaultUnbondingPeriod,
+		BlockGasLimit:    DefaultBlockGasLimit,
+		ChainID:          DefaultChainID,
+		RewardParams:     DefaultRewardParams(),
+		committedEvidence: make(map[string]int64),
+		canonicalCommits: make(map[int64]*types.Commit),
+		signingInfos:     make(map[string]*signingInfo),
+		delegations:      make(map[string]*Delegation),
+		unbonded:         make(map[string]*big.Int),
+		rewards:          make(map[string]*big.Int),
+		forkChoice:       NewForkChoice(nil),
+		shardHeads:       make([][]byte, NumShards),
+		shardPool:        make(map[uint64]*ShardBlock),
+		metrics:          NewMetrics(DefaultMetricsWindow),
+		clock:            wallClock{},
 	}
 }
 
-// Start begins consensus operations
-func (c *Consensus) Start() error {
+var _ service.Service = (*Consensus)(nil)
+
+// Start begins consensus operations. Round timeouts stop firing once ctx
+// is cancelled or Stop is called.
+func (c *Consensus) Start(ctx context.Context) error {
+	if _, err := c.routines.Begin(ctx); err != nil {
+		return fmt.Errorf("consensus: %w", err)
+	}
+
 	c.mu.Lock()
-	defer c.mu.Unlock()
 
 	fmt.Println("[CONSENSUS] Starting hybrid PoS + PoH consensus")
 	fmt.Printf("  - Block Time: %dms\n", BlockTime)
 	fmt.Printf("  - Finality: <%dms\n", FinalityTime)
 	fmt.Printf("  - Target TPS: %d (Max: %d)\n", TargetTPS, MaxTPS)
-	fmt.Printf("  - Min Stake: %d ZEN\n", MinStake/1000000000000000000)
+	fmt.Printf("  - Min Stake: %s ZEN\n", toZEN(MinStake))
 	fmt.Printf("  - Validators: %d\n", len(c.ValidatorSet))
+	fmt.Printf("  - PoH: %d hashes/tick, %d ticks/entry\n", c.HashesPerTick, c.TicksPerEntry)
 
 	// Initialize PoH with genesis
 	if err := c.initializePoH(); err != nil {
+		c.mu.Unlock()
+		c.routines.End()
 		return fmt.Errorf("failed to initialize PoH: %w", err)
 	}
 
-	// Shuffle validators into committees
-	c.shuffleValidators()
+	// Shuffle validators into committees unless restored from the store
+	if len(c.Committees) == 0 {
+		c.shuffleValidators()
+	}
+	c.updateBlockProducers()
 
-	// Start block production loop
-	go c.blockProductionLoop()
+	// Start BFT rounds for the next height, first rebuilding any votes
+	// and locks the WAL recorded before a restart
+	if c.signKey == nil || c.vrfKey == nil {
+		fmt.Println("[CONSENSUS] No validator keys configured, following the chain only")
+	}
+	height := int64(len(c.PoHSequence))
+	if c.rs.height == height {
+		// Restarted in-process: votes and locks are still in memory, so
+		// rejoin the round the peers are still in
+		c.resumeStep()
+	} else {
+		pending := c.pending
+		c.pending = nil
+		c.replaying = true
+		c.enterNewHeight(height)
+		err := c.replayWAL(height)
+		c.replaying = false
+		if err != nil {
+			c.mu.Unlock()
+			c.routines.End()
+			return fmt.Errorf("failed to replay WAL: %w", err)
+		}
+		c.resumeRound()
+		c.replayPending(pending, height)
+	}
+	c.scheduleGossip()
+	c.mu.Unlock()
+
+	// Halt the round timer when the node shuts down
+	c.routines.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		c.mu.Lock()
+		if c.timer != nil {
+			c.timer.Stop()
+		}
+		if c.gossipTimer != nil {
+			c.gossipTimer.Stop()
+		}
+		c.mu.Unlock()
+	})
 
+	c.flush()
 	return nil
 }
 
-// Stop halts consensus
+// Stop halts consensus, waits for timeouts already firing and closes storage
 func (c *Consensus) Stop() error {
+	c.mu.Lock()
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	if c.gossipTimer != nil {
+		c.gossipTimer.Stop()
+	}
+	c.mu.Unlock()
+
+	c.routines.End()
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if c.wal != nil {
+		c.wal.Close()
+		c.wal = nil
+	}
+	if c.store != nil {
+		c.store.Close()
+		c.store = nil
+	}
+
 	fmt.Println("[CONSENSUS] Stopping consensus engine")
 	return nil
 }
 
-// AddValidator adds a new validator to the set
+// AddValidator bonds a new validator with its self-delegation. Genesis
+// validators join immediately; later ones join at the next epoch boundary.
 func (c *Consensus) AddValidator(v Validator) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	// Check minimum stake
-	if v.Stake < MinStake {
-		return fmt.Errorf("validator stake below minimum: %d < %d", v.Stake, MinStake)
+	if v.Stake == nil || v.Stake.Cmp(MinStake) < 0 {
+		return fmt.Errorf("validator stake below minimum: %s < %s", orZero(v.Stake), MinStake)
+	}
+	if v.Commission > MaxCommission {
+		return fmt.Errorf("validator commission above 100%%: %d bps", v.Commission)
+	}
+
+	// Block signatures and proposer election require keys
+	if len(v.PubKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("validator %x has no ed25519 public key", v.Address)
+	}
+	if len(v.VRFPubKey) == 0 {
+		return fmt.Errorf("validator %x has no VRF public key", v.Address)
 	}
 
 	// Check if already exists
-	for _, val := range c.ValidatorSet {
-		if string(val.Address) == string(v.Address) {
-			return fmt.Errorf("validator already exists: %x", v.Address)
-		}
+	if c.validatorIndex(v.Address) >= 0 || c.pendingAdd(v.Address) {
+		return fmt.Errorf("validator already exists: %x", v.Address)
 	}
 
 	// Calculate voting power based on stake
-	v.Power = int64(v.Stake / 1000000000) // Normalize
-
-	// Add to set
-	c.ValidatorSet = append(c.ValidatorSet, v)
-
-	// Re-shuffle committees
-	c.shuffleValidators()
+	v.Stake = new(big.Int).Set(v.Stake)
+	v.Power = StakeToPower(v.Stake)
+	v.DelegatorShares = new(big.Int).Set(v.Stake)
+	c.addShares(v.Address, v.Address, v.Stake)
+
+	if len(c.PoHSequence) == 0 {
+		// Genesis: the chain has not started yet
+		c.ValidatorSet = append(c.ValidatorSet, v)
+	} else {
+		c.queueChange(changeAdd, v)
+	}
 
-	fmt.Printf("[CONSENSUS] Added validator: %x (Stake: %d ZEN, Power: %d)\n",
-		v.Address[:8], v.Stake/1000000000000000000, v.Power)
+	fmt.Printf("[CONSENSUS] Added validator: %x (Stake: %s ZEN, Power: %d)\n",
+		v.Address[:8], toZEN(v.Stake), v.Power)
 
 	return nil
 }
 
-// RemoveValidator removes a validator from the set
+// RemoveValidator queues a validator to leave the set at the next epoch
+// boundary, when all its delegations start unbonding
 func (c *Consensus) RemoveValidator(address []byte) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	for i, val := range c.ValidatorSet {
-		if string(val.Address) == string(address) {
-			c.ValidatorSet = append(c.ValidatorSet[:i], c.ValidatorSet[i+1:]...)
-			c.shuffleValidators()
-			fmt.Printf("[CONSENSUS] Removed validator: %x\n", address[:8])
-			return nil
-		}
+	idx := c.validatorIndex(address)
+	if idx < 0 {
+		return fmt.Errorf("validator not found: %x", address)
 	}
 
-	return fmt.Errorf("validator not found: %x", address[:8])
+	c.queueChange(changeRemove, c.ValidatorSet[idx])
+	return nil
 }
 
-// ProduceBlock produces a new block using PoS + PoH
+// ProduceBlock produces a new beacon block using PoS + PoH for the current
+// round. With nil txs it carries the shard blocks committees produced for
+// the height; otherwise txs are packed into shard blocks, which this node
+// must be the producer of.
 func (c *Consensus) ProduceBlock(height int64, txs [][]byte) (*types.Block, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Get PoH entry for this height
-	pohEntry, err := c.getPoHEntry(height)
+	if txs == nil {
+		return c.produceBlock(height, c.pooledShardBlocks())
+	}
+	shards, err := c.buildShardBlocks(height, txs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get PoH entry: %w", err)
+		return nil, fmt.Errorf("failed to build shard blocks: %w", err)
+	}
+	return c.produceBlock(height, shards)
+}
+
+// produceBlock builds and signs a beacon block for the current round
+func (c *Consensus) produceBlock(height int64, shards []*ShardBlock) (*types.Block, error) {
+	// Only a validator holding its keys may propose
+	if c.signKey == nil || c.vrfKey == nil {
+		return nil, fmt.Errorf("no validator keys configured")
 	}
 
-	// Select validator based on PoS (stake-weighted) and PoH (sequence)
-	proposer, err := c.selectProposer(height, pohEntry)
+	// Select validator based on PoS (stake-weighted) and the VRF beacon
+	round := c.rs.round
+	proposer, err := c.selectProposer(height, round)
 	if err != nil {
 		return nil, fmt.Errorf("failed to select proposer: %w", err)
 	}
+	if !bytes.Equal(proposer, c.selfAddress) {
+		return nil, fmt.Errorf("%w for height %d round %d", ErrNotProposer, height, round)
+	}
 
-	// Create block header
+	// Get PoH entry for this height, committing to the shard blocks
+	pohEntry, err := c.getPoHEntry(height, ShardHashes(shards))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PoH entry: %w", err)
+	}
+
+	// Reveal the VRF output that seeds the next height
+	vrfProof, err := c.proveEligibility(height)
+	if err != nil {
+		return nil, err
+	}
+
+	// Include pending misbehaviour evidence
+	evidence := c.reapEvidence()
+
+	// Create block header, committing to the PoH entry and thereby the shard blocks
 	header := &types.Header{
-		Height:     height,
-		Time:       time.Now(),
-		LastBlockID: types.BlockID{Hash: c.CurrentBlock.Header.Hash()},
-		Proposer:   proposer,
+		ChainID:        c.ChainID,
+		Height:         height,
+		Time:           c.clock.Now(),
+		DataHash:       pohEntry.Hash,
+		ValidatorsHash: ValidatorSetHash(c.ValidatorSet),
+		EvidenceHash:   evidenceHash(evidence),
+		Proposer:       proposer,
+	}
+	// Extend the finalized anchor of the fork choice: candidates above it
+	// carry no commit yet, so no block can build on them
+	if parent := c.forkChoice.Root(); parent != nil {
+		header.LastBlockID = types.BlockID{Hash: parent.Header.Hash()}
 	}
 
 	// Create block
 	block := &types.Block{
 		Header: header,
 		Data: types.Data{
-			Txs: txs,
+			Txs: shardTxs(shards),
 		},
+		LastCommit: c.Commit,
 	}
 
-	// Add PoH proof to block
+	// Add signed PoH proof to block
 	pohProof := PoHProof{
 		Entry:      *pohEntry,
 		Validator:  proposer,
-		Signature:  []byte{}, // Would be actual signature in production
-		Timestamp:  time.Now().UnixNano(),
+		VRFProof:   vrfProof,
+		Round:      round,
+		Timestamp:  c.clock.Now().UnixNano(),
 	}
+	pohProof.Signature = ed25519.Sign(c.signKey, proposalSignBytes(header, pohProof))
 
 	// Encode PoH proof
 	pohProofBytes, _ := json.Marshal(pohProof)
 	block.Data.Extensions = []types.Extension{
-		{Index: 0, Bytes: pohProofBytes},
+		{Index: extensionPoHProof, Bytes: pohProofBytes},
+	}
+	if len(evidence) > 0 {
+		evidenceBytes, _ := json.Marshal(evidence)
+		block.Data.Extensions = append(block.Data.Extensions,
+			types.Extension{Index: extensionEvidence, Bytes: evidenceBytes})
+	}
+	if len(shards) > 0 {
+		shardBytes, _ := json.Marshal(shards)
+		block.Data.Extensions = append(block.Data.Extensions,
+			types.Extension{Index: extensionShardBlocks, Bytes: shardBytes})
 	}
 
-	// Update current state
-	c.CurrentHeight = height
-	c.CurrentBlock = block
-
-	fmt.Printf("[CONSENSUS] Block produced at height %d by validator %x\n",
-		height, proposer[:8])
+	fmt.Printf("[CONSENSUS] Block produced at height %d by validator %x (%d shard blocks)\n",
+		height, proposer[:8], len(shards))
 
 	return block, nil
 }
 
-// CommitBlock commits a block and finalizes it
-func (c *Consensus) CommitBlock(block *types.Block) error {
+// CommitBlock commits a block decided by the network, e.g. while catching up.
+// The commit must carry precommits from more than 2/3 of the voting power.
+func (c *Consensus) CommitBlock(block *types.Block, commit *types.Commit) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
 
-	// Verify PoH proof
-	if err := c.verifyPoHProof(block); err != nil {
-		return fmt.Errorf("PoH proof verification failed: %w", err)
+	err := c.commitBlock(block, commit)
+	c.mu.Unlock()
+
+	c.flush()
+	return err
+}
+
+// commitBlock verifies a block and its commit certificate and applies it
+func (c *Consensus) commitBlock(block *types.Block, commit *types.Commit) error {
+	validated, err := c.validateBlock(block)
+	if err != nil {
+		return err
 	}
 
-	// Collect signatures for commit
-	// In production, this would be actual validator signatures
-	commit := &types.Commit{
-		BlockID: types.BlockID{Hash: block.Header.Hash()},
-		Signatures: make([]types.CommitSig, 0),
+	if commit == nil || commit.Height != block.Header.Height ||
+		!bytes.Equal(commit.BlockID.Hash, block.Header.Hash()) {
+		return fmt.Errorf("commit does not match block at height %d", block.Header.Height)
+	}
+	if err := VerifyCommit(c.ChainID, c.ValidatorSet, commit); err != nil {
+		return fmt.Errorf("commit verification failed: %w", err)
 	}
 
-	c.Commit = commit
+	c.applyBlock(validated, commit)
+	return nil
+}
 
-	fmt.Printf("[CONSENSUS] Block committed at height %d (Finality achieved in <2s)\n",
-		block.Header.Height)
+// validateBlock verifies the shard blocks, PoH proof, proposer, last commit
+// and evidence of a block for the next height
+func (c *Consensus) validateBlock(block *types.Block) (*validatedBlock, error) {
+	if block == nil || block.Header == nil {
+		return nil, fmt.Errorf("missing block header")
+	}
 
-	return nil
+	// Verify the shard blocks the block carries and the PoH proof over them
+	shards, err := c.blockShards(block)
+	if err != nil {
+		return nil, fmt.Errorf("shard block verification failed: %w", err)
+	}
+	pohProof, err := c.verifyPoHProof(block, shards)
+	if err != nil {
+		return nil, fmt.Errorf("PoH proof verification failed: %w", err)
+	}
+	if !bytes.Equal(block.Header.DataHash, pohProof.Entry.Hash) {
+		return nil, fmt.Errorf("block data hash does not match PoH entry %d", pohProof.Entry.Index)
+	}
+
+	// Light clients trust the signers through the header's validator hash
+	if !bytes.Equal(block.Header.ValidatorsHash, ValidatorSetHash(c.ValidatorSet)) {
+		return nil, fmt.Errorf("block validators hash does not match the validator set")
+	}
+
+	// The block must extend the last committed block
+	var parent []byte
+	if c.CurrentBlock != nil {
+		parent = c.CurrentBlock.Header.Hash()
+	}
+	if !bytes.Equal(block.Header.LastBlockID.Hash, parent) {
+		return nil, fmt.Errorf("block does not extend the block at height %d", c.CurrentHeight)
+	}
+
+	// Verify the proposer won the election for the block's round
+	vrfOutput, err := c.verifyProposer(block, pohProof)
+	if err != nil {
+		return nil, fmt.Errorf("proposer verification failed: %w", err)
+	}
+
+	// The last commit drives liveness tracking, so it must be canonical
+	if block.Header.Height > 1 {
+		lastCommit := block.LastCommit
+		if lastCommit == nil || c.CurrentBlock == nil || lastCommit.Height != c.CurrentHeight ||
+			!bytes.Equal(lastCommit.BlockID.Hash, c.CurrentBlock.Header.Hash()) {
+			return nil, fmt.Errorf("last commit does not match block at height %d", c.CurrentHeight)
+		}
+		if err := VerifyCommit(c.ChainID, c.lastValidators, lastCommit); err != nil {
+			return nil, fmt.Errorf("last commit verification failed: %w", err)
+		}
+	}
+
+	evidence, err := c.blockEvidence(block)
+	if err != nil {
+		return nil, fmt.Errorf("evidence verification failed: %w", err)
+	}
+
+	return &validatedBlock{block: block, proof: pohProof, vrfOutput: vrfOutput, evidence: evidence, shards: shards}, nil
 }
 
-// FinalizeBlock achieves finality using BFT
-func (c *Consensus) FinalizeBlock(block *types.Block) error {
+// applyBlock appends a decided block to the chain and schedules the next height
+func (c *Consensus) applyBlock(validated *validatedBlock, commit *types.Commit) {
+	block := validated.block
+	height := block.Header.Height
+	validators := append([]Validator{}, c.ValidatorSet...)
+
+	// Extend the local PoH sequence and the VRF beacon
+	c.updatePoHSequence(validated.proof.Entry)
+	c.vrfBeacon = validated.vrfOutput
+
+	proposer := &c.ValidatorSet[c.validatorIndex(block.Header.Proposer)]
+	proposer.VRFProof = validated.proof.VRFProof
+	proposer.LastBlockProduced = height
+
+	c.metrics.recordBlock(block, c.CurrentBlock, commit, c.clock.Now())
+	c.CurrentHeight = height
+	c.CurrentBlock = block
+	c.Commit = commit
+
+	// Keep the precommits behind the commit for finality and rewards
 	c.muFinality.Lock()
-	defer c.muFinality.Unlock()
+	votes := make([]*types.Vote, 0, len(commit.Signatures))
+	for i, sig := range commit.Signatures {
+		if sig.BlockIDFlag == types.BlockIDFlagCommit {
+			votes = append(votes, CommitVote(commit, i))
+		}
+	}
+	c.FinalityVotes[height] = votes
+	delete(c.FinalityVotes, height-finalityVoteRetention)
+	c.muFinality.Unlock()
 
-	// 2/3 + 1 validator signatures for finality
-	requiredSignatures := (len(c.ValidatorSet) * 2 / 3) + 1
+	// Re-anchor the fork choice, rolling back a head on a losing candidate
+	reorg, err := c.forkChoice.Finalize(block)
+	if err != nil {
+		fmt.Printf("[CONSENSUS] Fork choice rejected block at height %d: %v\n", height, err)
+		c.forkChoice = NewForkChoice(block)
+	}
+	c.queueReorg(reorg)
 
-	// Check if we have enough signatures
-	currentVotes := c.FinalityVotes[block.Header.Height]
-	if len(currentVotes) >= requiredSignatures {
-		// Block is finalized
-		fmt.Printf("[CONSENSUS] Block finalized at height %d (TPS: %d)\n",
-			block.Header.Height, c.calculateTPS())
+	c.committed = append(c.committed, committedBlock{block, commit})
 
-		// Reward validators
-		c.distributeRewards(block.Header.Height)
+	fmt.Printf("[CONSENSUS] Block committed at height %d round %d\n", height, commit.Round)
 
-		// Update PoH sequence
-		c.updatePoHSequence(block)
+	// Finalize and wait for late precommits before starting the next height
+	if err := c.FinalizeBlock(block); err != nil {
+		fmt.Printf("[CONSENSUS] Finalization failed at height %d: %v\n", height, err)
+	}
 
-		return nil
+	// Pay the block reward and fees to the signers of the previous height
+	reward := c.distributeRewards(block)
+
+	// Punish misbehaviour; validator set changes apply from the next height
+	c.applyEvidence(block, validated.evidence)
+	c.applyUnjails()
+	c.completeUnbonding(block.Header.Time)
+	if height%c.EpochLength == 0 {
+		c.applyEpochChanges(height, block.Header.Time)
+		c.shuffleValidators()
 	}
+	c.applyShardBlocks(validated.shards)
+	c.lastValidators = validators
+	c.persist(block, commit, validators, reward)
+
+	// Drop the committed transactions before the next proposal reaps
+	if c.mempool != nil {
+		txs := make([][]byte, len(block.Data.Txs))
+		for i, tx := range block.Data.Txs {
+			txs[i] = tx
+		}
+		c.mempool.Update(height, txs)
+	}
+
+	// Produce this node's shard blocks early so they reach the next proposer
+	c.produceShardBlocks(height + 1)
 
-	return fmt.Errorf("insufficient signatures for finality: %d/%d",
-		len(currentVotes), requiredSignatures)
+	c.rs.height = height
+	c.rs.step = stepCommit
+	c.scheduleTimeout(c.TimeoutCommit, height, c.rs.round, stepNewHeight)
 }
 
-// SlashValidator penalizes a validator for misbehavior
-func (c *Consensus) SlashValidator(address []byte, reason string, penalty uint64) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// committedBlock is a commit event waiting to be delivered to listeners
+type committedBlock struct {
+	block  *types.Block
+	commit *types.Commit
+}
 
-	// Find validator
-	for i, val := range c.ValidatorSet {
-		if string(val.Address) == string(address) {
-			// Apply slashing
-			if penalty > val.Stake {
-				penalty = val.Stake
-			}
-			val.Stake -= penalty
-			val.Slashed = true
-
-			// Record slashing event
-			event := SlashingEvent{
-				Height:    c.CurrentHeight,
-				Reason:    reason,
-				Penalty:   penalty,
-				Timestamp: time.Now().Unix(),
-			}
-			val.SlashingEvents = append(val.SlashingEvents, event)
-
-			// Remove from validator set if stake drops below minimum
-			if val.Stake < MinStake {
-				c.ValidatorSet = append(c.ValidatorSet[:i], c.ValidatorSet[i+1:]...)
-				fmt.Printf("[CONSENSUS] Validator %x removed (below minimum stake)\n", address[:8])
-			} else {
-				c.ValidatorSet[i] = val
-			}
-
-			fmt.Printf("[CONSENSUS] Validator %x slashed: %d ZEN (%s)\n",
-				address[:8], penalty/1000000000000000000, reason)
-
-			return nil
+// FinalizeBlock achieves finality using BFT.
+// A block is final once precommits from more than 2/3 of the voting power are recorded.
+func (c *Consensus) FinalizeBlock(block *types.Block) error {
+	c.muFinality.Lock()
+	defer c.muFinality.Unlock()
+
+	height := block.Header.Height
+	hash := block.Header.Hash()
+
+	// Sum the power of precommits for this block
+	var signed int64
+	for _, vote := range c.FinalityVotes[height] {
+		if !bytes.Equal(vote.BlockID.Hash, hash) {
+			continue
+		}
+		if idx := c.validatorIndex(vote.ValidatorAddress); idx >= 0 {
+			signed += VotingPower(c.ValidatorSet[idx])
 		}
 	}
 
-	return fmt.Errorf("validator not found: %x", address[:8])
+	total := TotalVotingPower(c.ValidatorSet)
+	if hasTwoThirds(signed, total) {
+		// Block is finalized
+		fmt.Printf("[CONSENSUS] Block finalized at height %d (TPS: %.1f)\n",
+			height, c.calculateTPS())
+
+		return nil
+	}
+
+	return fmt.Errorf("insufficient voting power for finality: %d/%d", signed, total)
 }
 
 // PoHProof represents a Proof of History proof
 type PoHProof struct {
 	Entry      ProofOfHistoryEntry `json:"entry"`
 	Validator  []byte              `json:"validator"`
-	Signature  []byte              `json:"signature"`
+	VRFProof   []byte              `json:"vrf_proof"` // Seeds the next height's election
+	Round      int32               `json:"round"`     // Round the block was first proposed in
+	Signature  []byte              `json:"signature"` // Proposer's ed25519 signature
 	Timestamp  int64               `json:"timestamp"`
 }
 
 // initializePoH creates the initial PoH sequence
 func (c *Consensus) initializePoH() error {
-	// Genesis entry
+	if len(c.PoHSequence) > 0 {
+		// Already initialized, continue from the last entry
+		c.poh = NewPoHGenerator(c.PoHSequence[len(c.PoHSequence)-1], c.HashesPerTick, c.TicksPerEntry)
+		if c.vrfBeacon == nil {
+			c.vrfBeacon = c.PoHSequence[0].Hash
+		}
+		return nil
+	}
+
+	// Genesis entry (hash must be identical on every node)
+	genesisHash := sha256.Sum256([]byte("zen-network-genesis"))
 	genesis := ProofOfHistoryEntry{
 		Index:         0,
-		Hash:          []byte("genesis"),
+		Hash:          genesisHash[:],
 		PreviousHash:  []byte{},
-		Timestamp:     time.Now().Unix(),
+		Timestamp:     c.clock.Now().Unix(),
 		EntryData:     []byte("zen-network-genesis"),
 	}
 	c.PoHSequence = append(c.PoHSequence, genesis)
+	c.poh = NewPoHGenerator(genesis, c.HashesPerTick, c.TicksPerEntry)
+	c.vrfBeacon = genesis.Hash // Seeds the first election
 
 	fmt.Println("[CONSENSUS] PoH initialized with genesis entry")
 	return nil
 }
 
-// shuffleValidators creates consensus committees
-func (c *Consensus) shuffleValidators() {
-	totalValidators := len(c.ValidatorSet)
-	if totalValidators == 0 {
-		return
+// getPoHEntry generates the PoH entry for the next height.
+// The entry extends the last committed entry, mixing in the shard block hashes;
+// it only joins the sequence once the block carrying it is committed.
+func (c *Consensus) getPoHEntry(height int64, shardHashes [][]byte) (*ProofOfHistoryEntry, error) {
+	if len(c.PoHSequence) == 0 || c.poh == nil {
+		return nil, fmt.Errorf("PoH not initialized")
 	}
 
-	// Create 64 committees (one per shard)
-	c.Committees = make([]Committee, 64)
-
-	validatorsPerShard := totalValidators / 64
-	if validatorsPerShard == 0 {
-		validatorsPerShard = 1
+	next := int64(len(c.PoHSequence))
+	if height < next {
+		return nil, fmt.Errorf("height %d already committed (next: %d)", height, next)
 	}
-
-	for shardID := 0; shardID < 64; shardID++ {
-		committee := Committee{
-			ID:          uint64(shardID),
-			Shuffled:    true,
-			PoHSequence: uint64(shardID),
-		}
-
-		start := shardID * validatorsPerShard
-		end := start + validatorsPerShard
-		if end > totalValidators {
-			end = totalValidators
-		}
-		if start >= totalValidators {
-			start = 0
-			end = 0
-		}
-
-		committee.Validators = c.ValidatorSet[start:end]
-		c.Committees[shardID] = committee
+	if height > next {
+		return nil, fmt.Errorf("PoH entry for height %d unavailable (next: %d)", height, next)
 	}
 
-	fmt.Printf("[CONSENSUS] Created %d committees (%d validators/shard)\n",
-		len(c.Committees), validatorsPerShard)
+	// Generate new entry on top of the committed tip
+	c.poh.Reset(c.PoHSequence[next-1])
+	entry := c.poh.Record(shardHashes, c.clock.Now().Unix())
+	return &entry, nil
 }
 
-// blockProductionLoop manages continuous block production
-func (c *Consensus) blockProductionLoop() {
-	ticker := time.NewTicker(time.Millisecond * BlockTime)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			c.mu.Lock()
-			height := c.CurrentHeight + 1
-			c.mu.Unlock()
+// verifyPoHProof verifies a PoH proof against the local sequence and the
+// block's shard blocks
+func (c *Consensus) verifyPoHProof(block *types.Block, shards []*ShardBlock) (PoHProof, error) {
+	// Check if block has PoH extension
+	if len(block.Data.Extensions) == 0 || block.Data.Extensions[0].Index != extensionPoHProof {
+		return PoHProof{}, fmt.Errorf("missing PoH proof")
+	}
 
-			// Get transactions from mempool
-			// In production: get from network module
-			txs := make([][]byte, 0)
-
-			// Produce block
-			if block, err := c.ProduceBlock(height, txs); err == nil {
-				// Commit block
-				if err := c.CommitBlock(block); err == nil {
-					// Finalize
-					c.FinalizeBlock(block)
-				}
-			}
-		}
+	// Decode the proof; its signature is checked with the proposer
+	pohProof := PoHProof{}
+	if err := json.Unmarshal(block.Data.Extensions[0].Bytes, &pohProof); err != nil {
+		return PoHProof{}, fmt.Errorf("failed to unmarshal PoH proof: %w", err)
 	}
-}
+	entry := pohProof.Entry
 
-// getPoHEntry retrieves a PoH entry for given height
-func (c *Consensus) getPoHEntry(height int64) (*ProofOfHistoryEntry, error) {
-	if height < 0 || height >= int64(len(c.PoHSequence)) {
-		// Generate new entry
-		prevEntry := c.PoHSequence[len(c.PoHSequence)-1]
-		entry := ProofOfHistoryEntry{
-			Index:         prevEntry.Index + 1,
-			Hash:          c.hashEntry(prevEntry, height),
-			PreviousHash:  prevEntry.Hash,
-			Timestamp:     time.Now().Unix(),
-			EntryData:     []byte{},
-		}
-		c.PoHSequence = append(c.PoHSequence, entry)
-		return &entry, nil
+	// The entry must extend the last committed entry
+	if entry.Index == 0 || entry.Index != uint64(len(c.PoHSequence)) {
+		return PoHProof{}, fmt.Errorf("PoH entry %d does not extend sequence (length %d)",
+			entry.Index, len(c.PoHSequence))
+	}
+	if entry.Index != uint64(block.Header.Height) {
+		return PoHProof{}, fmt.Errorf("PoH entry %d does not match block height %d",
+			entry.Index, block.Header.Height)
 	}
 
-	return &c.PoHSequence[height], nil
-}
+	// Verify hash chain
+	prev := c.PoHSequence[entry.Index-1]
+	if err := VerifyPoHEntry(prev, entry, c.HashesPerTick, c.TicksPerEntry); err != nil {
+		return PoHProof{}, err
+	}
 
-// hashEntry creates a hash for PoH entry
-func (c *Consensus) hashEntry(prev ProofOfHistoryEntry, height int64) []byte {
-	// Combine previous hash, height, and timestamp
-	data := append(prev.Hash, make([]byte, 8)...)
-	binary.BigEndian.PutUint64(data[len(prev.Hash):], uint64(height))
-	data = append(data, make([]byte, 8)...)
-	binary.BigEndian.PutInt64(data[len(data)-8:], time.Now().Unix())
+	// Verify the mixin commits to the shard blocks, and through them the txs
+	if !bytes.Equal(entry.EntryData, MixinHash(ShardHashes(shards))) {
+		return PoHProof{}, fmt.Errorf("PoH entry %d mixin does not match the shard blocks", entry.Index)
+	}
 
-	hash := sha256.Sum256(data)
-	return hash[:]
+	return pohProof, nil
 }
 
-// selectProposer chooses the block proposer
-func (c *Consensus) selectProposer(height int64, pohEntry *ProofOfHistoryEntry) ([]byte, error) {
-	if len(c.ValidatorSet) == 0 {
-		return nil, fmt.Errorf("no validators available")
+// updatePoHSequence appends a verified entry to the PoH sequence
+func (c *Consensus) updatePoHSequence(entry ProofOfHistoryEntry) {
+	if entry.Index != uint64(len(c.PoHSequence)) {
+		return
 	}
 
-	// Use PoH sequence to pseudo-randomly select validator
-	// In production: use VRF for unbiasable randomness
-	validatorIndex := pohEntry.Index % uint64(len(c.ValidatorSet))
-	return c.ValidatorSet[validatorIndex].Address, nil
+	c.PoHSequence = append(c.PoHSequence, entry)
+	if c.poh != nil {
+		c.poh.Reset(entry)
+	}
 }
 
-// verifyPoHProof verifies a PoH proof
-func (c *Consensus) verifyPoHProof(block *types.Block) error {
-	// Check if block has PoH extension
-	if len(block.Data.Extensions) == 0 {
-		return fmt.Errorf("missing PoH proof")
+// VerifyPoHSequence verifies entries received from a peer against the local sequence
+func (c *Consensus) VerifyPoHSequence(entries []ProofOfHistoryEntry) error {
+	if len(entries) == 0 {
+		return nil
 	}
 
-	// Verify the proof
-	// In production: verify actual signature
-	pohProof := PoHProof{}
-	if err := json.Unmarshal(block.Data.Extensions[0].Bytes, &pohProof); err != nil {
-		return fmt.Errorf("failed to unmarshal PoH proof: %w", err)
+	c.mu.RLock()
+	first := entries[0].Index
+	if first == 0 || first > uint64(len(c.PoHSequence)) {
+		c.mu.RUnlock()
+		return fmt.Errorf("no local PoH entry before index %d", first)
 	}
+	start := c.PoHSequence[first-1]
+	hashesPerTick, ticksPerEntry := c.HashesPerTick, c.TicksPerEntry
+	c.mu.RUnlock()
 
-	// Verify hash chain
-	// In production: complete verification
-	return nil
+	return VerifyPoHEntries(start, entries, hashesPerTick, ticksPerEntry)
 }
 
-// updatePoHSequence updates the PoH sequence
-func (c *Consensus) updatePoHSequence(block *types.Block) {
-	// Add to sequence if not present
-	if block.Header.Height >= int64(len(c.PoHSequence)) {
-		entry := ProofOfHistoryEntry{
-			Index:         uint64(block.Header.Height),
-			Hash:          block.Header.Hash(),
-			PreviousHash:  c.CurrentBlock.Header.Hash(),
-			Timestamp:     time.Now().Unix(),
-			EntryData:     block.Data.TxsHash,
-		}
-		c.PoHSequence = append(c.PoHSequence, entry)
-	}
+// calculateTPS returns the transactions per second measured over the
+// metrics window
+func (c *Consensus) calculateTPS() float64 {
+	return c.metrics.Snapshot().TPS
 }
 
-// calculateTPS calculates current transactions per second
-func (c *Consensus) calculateTPS() int {
-	// Simplified TPS calculation
-	// In production: actual measurement
-	return TargetTPS
+// getTotalStake calculates total staked amount
+func (c *Consensus) getTotalStake() *big.Int {
+	total := new(big.Int)
+	for _, val := range c.ValidatorSet {
+		total.Add(total, orZero(val.Stake))
+	}
+	return total
 }
 
-// distributeRewards distributes block rewards to validators
-func (c *Consensus) distributeRewards(height int64) {
-	// Calculate total reward from halving module
-	// In production: integrate with halving module
-	totalReward := uint64(1000000000000000000) // 1 ZEN for example
+// Head returns the block the fork choice rule currently selects: the
+// heaviest attested candidate for the next height, or the last committed
+// block if there is none
+func (c *Consensus) Head() *types.Block {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	// Distribute to validators based on stake
-	for i, val := range c.ValidatorSet {
-		stakeRatio := float64(val.Stake) / float64(c.getTotalStake())
-		reward := uint64(float64(totalReward) * stakeRatio)
-		c.ValidatorSet[i].Reward += reward
-	}
+	return c.forkChoice.Head()
 }
 
-// getTotalStake calculates total staked amount
-func (c *Consensus) getTotalStake() uint64 {
-	var total uint64
-	for _, val := range c.ValidatorSet {
-		total += val.Stake
-	}
-	return total
+// Height returns the last committed height
+func (c *Consensus) Height() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.CurrentHeight
 }
 
 // GetStatus returns current consensus status
@@ -540,15 +871,21 @@ func (c *Consensus) GetStatus() map[string]interface{} {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	measured := c.metrics.Snapshot()
 	return map[string]interface{}{
 		"height":         c.CurrentHeight,
+		"round":          c.rs.round,
+		"epoch":          c.CurrentHeight / c.EpochLength,
+		"step":           c.rs.step.String(),
 		"validators":     len(c.ValidatorSet),
 		"committees":     len(c.Committees),
 		"consensus_type": c.ConsensusType,
-		"block_time":     BlockTime,
-		"finality_time":  FinalityTime,
+		"block_time":     measured.BlockInterval.Milliseconds(),
+		"finality_time":  measured.Finality.Milliseconds(),
+		"tps":            measured.TPS,
+		"rounds":         measured.Rounds,
 		"target_tps":     TargetTPS,
 		"max_tps":        MaxTPS,
-		"total_stake":    c.getTotalStake() / 1000000000000000000,
+		"total_stake":    toZEN(c.getTotalStake()),
 	}
 }
@@ -0,0 +1,512 @@
+package consensus
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/tendermint/tendermint/types"
+)
+
+// Infraction identifies a slashable offence
+type Infraction string
+
+const (
+	InfractionDoubleSign Infraction = "double_sign" // Conflicting votes in one round
+	InfractionDowntime   Infraction = "downtime"    // Too many missed blocks in the window
+)
+
+// Block extension indexes
+const (
+	extensionPoHProof    = 0
+	extensionEvidence    = 1
+	extensionShardBlocks = 2
+)
+
+// EvidenceParams bound which evidence is still admissible.
+// Evidence expires once it is older than both limits.
+type EvidenceParams struct {
+	MaxAgeNumBlocks int64         `json:"max_age_num_blocks"`
+	MaxAgeDuration  time.Duration `json:"max_age_duration"`
+	MaxPerBlock     int           `json:"max_per_block"`
+}
+
+// DefaultEvidenceParams matches the genesis consensus_params.evidence section
+func DefaultEvidenceParams() EvidenceParams {
+	return EvidenceParams{
+		MaxAgeNumBlocks: 100000,
+		MaxAgeDuration:  48 * time.Hour,
+		MaxPerBlock:     50,
+	}
+}
+
+// SlashingParams sets penalties per offence, in basis points of stake
+type SlashingParams struct {
+	SignedBlocksWindow    int64         `json:"signed_blocks_window"`
+	MinSignedPerWindowBps uint64        `json:"min_signed_per_window_bps"`
+	DowntimeJailDuration  time.Duration `json:"downtime_jail_duration"`
+	DoubleSignSlashBps    uint64        `json:"double_sign_slash_bps"`
+	DowntimeSlashBps      uint64        `json:"downtime_slash_bps"`
+}
+
+// DefaultSlashingParams returns the default slashing parameters
+func DefaultSlashingParams() SlashingParams {
+	return SlashingParams{
+		SignedBlocksWindow:    100,
+		MinSignedPerWindowBps: 5000, // 50%
+		DowntimeJailDuration:  10 * time.Minute,
+		DoubleSignSlashBps:    500, // 5%
+		DowntimeSlashBps:      1,   // 0.01%
+	}
+}
+
+// DowntimeEvidence records a validator missing too many commits in a window.
+// Peers can check MissedHeights against the canonical commits they stored.
+type DowntimeEvidence struct {
+	Validator     []byte  `json:"validator"`
+	StartHeight   int64   `json:"start_height"`
+	EndHeight     int64   `json:"end_height"`
+	MissedHeights []int64 `json:"missed_heights"`
+}
+
+// Evidence is misbehaviour proof gossiped between validators
+type Evidence struct {
+	DuplicateVote *types.DuplicateVoteEvidence `json:"duplicate_vote,omitempty"`
+	Downtime      *DowntimeEvidence            `json:"downtime,omitempty"`
+}
+
+// signingInfo tracks the missed commits of a validator within the window
+type signingInfo struct {
+	StartHeight int64   `json:"start_height"`
+	Missed      []int64 `json:"missed"`
+}
+
+// SlashValidator penalizes a validator for an infraction and jails it.
+// Double signing jails permanently; downtime jails for DowntimeJailDuration.
+func (c *Consensus) SlashValidator(address []byte, infraction Infraction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.slashValidator(address, infraction, c.CurrentHeight, c.clock.Now())
+}
+
+// Unjail lets a jailed validator back in once its jail time has passed.
+// The change takes effect at the next block boundary.
+func (c *Consensus) Unjail(address []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	idx := c.validatorIndex(address)
+	if idx < 0 {
+		return fmt.Errorf("validator not found: %x", address)
+	}
+	val := c.ValidatorSet[idx]
+
+	switch {
+	case !val.Jailed:
+		return fmt.Errorf("validator %x is not jailed", address)
+	case val.Tombstoned:
+		return fmt.Errorf("validator %x is permanently jailed for double signing", address)
+	case c.clock.Now().Unix() < val.JailedUntil:
+		return fmt.Errorf("validator %x is jailed until %s", address, time.Unix(val.JailedUntil, 0))
+	case orZero(val.Stake).Cmp(MinStake) < 0:
+		return fmt.Errorf("validator %x stake below minimum", address)
+	}
+
+	c.unjailQueue = append(c.unjailQueue, address)
+	return nil
+}
+
+// GetValidator returns a copy of a validator in the set
+func (c *Consensus) GetValidator(address []byte) (Validator, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	idx := c.validatorIndex(address)
+	if idx < 0 {
+		return Validator{}, fmt.Errorf("validator not found: %x", address)
+	}
+	return c.ValidatorSet[idx], nil
+}
+
+// GetEvidence returns evidence waiting to be included in a block
+func (c *Consensus) GetEvidence() []Evidence {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return append([]Evidence{}, c.pendingEvidence...)
+}
+
+// slashValidator applies the penalty for an infraction at a height
+func (c *Consensus) slashValidator(address []byte, infraction Infraction, height int64, now time.Time) error {
+	idx := c.validatorIndex(address)
+	if idx < 0 {
+		return fmt.Errorf("validator not found: %x", address)
+	}
+	val := &c.ValidatorSet[idx]
+
+	var bps uint64
+	switch infraction {
+	case InfractionDoubleSign:
+		bps = c.SlashingParams.DoubleSignSlashBps
+		val.Tombstoned = true
+	case InfractionDowntime:
+		bps = c.SlashingParams.DowntimeSlashBps
+		val.JailedUntil = now.Add(c.SlashingParams.DowntimeJailDuration).Unix()
+	default:
+		return fmt.Errorf("unknown infraction: %s", infraction)
+	}
+
+	stake := orZero(val.Stake)
+	penalty := bpsOf(stake, bps)
+	if penalty.Cmp(stake) > 0 {
+		penalty.Set(stake)
+	}
+	val.Stake = new(big.Int).Sub(stake, penalty)
+	val.Power = StakeToPower(val.Stake)
+	penalty.Add(penalty, c.slashUnbonding(address, bps))
+	val.Slashed = true
+	val.Jailed = true
+
+	val.SlashingEvents = append(val.SlashingEvents, SlashingEvent{
+		Height:    height,
+		Reason:    string(infraction),
+		Penalty:   penalty,
+		Timestamp: now.Unix(),
+	})
+
+	fmt.Printf("[CONSENSUS] Validator %x slashed and jailed: %s ZEN (%s)\n",
+		address[:8], toZEN(penalty), infraction)
+
+	return nil
+}
+
+// newDuplicateVoteEvidence builds evidence from two conflicting votes
+func (c *Consensus) newDuplicateVoteEvidence(voteA, voteB *types.Vote) Evidence {
+	// Order votes by block hash so every node builds identical evidence
+	if bytes.Compare(voteA.BlockID.Hash, voteB.BlockID.Hash) > 0 {
+		voteA, voteB = voteB, voteA
+	}
+
+	timestamp := voteA.Timestamp
+	if voteB.Timestamp.Before(timestamp) {
+		timestamp = voteB.Timestamp
+	}
+
+	var power int64
+	if idx := c.validatorIndex(voteA.ValidatorAddress); idx >= 0 {
+		power = VotingPower(c.ValidatorSet[idx])
+	}
+
+	return Evidence{DuplicateVote: &types.DuplicateVoteEvidence{
+		VoteA:            voteA,
+		VoteB:            voteB,
+		TotalVotingPower: c.totalPower(),
+		ValidatorPower:   power,
+		Timestamp:        timestamp,
+	}}
+}
+
+// reportEvidence records locally detected evidence and gossips it
+func (c *Consensus) reportEvidence(ev Evidence) {
+	if err := c.addEvidence(ev); err != nil {
+		return
+	}
+	c.send(ConsensusMessage{Evidence: &ev})
+}
+
+// handleEvidence processes evidence gossiped by a peer
+func (c *Consensus) handleEvidence(ev *Evidence) error {
+	if ev.Downtime != nil {
+		// Downtime is slashed from canonical commits on every node;
+		// peers' records are only checked and kept for auditing
+		if err := c.verifyDowntimeEvidence(ev.Downtime); err != nil {
+			return err
+		}
+		c.recordEvidence(*ev)
+		return nil
+	}
+
+	if err := c.addEvidence(*ev); err != nil {
+		return err
+	}
+	c.send(ConsensusMessage{Evidence: ev})
+	return nil
+}
+
+// addEvidence verifies duplicate vote evidence and adds it to the pending pool
+func (c *Consensus) addEvidence(ev Evidence) error {
+	if err := c.verifyEvidence(ev); err != nil {
+		return err
+	}
+
+	key := evidenceKey(ev)
+	for _, pending := range c.pendingEvidence {
+		if evidenceKey(pending) == key {
+			return fmt.Errorf("duplicate evidence")
+		}
+	}
+
+	c.pendingEvidence = append(c.pendingEvidence, ev)
+	fmt.Printf("[CONSENSUS] Evidence of double signing by %x at height %d\n",
+		ev.DuplicateVote.VoteA.ValidatorAddress, ev.DuplicateVote.VoteA.Height)
+	return nil
+}
+
+// verifyEvidence checks that duplicate vote evidence is valid, unexpired and not yet punished
+func (c *Consensus) verifyEvidence(ev Evidence) error {
+	dve := ev.DuplicateVote
+	if dve == nil || dve.VoteA == nil || dve.VoteB == nil {
+		return fmt.Errorf("malformed evidence")
+	}
+	a, b := dve.VoteA, dve.VoteB
+
+	if a.Height != b.Height || a.Round != b.Round || a.Type != b.Type {
+		return fmt.Errorf("evidence votes are for different rounds")
+	}
+	if !bytes.Equal(a.ValidatorAddress, b.ValidatorAddress) {
+		return fmt.Errorf("evidence votes are from different validators")
+	}
+	if bytes.Equal(a.BlockID.Hash, b.BlockID.Hash) {
+		return fmt.Errorf("evidence votes do not conflict")
+	}
+
+	// Expired only when older than both the block and the time limit
+	params := c.EvidenceParams
+	now := c.now()
+	if c.CurrentHeight-a.Height > params.MaxAgeNumBlocks && now.Sub(dve.Timestamp) > params.MaxAgeDuration {
+		return fmt.Errorf("evidence from height %d expired", a.Height)
+	}
+	if _, ok := c.committedEvidence[evidenceKey(ev)]; ok {
+		return fmt.Errorf("evidence already committed")
+	}
+
+	idx := c.validatorIndex(a.ValidatorAddress)
+	if idx < 0 {
+		return fmt.Errorf("evidence against unknown validator %x", a.ValidatorAddress)
+	}
+	pubKey := c.ValidatorSet[idx].PubKey
+	if err := VerifyVoteSignature(c.ChainID, pubKey, a); err != nil {
+		return err
+	}
+	return VerifyVoteSignature(c.ChainID, pubKey, b)
+}
+
+// verifyDowntimeEvidence checks a downtime record against stored canonical commits
+func (c *Consensus) verifyDowntimeEvidence(ev *DowntimeEvidence) error {
+	window := c.SlashingParams.SignedBlocksWindow
+	if ev.EndHeight-ev.StartHeight+1 < window || int64(len(ev.MissedHeights)) <= window-c.minSignedPerWindow() {
+		return fmt.Errorf("downtime record below the slashing threshold")
+	}
+
+	for _, height := range ev.MissedHeights {
+		if height < ev.StartHeight || height > ev.EndHeight {
+			return fmt.Errorf("missed height %d outside window", height)
+		}
+		commit, ok := c.canonicalCommits[height]
+		if !ok {
+			continue // Pruned or not yet seen
+		}
+		for _, sig := range commit.Signatures {
+			if sig.BlockIDFlag == types.BlockIDFlagCommit && bytes.Equal(sig.ValidatorAddress, ev.Validator) {
+				return fmt.Errorf("validator %x signed the commit at height %d", ev.Validator, height)
+			}
+		}
+	}
+
+	return nil
+}
+
+// recordEvidence keeps evidence in the audit log
+func (c *Consensus) recordEvidence(ev Evidence) {
+	c.evidenceLog = append(c.evidenceLog, ev)
+	if len(c.evidenceLog) > evidenceLogSize {
+		c.evidenceLog = c.evidenceLog[len(c.evidenceLog)-evidenceLogSize:]
+	}
+}
+
+// evidenceLogSize bounds the audit log of processed evidence
+const evidenceLogSize = 1000
+
+// reapEvidence returns the pending evidence to include in a proposal
+func (c *Consensus) reapEvidence() []Evidence {
+	evidence := make([]Evidence, 0, len(c.pendingEvidence))
+	for _, ev := range c.pendingEvidence {
+		if len(evidence) >= c.EvidenceParams.MaxPerBlock {
+			break
+		}
+		if c.verifyEvidence(ev) == nil {
+			evidence = append(evidence, ev)
+		}
+	}
+	return evidence
+}
+
+// blockEvidence decodes and verifies the evidence carried by a block
+func (c *Consensus) blockEvidence(block *types.Block) ([]Evidence, error) {
+	var evidence []Evidence
+	for _, ext := range block.Data.Extensions {
+		if ext.Index != extensionEvidence {
+			continue
+		}
+		if err := json.Unmarshal(ext.Bytes, &evidence); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal evidence: %w", err)
+		}
+	}
+
+	if !bytes.Equal(block.Header.EvidenceHash, evidenceHash(evidence)) {
+		return nil, fmt.Errorf("block evidence does not match header")
+	}
+	if len(evidence) > c.EvidenceParams.MaxPerBlock {
+		return nil, fmt.Errorf("block carries %d evidence, max %d", len(evidence), c.EvidenceParams.MaxPerBlock)
+	}
+
+	seen := make(map[string]bool)
+	for _, ev := range evidence {
+		if ev.DuplicateVote == nil {
+			return nil, fmt.Errorf("only duplicate vote evidence is committed in blocks")
+		}
+		if err := c.verifyEvidence(ev); err != nil {
+			return nil, err
+		}
+		key := evidenceKey(ev)
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate evidence in block")
+		}
+		seen[key] = true
+	}
+
+	return evidence, nil
+}
+
+// applyEvidence slashes double signers in a committed block and updates liveness
+// from the block's canonical LastCommit
+func (c *Consensus) applyEvidence(block *types.Block, evidence []Evidence) {
+	height := block.Header.Height
+	now := block.Header.Time
+
+	// Double signing
+	for _, ev := range evidence {
+		c.committedEvidence[evidenceKey(ev)] = height
+		if err := c.slashValidator(ev.DuplicateVote.VoteA.ValidatorAddress, InfractionDoubleSign, height, now); err != nil {
+			fmt.Printf("[CONSENSUS] Failed to slash double signer: %v\n", err)
+		}
+		c.recordEvidence(ev)
+	}
+
+	// Forget committed evidence once it would have expired anyway
+	for key, h := range c.committedEvidence {
+		if height-h > c.EvidenceParams.MaxAgeNumBlocks {
+			delete(c.committedEvidence, key)
+		}
+	}
+
+	// Drop pending evidence that was committed or expired
+	pending := c.pendingEvidence[:0]
+	for _, ev := range c.pendingEvidence {
+		if c.verifyEvidence(ev) == nil {
+			pending = append(pending, ev)
+		}
+	}
+	c.pendingEvidence = pending
+
+	// Downtime, judged from the commit for the previous height
+	if block.LastCommit != nil {
+		c.trackLiveness(block.LastCommit, now)
+	}
+}
+
+// trackLiveness updates missed-block windows from a canonical commit
+func (c *Consensus) trackLiveness(commit *types.Commit, now time.Time) {
+	window := c.SlashingParams.SignedBlocksWindow
+	c.canonicalCommits[commit.Height] = commit
+	delete(c.canonicalCommits, commit.Height-window)
+
+	signed := make(map[string]bool)
+	for _, sig := range commit.Signatures {
+		if sig.BlockIDFlag == types.BlockIDFlagCommit {
+			signed[hex.EncodeToString(sig.ValidatorAddress)] = true
+		}
+	}
+
+	for _, val := range c.lastValidators {
+		if VotingPower(val) == 0 {
+			continue
+		}
+
+		key := hex.EncodeToString(val.Address)
+		info, ok := c.signingInfos[key]
+		if !ok {
+			info = &signingInfo{StartHeight: commit.Height}
+			c.signingInfos[key] = info
+		}
+
+		// Drop misses that left the window
+		for len(info.Missed) > 0 && info.Missed[0] <= commit.Height-window {
+			info.Missed = info.Missed[1:]
+		}
+		if !signed[key] {
+			info.Missed = append(info.Missed, commit.Height)
+		}
+
+		if commit.Height-info.StartHeight+1 < window || int64(len(info.Missed)) <= window-c.minSignedPerWindow() {
+			continue
+		}
+
+		ev := Evidence{Downtime: &DowntimeEvidence{
+			Validator:     val.Address,
+			StartHeight:   commit.Height - window + 1,
+			EndHeight:     commit.Height,
+			MissedHeights: append([]int64{}, info.Missed...),
+		}}
+		if err := c.slashValidator(val.Address, InfractionDowntime, commit.Height, now); err != nil {
+			fmt.Printf("[CONSENSUS] Failed to slash offline validator: %v\n", err)
+			continue
+		}
+		c.recordEvidence(ev)
+		c.send(ConsensusMessage{Evidence: &ev})
+		delete(c.signingInfos, key)
+	}
+}
+
+// applyUnjails returns queued validators to the active set
+func (c *Consensus) applyUnjails() {
+	for _, address := range c.unjailQueue {
+		if idx := c.validatorIndex(address); idx >= 0 {
+			c.ValidatorSet[idx].Jailed = false
+			c.ValidatorSet[idx].JailedUntil = 0
+			fmt.Printf("[CONSENSUS] Validator %x unjailed\n", address[:8])
+		}
+	}
+	c.unjailQueue = nil
+}
+
+// minSignedPerWindow returns how many blocks a validator must sign per window
+func (c *Consensus) minSignedPerWindow() int64 {
+	return c.SlashingParams.SignedBlocksWindow * int64(c.SlashingParams.MinSignedPerWindowBps) / 10000
+}
+
+// evidenceKey identifies the offence behind a piece of evidence
+func evidenceKey(ev Evidence) string {
+	if ev.DuplicateVote == nil || ev.DuplicateVote.VoteA == nil {
+		return ""
+	}
+	vote := ev.DuplicateVote.VoteA
+
+	return fmt.Sprintf("%x/%d/%d/%d", vote.ValidatorAddress, vote.Height, vote.Round, vote.Type)
+}
+
+// evidenceHash commits to the evidence carried by a block
+func evidenceHash(evidence []Evidence) []byte {
+	if len(evidence) == 0 {
+		return nil
+	}
+
+	bz, _ := json.Marshal(evidence)
+	sum := sha256.Sum256(bz)
+	return sum[:]
+}
@@ -0,0 +1,266 @@
+package consensus
+
+import (
+	"bytes"
+	"fmt"
+
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	"github.com/tendermint/tendermint/types"
+)
+
+// ReorgEvent reports a fork choice head moving to a block that does not
+// descend from the previous head. Non-final state built on the rolled back
+// blocks must be reverted to the common ancestor.
+type ReorgEvent struct {
+	OldHead        *types.Block
+	NewHead        *types.Block
+	CommonAncestor *types.Block   // nil for the genesis anchor
+	RolledBack     []*types.Block // Blocks leaving the head branch, newest first
+	Applied        []*types.Block // Blocks joining the head branch, oldest first
+}
+
+// Depth returns the number of blocks rolled back
+func (e *ReorgEvent) Depth() int {
+	return len(e.RolledBack)
+}
+
+// AncestorHeight returns the height state is rolled back to
+func (e *ReorgEvent) AncestorHeight() int64 {
+	if e.CommonAncestor == nil {
+		return 0
+	}
+	return e.CommonAncestor.Header.Height
+}
+
+// forkNode is a block in the fork choice tree
+type forkNode struct {
+	block    *types.Block // nil for the genesis anchor
+	hash     []byte
+	height   int64
+	parent   *forkNode
+	children []*forkNode
+}
+
+// attestation is the latest prevote or precommit of a validator
+type attestation struct {
+	hash   string
+	height int64
+	round  int32
+	step   roundStep
+	power  int64
+}
+
+// newerThan orders a validator's votes by height, round and step
+func (a attestation) newerThan(b attestation) bool {
+	if a.height != b.height {
+		return a.height > b.height
+	}
+	if a.round != b.round {
+		return a.round > b.round
+	}
+	return a.step > b.step
+}
+
+// ForkChoice is a tree of candidate blocks anchored at the last finalized
+// block. The head is found by descending from the anchor into the child
+// whose subtree holds the most voting power among the validators' latest
+// prevotes and precommits, breaking ties by the lowest hash. It is not safe
+// for concurrent use; Consensus guards it with its lock.
+type ForkChoice struct {
+	root   *forkNode
+	head   *forkNode
+	nodes  map[string]*forkNode
+	latest map[string]attestation // Validator address -> latest vote
+}
+
+// NewForkChoice creates a tree anchored at a finalized block, or at
+// genesis if root is nil
+func NewForkChoice(root *types.Block) *ForkChoice {
+	node := &forkNode{block: root}
+	if root != nil {
+		node.hash = root.Header.Hash()
+		node.height = root.Header.Height
+	}
+	return &ForkChoice{
+		root:   node,
+		head:   node,
+		nodes:  map[string]*forkNode{string(node.hash): node},
+		latest: make(map[string]attestation),
+	}
+}
+
+// Root returns the finalized anchor, nil at genesis
+func (f *ForkChoice) Root() *types.Block {
+	return f.root.block
+}
+
+// Head returns the block the fork choice rule selects
+func (f *ForkChoice) Head() *types.Block {
+	return f.head.block
+}
+
+// Has reports whether a block is in the tree
+func (f *ForkChoice) Has(hash []byte) bool {
+	_, ok := f.nodes[string(hash)]
+	return ok
+}
+
+// Weight returns the voting power attesting to a block or its descendants
+func (f *ForkChoice) Weight(hash []byte) int64 {
+	node, ok := f.nodes[string(hash)]
+	if !ok {
+		return 0
+	}
+	return f.weights()[node]
+}
+
+// AddBlock inserts a validated block whose parent is in the tree
+func (f *ForkChoice) AddBlock(block *types.Block) (*ReorgEvent, error) {
+	if _, err := f.addNode(block); err != nil {
+		return nil, err
+	}
+	return f.updateHead(), nil
+}
+
+// Attest records a validator's vote if it is newer than its last one. A
+// nil vote withdraws the validator's weight from every branch.
+func (f *ForkChoice) Attest(vote *types.Vote, power int64) *ReorgEvent {
+	if vote.Height <= f.root.height {
+		return nil
+	}
+
+	step := stepPrevote
+	if vote.Type == tmproto.PrecommitType {
+		step = stepPrecommit
+	}
+	att := attestation{
+		hash:   string(vote.BlockID.Hash),
+		height: vote.Height,
+		round:  vote.Round,
+		step:   step,
+		power:  power,
+	}
+	key := string(vote.ValidatorAddress)
+	if last, ok := f.latest[key]; ok && !att.newerThan(last) {
+		return nil
+	}
+	f.latest[key] = att
+	return f.updateHead()
+}
+
+// Finalize re-anchors the tree at a committed block, pruning every branch
+// that does not descend from it and the votes it settled
+func (f *ForkChoice) Finalize(block *types.Block) (*ReorgEvent, error) {
+	node, err := f.addNode(block)
+	if err != nil {
+		return nil, err
+	}
+	if node == f.root {
+		return nil, nil
+	}
+
+	f.root = node
+	f.nodes = make(map[string]*forkNode)
+	pending := []*forkNode{node}
+	for len(pending) > 0 {
+		n := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		f.nodes[string(n.hash)] = n
+		pending = append(pending, n.children...)
+	}
+	for key, att := range f.latest {
+		if att.height <= node.height {
+			delete(f.latest, key)
+		}
+	}
+
+	// The old head still links back to the common ancestor
+	reorg := f.updateHead()
+	node.parent = nil
+	return reorg, nil
+}
+
+// addNode inserts a block under its parent, returning the existing node if
+// it is already known
+func (f *ForkChoice) addNode(block *types.Block) (*forkNode, error) {
+	if block == nil || block.Header == nil {
+		return nil, fmt.Errorf("missing block header")
+	}
+	hash := block.Header.Hash()
+	if node, ok := f.nodes[string(hash)]; ok {
+		return node, nil
+	}
+
+	parent, ok := f.nodes[string(block.Header.LastBlockID.Hash)]
+	if !ok {
+		return nil, fmt.Errorf("block at height %d does not extend the fork choice tree", block.Header.Height)
+	}
+	if block.Header.Height != parent.height+1 {
+		return nil, fmt.Errorf("block at height %d has a parent at height %d", block.Header.Height, parent.height)
+	}
+
+	node := &forkNode{block: block, hash: hash, height: block.Header.Height, parent: parent}
+	parent.children = append(parent.children, node)
+	f.nodes[string(hash)] = node
+	return node, nil
+}
+
+// weights sums the latest attestations into every block they build on
+func (f *ForkChoice) weights() map[*forkNode]int64 {
+	weights := make(map[*forkNode]int64)
+	for _, att := range f.latest {
+		for n := f.nodes[att.hash]; n != nil; n = n.parent {
+			weights[n] += att.power
+		}
+	}
+	return weights
+}
+
+// updateHead re-runs the fork choice rule, returning a reorg event if the
+// new head does not descend from the old one
+func (f *ForkChoice) updateHead() *ReorgEvent {
+	weights := f.weights()
+	head := f.root
+	for len(head.children) > 0 {
+		best := head.children[0]
+		for _, child := range head.children[1:] {
+			if weights[child] > weights[best] ||
+				(weights[child] == weights[best] && bytes.Compare(child.hash, best.hash) < 0) {
+				best = child
+			}
+		}
+		head = best
+	}
+
+	old := f.head
+	f.head = head
+	if old == head {
+		return nil
+	}
+
+	oldBranch := make(map[*forkNode]bool)
+	for n := old; n != nil; n = n.parent {
+		oldBranch[n] = true
+	}
+	ancestor := head
+	applied := make([]*types.Block, 0)
+	for ancestor != nil && !oldBranch[ancestor] {
+		applied = append(applied, ancestor.block)
+		ancestor = ancestor.parent
+	}
+	if ancestor == old {
+		return nil // The head only advanced
+	}
+	for i, j := 0, len(applied)-1; i < j; i, j = i+1, j-1 {
+		applied[i], applied[j] = applied[j], applied[i]
+	}
+
+	reorg := &ReorgEvent{OldHead: old.block, NewHead: head.block, Applied: applied}
+	for n := old; n != ancestor; n = n.parent {
+		reorg.RolledBack = append(reorg.RolledBack, n.block)
+	}
+	if ancestor != nil {
+		reorg.CommonAncestor = ancestor.block
+	}
+	return reorg
+}
@@ -0,0 +1,173 @@
+package consensus
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tendermint/tendermint/types"
+)
+
+// Metrics defaults
+const (
+	DefaultMetricsWindow = 100 // Heights the rolling averages cover
+	metricsNamespace     = "zen_consensus"
+)
+
+// HeightMetrics is what the node measured committing a height
+type HeightMetrics struct {
+	Height   int64         `json:"height"`
+	Txs      int           `json:"txs"`
+	Interval time.Duration `json:"interval"` // Since the previous block's header time, zero for the first
+	Finality time.Duration `json:"finality"` // From the block's header time to its local commit
+	Rounds   int32         `json:"rounds"`   // Rounds the height took to decide
+}
+
+// MetricsSnapshot summarizes the heights in the window
+type MetricsSnapshot struct {
+	Height        int64         `json:"height"`
+	TPS           float64       `json:"tps"`
+	BlockInterval time.Duration `json:"block_interval"`
+	Finality      time.Duration `json:"finality"`
+	Rounds        float64       `json:"rounds"`
+	TotalTxs      uint64        `json:"total_txs"`
+	TotalBlocks   uint64        `json:"total_blocks"`
+}
+
+// Metrics keeps rolling measurements of the last committed heights. It is
+// a Prometheus collector.
+type Metrics struct {
+	mu          sync.Mutex
+	window      int
+	samples     []HeightMetrics
+	totalTxs    uint64
+	totalBlocks uint64
+
+	heightDesc   *prometheus.Desc
+	txsDesc      *prometheus.Desc
+	blocksDesc   *prometheus.Desc
+	tpsDesc      *prometheus.Desc
+	intervalDesc *prometheus.Desc
+	finalityDesc *prometheus.Desc
+	roundsDesc   *prometheus.Desc
+}
+
+var _ prometheus.Collector = (*Metrics)(nil)
+
+// NewMetrics creates a collector averaging over the last window heights
+func NewMetrics(window int) *Metrics {
+	if window <= 0 {
+		window = DefaultMetricsWindow
+	}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(metricsNamespace+"_"+name, help, nil, nil)
+	}
+	return &Metrics{
+		window:       window,
+		heightDesc:   desc("height", "Last committed height."),
+		txsDesc:      desc("txs_total", "Transactions committed since the node started."),
+		blocksDesc:   desc("blocks_total", "Blocks committed since the node started."),
+		tpsDesc:      desc("tps", "Transactions per second over the window."),
+		intervalDesc: desc("block_interval_seconds", "Average time between blocks over the window."),
+		finalityDesc: desc("finality_seconds", "Average time from block proposal to commit over the window."),
+		roundsDesc:   desc("rounds", "Average rounds per height over the window."),
+	}
+}
+
+// recordBlock measures a committed block against the block before it, nil
+// for the first
+func (m *Metrics) recordBlock(block, prev *types.Block, commit *types.Commit, now time.Time) {
+	sample := HeightMetrics{
+		Height: block.Header.Height,
+		Txs:    len(block.Data.Txs),
+		Rounds: commit.Round + 1,
+	}
+	if prev != nil && block.Header.Time.After(prev.Header.Time) {
+		sample.Interval = block.Header.Time.Sub(prev.Header.Time)
+	}
+	if now.After(block.Header.Time) {
+		sample.Finality = now.Sub(block.Header.Time)
+	}
+	m.Record(sample)
+}
+
+// Record adds the measurements of a height, evicting the oldest beyond the
+// window
+func (m *Metrics) Record(sample HeightMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.samples = append(m.samples, sample)
+	if len(m.samples) > m.window {
+		m.samples = append([]HeightMetrics{}, m.samples[len(m.samples)-m.window:]...)
+	}
+	m.totalTxs += uint64(sample.Txs)
+	m.totalBlocks++
+}
+
+// Samples returns the measurements in the window, oldest first
+func (m *Metrics) Samples() []HeightMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]HeightMetrics{}, m.samples...)
+}
+
+// Snapshot averages the measurements in the window. TPS only counts blocks
+// with a known interval.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snap := MetricsSnapshot{TotalTxs: m.totalTxs, TotalBlocks: m.totalBlocks}
+	if len(m.samples) == 0 {
+		return snap
+	}
+	snap.Height = m.samples[len(m.samples)-1].Height
+
+	var txs int
+	var intervals, finality time.Duration
+	var timed int
+	var rounds int64
+	for _, s := range m.samples {
+		if s.Interval > 0 {
+			txs += s.Txs
+			intervals += s.Interval
+			timed++
+		}
+		finality += s.Finality
+		rounds += int64(s.Rounds)
+	}
+	if timed > 0 {
+		snap.TPS = float64(txs) / intervals.Seconds()
+		snap.BlockInterval = intervals / time.Duration(timed)
+	}
+	snap.Finality = finality / time.Duration(len(m.samples))
+	snap.Rounds = float64(rounds) / float64(len(m.samples))
+	return snap
+}
+
+// Describe implements prometheus.Collector
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{m.heightDesc, m.txsDesc, m.blocksDesc, m.tpsDesc,
+		m.intervalDesc, m.finalityDesc, m.roundsDesc} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	snap := m.Snapshot()
+	ch <- prometheus.MustNewConstMetric(m.heightDesc, prometheus.GaugeValue, float64(snap.Height))
+	ch <- prometheus.MustNewConstMetric(m.txsDesc, prometheus.CounterValue, float64(snap.TotalTxs))
+	ch <- prometheus.MustNewConstMetric(m.blocksDesc, prometheus.CounterValue, float64(snap.TotalBlocks))
+	ch <- prometheus.MustNewConstMetric(m.tpsDesc, prometheus.GaugeValue, snap.TPS)
+	ch <- prometheus.MustNewConstMetric(m.intervalDesc, prometheus.GaugeValue, snap.BlockInterval.Seconds())
+	ch <- prometheus.MustNewConstMetric(m.finalityDesc, prometheus.GaugeValue, snap.Finality.Seconds())
+	ch <- prometheus.MustNewConstMetric(m.roundsDesc, prometheus.GaugeValue, snap.Rounds)
+}
+
+// Metrics returns the node's consensus metrics
+func (c *Consensus) Metrics() *Metrics {
+	return c.metrics
+}
@@ -0,0 +1,256 @@
+package consensus
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// PoH defaults
+const (
+	DefaultHashesPerTick = 2000 // Sequential SHA-256 rounds per tick
+	DefaultTicksPerEntry = 64   // Ticks recorded between two entries
+)
+
+// PoHGenerator produces a sequential SHA-256 hash chain.
+// Every hash depends on the previous one, so the number of hashes between
+// two entries proves that time has passed between them.
+type PoHGenerator struct {
+	mu            sync.Mutex
+	hash          []byte
+	index         uint64
+	hashesPerTick uint64
+	ticksPerEntry uint64
+}
+
+// NewPoHGenerator creates a generator continuing from the given entry
+func NewPoHGenerator(last ProofOfHistoryEntry, hashesPerTick, ticksPerEntry uint64) *PoHGenerator {
+	if hashesPerTick == 0 {
+		hashesPerTick = DefaultHashesPerTick
+	}
+	if ticksPerEntry == 0 {
+		ticksPerEntry = DefaultTicksPerEntry
+	}
+
+	return &PoHGenerator{
+		hash:          append([]byte{}, last.Hash...),
+		index:         last.Index,
+		hashesPerTick: hashesPerTick,
+		ticksPerEntry: ticksPerEntry,
+	}
+}
+
+// HashesPerEntry returns the number of hashes between two entries
+func (g *PoHGenerator) HashesPerEntry() uint64 {
+	return g.hashesPerTick * g.ticksPerEntry
+}
+
+// Record advances the chain by one entry, mixing in the given transaction hashes.
+// The chain state is recorded at every tick boundary so verifiers can check
+// each tick independently; the mixin is applied after the last tick.
+func (g *PoHGenerator) Record(txHashes [][]byte, timestamp int64) ProofOfHistoryEntry {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	mixin := MixinHash(txHashes)
+
+	entry := ProofOfHistoryEntry{
+		Index:        g.index + 1,
+		PreviousHash: g.hash,
+		Timestamp:    timestamp,
+		EntryData:    mixin,
+		NumHashes:    g.HashesPerEntry(),
+		Ticks:        make([][]byte, g.ticksPerEntry),
+	}
+
+	hash := g.hash
+	for i := range entry.Ticks {
+		hash = computePoHHash(hash, g.hashesPerTick)
+		entry.Ticks[i] = hash
+	}
+	entry.Hash = mixinPoHHash(hash, mixin)
+
+	g.hash = entry.Hash
+	g.index = entry.Index
+
+	return entry
+}
+
+// Reset moves the generator to continue from the given entry
+func (g *PoHGenerator) Reset(last ProofOfHistoryEntry) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.hash = append([]byte{}, last.Hash...)
+	g.index = last.Index
+}
+
+// MixinHash commits to an ordered list of transaction hashes.
+// Returns nil for an empty list so tick-only entries carry no mixin.
+func MixinHash(txHashes [][]byte) []byte {
+	if len(txHashes) == 0 {
+		return nil
+	}
+
+	h := sha256.New()
+	for _, txHash := range txHashes {
+		h.Write(txHash)
+	}
+	return h.Sum(nil)
+}
+
+// TxHashes returns the SHA-256 hash of every transaction
+func TxHashes(txs [][]byte) [][]byte {
+	hashes := make([][]byte, len(txs))
+	for i, tx := range txs {
+		sum := sha256.Sum256(tx)
+		hashes[i] = sum[:]
+	}
+	return hashes
+}
+
+// computePoHHash runs numHashes sequential SHA-256 rounds starting at prev
+func computePoHHash(prev []byte, numHashes uint64) []byte {
+	hash := sha256.Sum256(prev)
+	for i := uint64(1); i < numHashes; i++ {
+		hash = sha256.Sum256(hash[:])
+	}
+	return hash[:]
+}
+
+// mixinPoHHash hashes the chain state with a mixin, if any
+func mixinPoHHash(hash, mixin []byte) []byte {
+	if len(mixin) == 0 {
+		return hash
+	}
+
+	sum := sha256.Sum256(append(append([]byte{}, hash...), mixin...))
+	return sum[:]
+}
+
+// checkPoHShape checks the parts of an entry that are cheap to verify:
+// the link to prev and the amount of work it claims.
+func checkPoHShape(prev, entry ProofOfHistoryEntry, hashesPerTick, ticksPerEntry uint64) error {
+	if entry.Index != prev.Index+1 {
+		return fmt.Errorf("PoH index gap: %d after %d", entry.Index, prev.Index)
+	}
+	if !bytes.Equal(entry.PreviousHash, prev.Hash) {
+		return fmt.Errorf("PoH entry %d does not chain to previous entry", entry.Index)
+	}
+	if hashesPerTick == 0 || ticksPerEntry == 0 {
+		return fmt.Errorf("invalid PoH parameters: %d hashes/tick, %d ticks/entry", hashesPerTick, ticksPerEntry)
+	}
+	if entry.NumHashes != hashesPerTick*ticksPerEntry {
+		return fmt.Errorf("PoH entry %d has %d hashes, expected %d",
+			entry.Index, entry.NumHashes, hashesPerTick*ticksPerEntry)
+	}
+	if uint64(len(entry.Ticks)) != ticksPerEntry {
+		return fmt.Errorf("PoH entry %d has %d ticks, expected %d", entry.Index, len(entry.Ticks), ticksPerEntry)
+	}
+	if !bytes.Equal(entry.Hash, mixinPoHHash(entry.Ticks[ticksPerEntry-1], entry.EntryData)) {
+		return fmt.Errorf("PoH entry %d hash does not follow its last tick", entry.Index)
+	}
+
+	return nil
+}
+
+// verifyPoHTick recomputes a single tick of an entry
+func verifyPoHTick(entry ProofOfHistoryEntry, tick int, hashesPerTick uint64) error {
+	start := entry.PreviousHash
+	if tick > 0 {
+		start = entry.Ticks[tick-1]
+	}
+
+	if !bytes.Equal(computePoHHash(start, hashesPerTick), entry.Ticks[tick]) {
+		return fmt.Errorf("PoH entry %d tick %d hash mismatch", entry.Index, tick)
+	}
+	return nil
+}
+
+// VerifyPoHEntry checks that an entry follows from prev with the expected
+// number of hashes per tick and ticks per entry
+func VerifyPoHEntry(prev, entry ProofOfHistoryEntry, hashesPerTick, ticksPerEntry uint64) error {
+	if err := checkPoHShape(prev, entry, hashesPerTick, ticksPerEntry); err != nil {
+		return err
+	}
+
+	for tick := range entry.Ticks {
+		if err := verifyPoHTick(entry, tick, hashesPerTick); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// VerifyPoHEntries verifies a contiguous range of entries starting after start.
+// Every tick only depends on the tick before it, so the hash work is split
+// across all CPU cores once the links between entries have been checked.
+func VerifyPoHEntries(start ProofOfHistoryEntry, entries []ProofOfHistoryEntry, hashesPerTick, ticksPerEntry uint64) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	// Check links and claimed work sequentially (cheap)
+	prev := start
+	for _, entry := range entries {
+		if err := checkPoHShape(prev, entry, hashesPerTick, ticksPerEntry); err != nil {
+			return err
+		}
+		prev = entry
+	}
+
+	// Recompute ticks in parallel (expensive)
+	type job struct {
+		entry int
+		tick  int
+	}
+
+	workers := runtime.NumCPU()
+	if total := len(entries) * int(ticksPerEntry); workers > total {
+		workers = total
+	}
+
+	jobs := make(chan job)
+	stop := make(chan struct{})
+	var once sync.Once
+	var firstErr error
+	var wg sync.WaitGroup
+
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			close(stop)
+		})
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if err := verifyPoHTick(entries[j.entry], j.tick, hashesPerTick); err != nil {
+					fail(err)
+					return
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range entries {
+		for tick := 0; tick < int(ticksPerEntry); tick++ {
+			select {
+			case jobs <- job{i, tick}:
+			case <-stop:
+				break feed
+			}
+		}
+	}
+	close(jobs)
+
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,261 @@
+package consensus
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"sort"
+
+	"github.com/tendermint/tendermint/types"
+	"github.com/zennetwork/zennetwork/x/security"
+)
+
+// Domain separators for proposer election and block signatures
+var (
+	proposerSeedDomain = []byte("zen-proposer-election")
+	proposalSignDomain = []byte("zen-proposal")
+)
+
+// scoreFracBits is the fixed-point precision of election scores.
+// -log2(u) is at most 63, so 58 fractional bits keep scores within a uint64.
+const scoreFracBits = 58
+
+// ErrNotProposer is returned by ProduceBlock when the local validator is not elected
+var ErrNotProposer = errors.New("not the elected proposer")
+
+// SetValidatorKeys sets the local validator identity: the ed25519 key that
+// signs blocks and the P-256 key that proves VRF outputs
+func (c *Consensus) SetValidatorKeys(address []byte, signKey ed25519.PrivateKey, vrfKey *ecdsa.PrivateKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.selfAddress = address
+	c.signKey = signKey
+	c.vrfKey = vrfKey
+}
+
+// ProposerFor returns the elected proposer for a height and round
+func (c *Consensus) ProposerFor(height int64, round int32) ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.selectProposer(height, round)
+}
+
+// ProposerSeed derives the election seed of a height from the VRF output
+// revealed by the proposer of the previous height. VRF outputs are unique,
+// so a proposer cannot grind the seed of the next height; it can only
+// withhold its block, which hands the slot to the next round.
+func ProposerSeed(beacon []byte, height int64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(height))
+
+	h := sha256.New()
+	h.Write(proposerSeedDomain)
+	h.Write(beacon)
+	h.Write(buf)
+	return h.Sum(nil)
+}
+
+// RankProposers orders the eligible validators by election score for a seed.
+// Every validator draws score = -log2(u) / power with u uniform in (0, 1]
+// taken from H(seed || address); the lowest score wins. The minimum of
+// independent exponential variables is won with probability power / total
+// power, so selection is stake-proportional. Scores use fixed-point integer
+// arithmetic so every node ranks identically.
+func RankProposers(validators []Validator, seed []byte) [][]byte {
+	type candidate struct {
+		address []byte
+		score   uint64
+		power   uint64
+	}
+	candidates := make([]candidate, 0, len(validators))
+
+	for _, val := range validators {
+		if VotingPower(val) <= 0 {
+			continue
+		}
+		candidates = append(candidates, candidate{val.Address, proposerScore(seed, val.Address), uint64(VotingPower(val))})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		// a.score / a.power < b.score / b.power, compared in 128 bits
+		ahi, alo := bits.Mul64(a.score, b.power)
+		bhi, blo := bits.Mul64(b.score, a.power)
+		if ahi != bhi {
+			return ahi < bhi
+		}
+		if alo != blo {
+			return alo < blo
+		}
+		return bytes.Compare(a.address, b.address) < 0
+	})
+
+	ranking := make([][]byte, len(candidates))
+	for i, cand := range candidates {
+		ranking[i] = cand.address
+	}
+	return ranking
+}
+
+// ElectProposer returns the proposer for a round. Round 0 goes to the
+// best-ranked validator; if it never produces a block the height moves to
+// the next round on timeout and the next validator in the ranking proposes.
+func ElectProposer(validators []Validator, seed []byte, round int32) ([]byte, error) {
+	if round < 0 {
+		return nil, fmt.Errorf("invalid round %d", round)
+	}
+
+	ranking := RankProposers(validators, seed)
+	if len(ranking) == 0 {
+		return nil, fmt.Errorf("no eligible validators")
+	}
+
+	return ranking[int(round)%len(ranking)], nil
+}
+
+// proposerSeed returns the election seed for the next height
+func (c *Consensus) proposerSeed(height int64) ([]byte, error) {
+	next := int64(len(c.PoHSequence))
+	if len(c.vrfBeacon) == 0 || height != next {
+		return nil, fmt.Errorf("no election seed for height %d (next: %d)", height, next)
+	}
+
+	return ProposerSeed(c.vrfBeacon, height), nil
+}
+
+// selectProposer elects the block proposer for a height and round
+func (c *Consensus) selectProposer(height int64, round int32) ([]byte, error) {
+	if len(c.ValidatorSet) == 0 {
+		return nil, fmt.Errorf("no validators available")
+	}
+
+	seed, err := c.proposerSeed(height)
+	if err != nil {
+		return nil, err
+	}
+
+	return ElectProposer(c.ValidatorSet, seed, round)
+}
+
+// proveEligibility computes the local VRF proof over the seed of a height
+func (c *Consensus) proveEligibility(height int64) ([]byte, error) {
+	if c.vrfKey == nil {
+		return nil, fmt.Errorf("no VRF key configured")
+	}
+
+	seed, err := c.proposerSeed(height)
+	if err != nil {
+		return nil, err
+	}
+
+	_, proof, err := security.VRFProve(c.vrfKey, seed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute VRF proof: %w", err)
+	}
+
+	return proof, nil
+}
+
+// verifyProposer checks that the proposer of a block won the election for
+// the proof's round, signed the block and revealed a valid VRF proof.
+// Returns the VRF output that seeds the next height.
+func (c *Consensus) verifyProposer(block *types.Block, proof PoHProof) ([]byte, error) {
+	height := block.Header.Height
+	proposer := block.Header.Proposer
+
+	if !bytes.Equal(proposer, proof.Validator) {
+		return nil, fmt.Errorf("PoH proof validator %x does not match proposer %x", proof.Validator, proposer)
+	}
+
+	elected, err := c.selectProposer(height, proof.Round)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(proposer, elected) {
+		return nil, fmt.Errorf("proposer %x was not elected for height %d round %d (elected: %x)",
+			proposer, height, proof.Round, elected)
+	}
+
+	val := c.ValidatorSet[c.validatorIndex(proposer)]
+	if len(val.PubKey) != ed25519.PublicKeySize ||
+		!ed25519.Verify(val.PubKey, proposalSignBytes(block.Header, proof), proof.Signature) {
+		return nil, fmt.Errorf("invalid block signature from %x", proposer)
+	}
+
+	seed, err := c.proposerSeed(height)
+	if err != nil {
+		return nil, err
+	}
+	output, err := security.VRFVerify(val.VRFPubKey, seed, proof.VRFProof)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VRF proof from %x: %w", proposer, err)
+	}
+
+	return output, nil
+}
+
+// proposalSignBytes returns the bytes a proposer signs for a block
+func proposalSignBytes(header *types.Header, proof PoHProof) []byte {
+	buf := make([]byte, 12)
+	binary.BigEndian.PutUint32(buf[:4], uint32(proof.Round))
+	binary.BigEndian.PutUint64(buf[4:], uint64(proof.Timestamp))
+
+	h := sha256.New()
+	h.Write(proposalSignDomain)
+	h.Write(header.Hash())
+	h.Write(proof.Entry.Hash)
+	h.Write(proof.VRFProof)
+	h.Write(buf)
+	return h.Sum(nil)
+}
+
+// proposerScore returns -log2(u) in fixed point, with u in (0, 1] derived
+// from the seed and the validator address
+func proposerScore(seed, address []byte) uint64 {
+	sum := sha256.Sum256(append(append([]byte{}, seed...), address...))
+
+	// u = w / 2^63 with w in [1, 2^63]
+	w := binary.BigEndian.Uint64(sum[:8])>>1 + 1
+
+	return 63<<scoreFracBits - log2Fixed(w)
+}
+
+// log2Fixed returns log2(w) with scoreFracBits fractional bits, using only
+// integer arithmetic so the result is identical on every architecture
+func log2Fixed(w uint64) uint64 {
+	n := uint64(bits.Len64(w) - 1)
+	result := n << scoreFracBits
+
+	// Normalise w to x in [1, 2) as a Q1.63 fixed-point value
+	x := w << (63 - n)
+	for bit := uint64(1) << (scoreFracBits - 1); bit > 0; bit >>= 1 {
+		// x = x^2, with x^2 in [1, 4)
+		hi, lo := bits.Mul64(x, x)
+		if hi >= 1<<63 {
+			// x^2 >= 2: emit the bit and halve
+			result |= bit
+			x = hi
+		} else {
+			x = hi<<1 | lo>>63
+		}
+	}
+
+	return result
+}
+
+// validatorIndex returns the index of a validator in the set, or -1
+func (c *Consensus) validatorIndex(address []byte) int {
+	for i, val := range c.ValidatorSet {
+		if bytes.Equal(val.Address, address) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,294 @@
+package consensus
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/tendermint/tendermint/types"
+)
+
+// BlockRewarder mints the reward of a block; x/halving implements it. The
+// reward of a height must not change when it is asked for again.
+type BlockRewarder interface {
+	CalculateReward(blockNumber int64, validator []byte) (*big.Int, error)
+}
+
+// FeeShare returns the validators' share of the fees paid by a block's txs
+type FeeShare func(txs [][]byte) *big.Int
+
+// RewardParams sets how a block's rewards are split
+type RewardParams struct {
+	ProposerBonusBps uint64 `json:"proposer_bonus_bps"` // Paid to the proposer before the signer split
+}
+
+// DefaultRewardParams returns the default reward split
+func DefaultRewardParams() RewardParams {
+	return RewardParams{
+		ProposerBonusBps: 500, // 5%
+	}
+}
+
+// Reward allocation sources and payout kinds
+const (
+	AllocationProposerBonus = "proposer_bonus"
+	AllocationSigner        = "signer"
+	AllocationRemainder     = "remainder" // Rounding dust, to the proposer
+
+	PayoutCommission = "commission"
+	PayoutDelegation = "delegation"
+)
+
+// RewardAllocation is the share of a block's rewards assigned to a validator
+type RewardAllocation struct {
+	Validator []byte   `json:"validator"`
+	Source    string   `json:"source"`
+	Amount    *big.Int `json:"amount"`
+}
+
+// RewardPayout is an amount credited to an account from a validator's allocation
+type RewardPayout struct {
+	Recipient []byte   `json:"recipient"`
+	Validator []byte   `json:"validator"`
+	Kind      string   `json:"kind"`
+	Amount    *big.Int `json:"amount"`
+}
+
+// RewardRecord is the audit trail of one block's rewards. Allocations and
+// payouts each sum to Total.
+type RewardRecord struct {
+	Height      int64              `json:"height"`
+	Proposer    []byte             `json:"proposer"`
+	BlockReward *big.Int           `json:"block_reward"`
+	Fees        *big.Int           `json:"fees"`
+	Total       *big.Int           `json:"total"`
+	Allocations []RewardAllocation `json:"allocations"`
+	Payouts     []RewardPayout     `json:"payouts"`
+}
+
+// rewardLogSize bounds the in-memory reward records; older ones are in the store
+const rewardLogSize = 1000
+
+// SetRewardSources sets where block rewards come from: the minted block
+// reward and the validator share of transaction fees. Either may be nil.
+func (c *Consensus) SetRewardSources(rewarder BlockRewarder, feeShare FeeShare) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rewarder = rewarder
+	c.feeShare = feeShare
+}
+
+// GetRewards returns the rewards credited to an account and not yet withdrawn
+func (c *Consensus) GetRewards(address []byte) *big.Int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if amount, ok := c.rewards[hex.EncodeToString(address)]; ok {
+		return new(big.Int).Set(amount)
+	}
+	return new(big.Int)
+}
+
+// WithdrawRewards returns and clears the rewards credited to an account
+func (c *Consensus) WithdrawRewards(address []byte) *big.Int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := hex.EncodeToString(address)
+	amount, ok := c.rewards[key]
+	if !ok {
+		return new(big.Int)
+	}
+	delete(c.rewards, key)
+	return amount
+}
+
+// GetRewardRecord returns the reward record of a committed height
+func (c *Consensus) GetRewardRecord(height int64) (RewardRecord, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for i := len(c.rewardLog) - 1; i >= 0; i-- {
+		if c.rewardLog[i].Height == height {
+			return c.rewardLog[i], nil
+		}
+	}
+	if c.store != nil {
+		return c.store.loadRewardRecord(height)
+	}
+	return RewardRecord{}, fmt.Errorf("no reward record at height %d", height)
+}
+
+// distributeRewards mints the block reward, adds the validator fee share
+// and splits the total: a bonus to the proposer, the rest to the signers of
+// the block's LastCommit by voting power. Each validator's allocation pays
+// its commission to the operator and the remainder to its delegators by
+// shares. All amounts are exact; rounding dust goes to the proposer.
+func (c *Consensus) distributeRewards(block *types.Block) *RewardRecord {
+	height := block.Header.Height
+	proposer := block.Header.Proposer
+
+	record := &RewardRecord{
+		Height:      height,
+		Proposer:    proposer,
+		BlockReward: new(big.Int),
+		Fees:        new(big.Int),
+		Allocations: make([]RewardAllocation, 0),
+		Payouts:     make([]RewardPayout, 0),
+	}
+	if c.rewarder != nil {
+		amount, err := c.rewarder.CalculateReward(height, proposer)
+		if err != nil {
+			fmt.Printf("[CONSENSUS] No block reward at height %d: %v\n", height, err)
+		} else if amount != nil {
+			record.BlockReward.Set(amount)
+		}
+	}
+	if c.feeShare != nil {
+		txs := make([][]byte, len(block.Data.Txs))
+		for i, tx := range block.Data.Txs {
+			txs[i] = tx
+		}
+		if fees := c.feeShare(txs); fees != nil {
+			record.Fees.Set(fees)
+		}
+	}
+	record.Total = new(big.Int).Add(record.BlockReward, record.Fees)
+	if record.Total.Sign() == 0 {
+		c.recordReward(*record)
+		return record
+	}
+
+	bonus := bpsOf(record.Total, c.RewardParams.ProposerBonusBps)
+	c.allocateReward(record, proposer, AllocationProposerBonus, bonus)
+	pool := new(big.Int).Sub(record.Total, bonus)
+
+	// Signers of the previous height, weighted by the power they signed with
+	signers, signedPower := c.lastCommitSigners(block.LastCommit)
+	distributed := new(big.Int)
+	if signedPower > 0 {
+		total := big.NewInt(signedPower)
+		for _, signer := range signers {
+			share := new(big.Int).Mul(pool, big.NewInt(VotingPower(signer)))
+			share.Quo(share, total)
+			c.allocateReward(record, signer.Address, AllocationSigner, share)
+			distributed.Add(distributed, share)
+		}
+	}
+	c.allocateReward(record, proposer, AllocationRemainder, pool.Sub(pool, distributed))
+
+	c.recordReward(*record)
+	return record
+}
+
+// lastCommitSigners returns the validators that signed a LastCommit and
+// their total voting power, in validator set order
+func (c *Consensus) lastCommitSigners(commit *types.Commit) ([]Validator, int64) {
+	if commit == nil {
+		return nil, 0
+	}
+
+	signed := make(map[string]bool)
+	for _, sig := range commit.Signatures {
+		if sig.BlockIDFlag == types.BlockIDFlagCommit {
+			signed[hex.EncodeToString(sig.ValidatorAddress)] = true
+		}
+	}
+
+	signers := make([]Validator, 0, len(signed))
+	var power int64
+	for _, val := range c.lastValidators {
+		if signed[hex.EncodeToString(val.Address)] && VotingPower(val) > 0 {
+			signers = append(signers, val)
+			power += VotingPower(val)
+		}
+	}
+	return signers, power
+}
+
+// allocateReward assigns an amount to a validator and pays it out: the
+// commission to the operator, the rest to delegators by shares. A validator
+// that has left the set is paid in full as commission.
+func (c *Consensus) allocateReward(record *RewardRecord, address []byte, source string, amount *big.Int) {
+	if amount.Sign() == 0 {
+		return
+	}
+	record.Allocations = append(record.Allocations, RewardAllocation{
+		Validator: address,
+		Source:    source,
+		Amount:    new(big.Int).Set(amount),
+	})
+
+	idx := c.validatorIndex(address)
+	if idx < 0 {
+		c.payReward(record, address, address, PayoutCommission, amount)
+		return
+	}
+	val := &c.ValidatorSet[idx]
+	val.Reward = new(big.Int).Add(orZero(val.Reward), amount)
+
+	commission := bpsOf(amount, val.Commission)
+	rest := new(big.Int).Sub(amount, commission)
+
+	// Delegations in key order so records are identical on every node
+	keys := make([]string, 0)
+	for key, delegation := range c.delegations {
+		if bytes.Equal(delegation.Validator, address) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	paid := new(big.Int)
+	if totalShares := orZero(val.DelegatorShares); totalShares.Sign() > 0 {
+		for _, key := range keys {
+			delegation := c.delegations[key]
+			share := new(big.Int).Mul(rest, delegation.Shares)
+			share.Quo(share, totalShares)
+			c.payReward(record, delegation.Delegator, address, PayoutDelegation, share)
+			paid.Add(paid, share)
+		}
+	}
+
+	// Undelegated shares and rounding dust stay with the operator
+	commission.Add(commission, rest.Sub(rest, paid))
+	c.payReward(record, address, address, PayoutCommission, commission)
+}
+
+// payReward credits an account and records the payout
+func (c *Consensus) payReward(record *RewardRecord, recipient, validator []byte, kind string, amount *big.Int) {
+	if amount.Sign() == 0 {
+		return
+	}
+
+	key := hex.EncodeToString(recipient)
+	balance, ok := c.rewards[key]
+	if !ok {
+		balance = new(big.Int)
+	}
+	c.rewards[key] = new(big.Int).Add(balance, amount)
+
+	record.Payouts = append(record.Payouts, RewardPayout{
+		Recipient: recipient,
+		Validator: validator,
+		Kind:      kind,
+		Amount:    new(big.Int).Set(amount),
+	})
+}
+
+// recordReward appends a record to the bounded reward log
+func (c *Consensus) recordReward(record RewardRecord) {
+	c.rewardLog = append(c.rewardLog, record)
+	if len(c.rewardLog) > rewardLogSize {
+		c.rewardLog = c.rewardLog[len(c.rewardLog)-rewardLogSize:]
+	}
+}
+
+// bpsOf returns amount * bps / 10000, rounded down
+func bpsOf(amount *big.Int, bps uint64) *big.Int {
+	share := new(big.Int).Mul(amount, new(big.Int).SetUint64(bps))
+	return share.Quo(share, big.NewInt(10000))
+}
@@ -0,0 +1,331 @@
+package consensus
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/tendermint/tendermint/types"
+)
+
+// shardBlockDomain separates shard block signatures from other signatures
+var shardBlockDomain = []byte("zen-shard-block")
+
+// ShardBlock is the batch of transactions a shard's committee produced for
+// a beacon height. The beacon block of the height carries the shard blocks
+// and its PoH entry commits to their hashes.
+type ShardBlock struct {
+	ShardID    uint64   `json:"shard_id"`
+	Height     int64    `json:"height"`      // Beacon height the block is produced for
+	ParentHash []byte   `json:"parent_hash"` // Last committed block of the shard, nil for the first
+	TxRoot     []byte   `json:"tx_root"`
+	Txs        [][]byte `json:"txs"`
+	Producer   []byte   `json:"producer"`
+	Signature  []byte   `json:"signature"` // Producer's ed25519 signature of Hash
+}
+
+// Hash returns the hash of the shard block, committing to its transactions
+// through TxRoot
+func (sb *ShardBlock) Hash() []byte {
+	buf := make([]byte, 16)
+	binary.BigEndian.PutUint64(buf, sb.ShardID)
+	binary.BigEndian.PutUint64(buf[8:], uint64(sb.Height))
+
+	h := sha256.New()
+	h.Write(shardBlockDomain)
+	h.Write(buf)
+	h.Write([]byte{byte(len(sb.ParentHash))})
+	h.Write(sb.ParentHash)
+	h.Write([]byte{byte(len(sb.TxRoot))})
+	h.Write(sb.TxRoot)
+	h.Write(sb.Producer)
+	return h.Sum(nil)
+}
+
+// TxShard returns the shard a transaction belongs to
+func TxShard(tx []byte) uint64 {
+	sum := sha256.Sum256(tx)
+	return binary.BigEndian.Uint64(sum[24:]) % NumShards
+}
+
+// ShardHashes returns the hashes of shard blocks in order
+func ShardHashes(blocks []*ShardBlock) [][]byte {
+	hashes := make([][]byte, len(blocks))
+	for i, sb := range blocks {
+		hashes[i] = sb.Hash()
+	}
+	return hashes
+}
+
+// ShardProducer returns the committee member producing a shard's block at
+// a beacon height
+func (c *Consensus) ShardProducer(shard uint64, height int64) ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.shardProducer(shard, height)
+}
+
+// shardProducer rotates a shard's block production through its committee
+// by height, skipping members that lost their voting power since the
+// shuffle
+func (c *Consensus) shardProducer(shard uint64, height int64) ([]byte, error) {
+	if shard >= uint64(len(c.Committees)) {
+		return nil, fmt.Errorf("no committee for shard %d", shard)
+	}
+
+	members := c.Committees[shard].Validators
+	for i := range members {
+		member := members[(height+int64(i))%int64(len(members))]
+		if idx := c.validatorIndex(member.Address); idx >= 0 && VotingPower(c.ValidatorSet[idx]) > 0 {
+			return member.Address, nil
+		}
+	}
+	return nil, fmt.Errorf("no eligible producer for shard %d", shard)
+}
+
+// updateBlockProducers records the producer of every shard's next block
+func (c *Consensus) updateBlockProducers() {
+	c.BlockProducers = make([]uint64, NumShards)
+	for shard := range c.BlockProducers {
+		producer, err := c.shardProducer(uint64(shard), c.CurrentHeight+1)
+		if err != nil {
+			continue
+		}
+		c.BlockProducers[shard] = uint64(c.validatorIndex(producer))
+	}
+}
+
+// buildShardBlocks splits txs by shard and produces a signed block for
+// every non-empty shard in parallel. Every shard must be one this node
+// produces at the height.
+func (c *Consensus) buildShardBlocks(height int64, txs [][]byte) ([]*ShardBlock, error) {
+	if c.signKey == nil {
+		return nil, fmt.Errorf("no validator keys configured")
+	}
+
+	byShard := make(map[uint64][][]byte)
+	for _, tx := range txs {
+		shard := TxShard(tx)
+		byShard[shard] = append(byShard[shard], tx)
+	}
+	blocks := make([]*ShardBlock, 0, len(byShard))
+	for shard, shardTxs := range byShard {
+		producer, err := c.shardProducer(shard, height)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(producer, c.selfAddress) {
+			return nil, fmt.Errorf("not the producer of shard %d at height %d", shard, height)
+		}
+		blocks = append(blocks, &ShardBlock{
+			ShardID:    shard,
+			Height:     height,
+			ParentHash: c.shardHeads[shard],
+			Txs:        shardTxs,
+			Producer:   c.selfAddress,
+		})
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].ShardID < blocks[j].ShardID })
+
+	var wg sync.WaitGroup
+	for _, sb := range blocks {
+		wg.Add(1)
+		go func(sb *ShardBlock) {
+			defer wg.Done()
+			sb.TxRoot = MixinHash(TxHashes(sb.Txs))
+			sb.Signature = ed25519.Sign(c.signKey, sb.Hash())
+		}(sb)
+	}
+	wg.Wait()
+	return blocks, nil
+}
+
+// produceShardBlocks produces the blocks of the shards this node serves at
+// a height from the mempool, pools them for the proposal and gossips them
+func (c *Consensus) produceShardBlocks(height int64) {
+	if c.replaying || c.signKey == nil || c.mempool == nil || c.shardsProduced >= height ||
+		height != c.CurrentHeight+1 {
+		return
+	}
+	c.shardsProduced = height
+
+	served := make(map[uint64]bool)
+	for shard := uint64(0); shard < NumShards; shard++ {
+		if producer, err := c.shardProducer(shard, height); err == nil && bytes.Equal(producer, c.selfAddress) {
+			served[shard] = true
+		}
+	}
+	if len(served) == 0 {
+		return
+	}
+
+	txs := make([][]byte, 0)
+	for _, tx := range c.reapTxs() {
+		if served[TxShard(tx)] {
+			txs = append(txs, tx)
+		}
+	}
+	blocks, err := c.buildShardBlocks(height, txs)
+	if err != nil {
+		fmt.Printf("[CONSENSUS] Shard block production failed at height %d: %v\n", height, err)
+		return
+	}
+	for _, sb := range blocks {
+		c.shardPool[sb.ShardID] = sb
+		c.send(ConsensusMessage{ShardBlock: sb})
+	}
+	if len(blocks) > 0 {
+		fmt.Printf("[CONSENSUS] Produced %d shard blocks for height %d\n", len(blocks), height)
+	}
+}
+
+// handleShardBlock verifies a gossiped shard block and pools it for the
+// proposal of the next height
+func (c *Consensus) handleShardBlock(sb *ShardBlock) error {
+	switch sb.Height {
+	case c.CurrentHeight + 1:
+	case c.CurrentHeight + 2:
+		c.bufferMessage(ConsensusMessage{ShardBlock: sb})
+		return nil
+	default:
+		return nil
+	}
+	if _, ok := c.shardPool[sb.ShardID]; ok {
+		return nil
+	}
+	if err := c.verifyShardBlock(sb, sb.Height); err != nil {
+		return err
+	}
+	c.shardPool[sb.ShardID] = sb
+	return nil
+}
+
+// verifyShardBlock checks a shard block extends its shard with that shard's
+// transactions and is signed by the shard's producer for the height
+func (c *Consensus) verifyShardBlock(sb *ShardBlock, height int64) error {
+	if sb.ShardID >= NumShards {
+		return fmt.Errorf("shard block for unknown shard %d", sb.ShardID)
+	}
+	if sb.Height != height {
+		return fmt.Errorf("shard %d block for height %d, want %d", sb.ShardID, sb.Height, height)
+	}
+	if !bytes.Equal(sb.ParentHash, c.shardHeads[sb.ShardID]) {
+		return fmt.Errorf("shard %d block does not extend the shard", sb.ShardID)
+	}
+	if len(sb.Txs) == 0 {
+		return fmt.Errorf("empty shard %d block", sb.ShardID)
+	}
+	for _, tx := range sb.Txs {
+		if TxShard(tx) != sb.ShardID {
+			return fmt.Errorf("shard %d block carries a transaction of shard %d", sb.ShardID, TxShard(tx))
+		}
+	}
+	if !bytes.Equal(sb.TxRoot, MixinHash(TxHashes(sb.Txs))) {
+		return fmt.Errorf("shard %d block tx root does not match its transactions", sb.ShardID)
+	}
+
+	producer, err := c.shardProducer(sb.ShardID, height)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(sb.Producer, producer) {
+		return fmt.Errorf("shard %d block produced by %x, want %x", sb.ShardID, sb.Producer, producer)
+	}
+	pubKey := c.ValidatorSet[c.validatorIndex(producer)].PubKey
+	if len(pubKey) != ed25519.PublicKeySize || !ed25519.Verify(pubKey, sb.Hash(), sb.Signature) {
+		return fmt.Errorf("invalid shard %d block signature", sb.ShardID)
+	}
+	return nil
+}
+
+// pooledShardBlocks returns the pooled shard blocks in shard order
+func (c *Consensus) pooledShardBlocks() []*ShardBlock {
+	blocks := make([]*ShardBlock, 0, len(c.shardPool))
+	for _, sb := range c.shardPool {
+		blocks = append(blocks, sb)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].ShardID < blocks[j].ShardID })
+	return blocks
+}
+
+// shardTxs returns the transactions of shard blocks in shard order, the
+// order a beacon block lists them in
+func shardTxs(blocks []*ShardBlock) [][]byte {
+	txs := make([][]byte, 0)
+	for _, sb := range blocks {
+		txs = append(txs, sb.Txs...)
+	}
+	return txs
+}
+
+// ShardBlocksOf decodes the shard blocks a beacon block carries, without
+// verifying them
+func ShardBlocksOf(block *types.Block) ([]*ShardBlock, error) {
+	var blocks []*ShardBlock
+	for _, ext := range block.Data.Extensions {
+		if ext.Index != extensionShardBlocks {
+			continue
+		}
+		if err := json.Unmarshal(ext.Bytes, &blocks); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal shard blocks: %w", err)
+		}
+	}
+	return blocks, nil
+}
+
+// blockShards decodes the shard blocks of a beacon block and verifies them
+// in parallel, checking the block lists exactly their transactions
+func (c *Consensus) blockShards(block *types.Block) ([]*ShardBlock, error) {
+	blocks, err := ShardBlocksOf(block)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, sb := range blocks {
+		if sb == nil || (i > 0 && sb.ShardID <= blocks[i-1].ShardID) {
+			return nil, fmt.Errorf("shard blocks out of order")
+		}
+	}
+	errs := make([]error, len(blocks))
+	var wg sync.WaitGroup
+	for i, sb := range blocks {
+		wg.Add(1)
+		go func(i int, sb *ShardBlock) {
+			defer wg.Done()
+			errs[i] = c.verifyShardBlock(sb, block.Header.Height)
+		}(i, sb)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	txs := shardTxs(blocks)
+	if len(txs) != len(block.Data.Txs) {
+		return nil, fmt.Errorf("block carries %d transactions, its shard blocks %d", len(block.Data.Txs), len(txs))
+	}
+	for i, tx := range txs {
+		if !bytes.Equal(tx, block.Data.Txs[i]) {
+			return nil, fmt.Errorf("block transaction %d does not match its shard blocks", i)
+		}
+	}
+	return blocks, nil
+}
+
+// applyShardBlocks advances the shards a committed beacon block extended
+// and starts the pool of the next height
+func (c *Consensus) applyShardBlocks(blocks []*ShardBlock) {
+	for _, sb := range blocks {
+		c.shardHeads[sb.ShardID] = sb.Hash()
+	}
+	c.shardPool = make(map[uint64]*ShardBlock)
+	c.updateBlockProducers()
+}
@@ -0,0 +1,340 @@
+package consensus
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Staking defaults
+const (
+	DefaultEpochLength     = 100                 // Blocks per epoch (~5 minutes)
+	DefaultUnbondingPeriod = 21 * 24 * time.Hour // Unbonding stake stays slashable this long
+	MaxCommission          = 10000               // 100% in basis points
+)
+
+// MaxValidatorPower caps a validator's voting power. Power is stake in
+// whole ZEN; the 1e9 ZEN supply stays far below the cap, and even 2^20
+// validators at the cap total under 2^61, so quorum checks that compute
+// power*3 cannot overflow an int64.
+const MaxValidatorPower = 1 << 40
+
+// StakeToPower normalises a stake to voting power: one unit per whole ZEN,
+// rounded down and capped at MaxValidatorPower
+func StakeToPower(stake *big.Int) int64 {
+	if stake == nil || stake.Sign() <= 0 {
+		return 0
+	}
+	power := new(big.Int).Quo(stake, OneZEN)
+	if !power.IsInt64() || power.Int64() > MaxValidatorPower {
+		return MaxValidatorPower
+	}
+	return power.Int64()
+}
+
+// Delegation is a token holder's stake in a validator, held as shares of
+// the validator's tokens so slashing reduces every delegation pro rata
+type Delegation struct {
+	Delegator []byte   `json:"delegator"`
+	Validator []byte   `json:"validator"`
+	Shares    *big.Int `json:"shares"`
+}
+
+// UnbondingEntry is stake leaving a validator. It can still be slashed
+// for the validator's misbehaviour until it completes.
+type UnbondingEntry struct {
+	Delegator      []byte   `json:"delegator"`
+	Validator      []byte   `json:"validator"`
+	CreationHeight int64    `json:"creation_height"`
+	CompletionTime int64    `json:"completion_time"` // Unix seconds
+	Amount         *big.Int `json:"amount"`
+}
+
+// changeKind is the type of a queued validator set change
+type changeKind uint8
+
+const (
+	changeAdd changeKind = iota
+	changeRemove
+	changePower
+)
+
+// validatorChange is a validator set change waiting for the epoch boundary
+type validatorChange struct {
+	Kind      changeKind `json:"kind"`
+	Validator Validator  `json:"validator"`
+}
+
+// CurrentEpoch returns the epoch of the last committed height
+func (c *Consensus) CurrentEpoch() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return uint64(c.CurrentHeight / c.EpochLength)
+}
+
+// Delegate bonds tokens from a delegator to a validator. The validator's
+// voting power changes at the next epoch boundary.
+func (c *Consensus) Delegate(delegator, validator []byte, amount *big.Int) (Delegation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if amount == nil || amount.Sign() <= 0 {
+		return Delegation{}, fmt.Errorf("delegation amount must be positive")
+	}
+
+	idx := c.validatorIndex(validator)
+	if idx < 0 {
+		return Delegation{}, fmt.Errorf("validator not found: %x", validator)
+	}
+	val := &c.ValidatorSet[idx]
+	if val.Tombstoned {
+		return Delegation{}, fmt.Errorf("validator %x is tombstoned", validator)
+	}
+
+	// Shares are issued at the validator's current exchange rate
+	stake, totalShares := orZero(val.Stake), orZero(val.DelegatorShares)
+	shares := new(big.Int).Set(amount)
+	if totalShares.Sign() > 0 && stake.Sign() > 0 {
+		shares.Mul(amount, totalShares)
+		shares.Quo(shares, stake)
+	}
+	if shares.Sign() == 0 {
+		return Delegation{}, fmt.Errorf("delegation of %s too small for a share", amount)
+	}
+
+	val.Stake = new(big.Int).Add(stake, amount)
+	val.DelegatorShares = new(big.Int).Add(totalShares, shares)
+	delegation := c.addShares(delegator, validator, shares)
+	c.queueChange(changePower, *val)
+
+	return *delegation, nil
+}
+
+// Undelegate starts unbonding shares of a delegation. The tokens are
+// released after UnbondingPeriod and stay slashable until then.
+func (c *Consensus) Undelegate(delegator, validator []byte, shares *big.Int) (UnbondingEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	idx := c.validatorIndex(validator)
+	if idx < 0 {
+		return UnbondingEntry{}, fmt.Errorf("validator not found: %x", validator)
+	}
+
+	entry, err := c.undelegate(&c.ValidatorSet[idx], delegator, shares, c.now())
+	if err != nil {
+		return UnbondingEntry{}, err
+	}
+	c.queueChange(changePower, c.ValidatorSet[idx])
+
+	return entry, nil
+}
+
+// GetDelegation returns the delegation of a delegator to a validator
+func (c *Consensus) GetDelegation(delegator, validator []byte) (Delegation, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	delegation, ok := c.delegations[delegationKey(delegator, validator)]
+	if !ok {
+		return Delegation{}, fmt.Errorf("no delegation from %x to %x", delegator, validator)
+	}
+	return *delegation, nil
+}
+
+// GetUnbonding returns the unbonding entries of a delegator
+func (c *Consensus) GetUnbonding(delegator []byte) []UnbondingEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := make([]UnbondingEntry, 0)
+	for _, entry := range c.unbonding {
+		if bytes.Equal(entry.Delegator, delegator) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// ClaimUnbonded returns and clears the tokens a delegator finished unbonding
+func (c *Consensus) ClaimUnbonded(delegator []byte) *big.Int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := hex.EncodeToString(delegator)
+	amount := orZero(c.unbonded[key])
+	delete(c.unbonded, key)
+	return amount
+}
+
+// undelegate converts shares back to tokens and queues them for unbonding
+func (c *Consensus) undelegate(val *Validator, delegator []byte, shares *big.Int, now time.Time) (UnbondingEntry, error) {
+	key := delegationKey(delegator, val.Address)
+	delegation, ok := c.delegations[key]
+	if !ok || shares == nil || shares.Sign() <= 0 || delegation.Shares.Cmp(shares) < 0 {
+		return UnbondingEntry{}, fmt.Errorf("insufficient shares from %x in %x", delegator, val.Address)
+	}
+
+	// Values are replaced, never mutated: validator copies share them
+	stake, totalShares := orZero(val.Stake), orZero(val.DelegatorShares)
+	amount := new(big.Int).Mul(shares, stake)
+	amount.Quo(amount, totalShares)
+	val.Stake = new(big.Int).Sub(stake, amount)
+	val.DelegatorShares = new(big.Int).Sub(totalShares, shares)
+	delegation.Shares = new(big.Int).Sub(delegation.Shares, shares)
+	if delegation.Shares.Sign() == 0 {
+		delete(c.delegations, key)
+	}
+
+	entry := UnbondingEntry{
+		Delegator:      delegator,
+		Validator:      val.Address,
+		CreationHeight: c.CurrentHeight,
+		CompletionTime: now.Add(c.UnbondingPeriod).Unix(),
+		Amount:         amount,
+	}
+	c.unbonding = append(c.unbonding, entry)
+	return entry, nil
+}
+
+// addShares credits shares to a delegation, creating it if needed
+func (c *Consensus) addShares(delegator, validator []byte, shares *big.Int) *Delegation {
+	key := delegationKey(delegator, validator)
+	delegation, ok := c.delegations[key]
+	if !ok {
+		delegation = &Delegation{Delegator: delegator, Validator: validator}
+		c.delegations[key] = delegation
+	}
+	delegation.Shares = new(big.Int).Add(orZero(delegation.Shares), shares)
+	return delegation
+}
+
+// queueChange queues a validator set change for the next epoch boundary
+func (c *Consensus) queueChange(kind changeKind, val Validator) {
+	c.pendingChanges = append(c.pendingChanges, validatorChange{Kind: kind, Validator: val})
+}
+
+// pendingAdd reports whether a validator is queued to join the set
+func (c *Consensus) pendingAdd(address []byte) bool {
+	for _, change := range c.pendingChanges {
+		if change.Kind == changeAdd && bytes.Equal(change.Validator.Address, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyEpochChanges applies queued validator set changes at an epoch boundary
+func (c *Consensus) applyEpochChanges(height int64, now time.Time) {
+	if len(c.pendingChanges) == 0 {
+		return
+	}
+
+	for _, change := range c.pendingChanges {
+		address := change.Validator.Address
+		switch change.Kind {
+		case changeAdd:
+			if c.validatorIndex(address) < 0 {
+				c.ValidatorSet = append(c.ValidatorSet, change.Validator)
+				fmt.Printf("[CONSENSUS] Validator %x joined at height %d\n", address[:8], height)
+			}
+		case changeRemove:
+			c.removeValidator(address, now)
+		case changePower:
+			idx := c.validatorIndex(address)
+			if idx < 0 {
+				continue
+			}
+			val := &c.ValidatorSet[idx]
+			if orZero(val.Stake).Cmp(MinStake) < 0 {
+				c.removeValidator(address, now)
+				continue
+			}
+			if !val.Jailed {
+				val.Power = StakeToPower(val.Stake)
+			}
+		}
+	}
+	c.pendingChanges = nil
+
+	fmt.Printf("[CONSENSUS] Epoch %d: %d validators\n", height/c.EpochLength, len(c.ValidatorSet))
+}
+
+// removeValidator drops a validator from the set and unbonds all its delegations
+func (c *Consensus) removeValidator(address []byte, now time.Time) {
+	idx := c.validatorIndex(address)
+	if idx < 0 {
+		return
+	}
+	val := c.ValidatorSet[idx]
+
+	for _, delegation := range c.delegations {
+		if bytes.Equal(delegation.Validator, address) {
+			c.undelegate(&val, delegation.Delegator, delegation.Shares, now)
+		}
+	}
+
+	c.ValidatorSet = append(c.ValidatorSet[:idx], c.ValidatorSet[idx+1:]...)
+	fmt.Printf("[CONSENSUS] Removed validator: %x\n", address[:8])
+}
+
+// completeUnbonding releases unbonding entries that reached their completion time
+func (c *Consensus) completeUnbonding(now time.Time) {
+	remaining := c.unbonding[:0]
+	for _, entry := range c.unbonding {
+		if entry.CompletionTime <= now.Unix() {
+			key := hex.EncodeToString(entry.Delegator)
+			c.unbonded[key] = new(big.Int).Add(orZero(c.unbonded[key]), entry.Amount)
+			continue
+		}
+		remaining = append(remaining, entry)
+	}
+	c.unbonding = remaining
+}
+
+// slashUnbonding applies a slash to stake still unbonding from a validator
+func (c *Consensus) slashUnbonding(address []byte, bps uint64) *big.Int {
+	total := new(big.Int)
+	for i := range c.unbonding {
+		entry := &c.unbonding[i]
+		if !bytes.Equal(entry.Validator, address) {
+			continue
+		}
+		penalty := bpsOf(entry.Amount, bps)
+		if penalty.Cmp(entry.Amount) > 0 {
+			penalty.Set(entry.Amount)
+		}
+		entry.Amount = new(big.Int).Sub(entry.Amount, penalty)
+		total.Add(total, penalty)
+	}
+	return total
+}
+
+// now returns the time of the last committed block, which every node agrees on
+func (c *Consensus) now() time.Time {
+	if c.CurrentBlock != nil {
+		return c.CurrentBlock.Header.Time
+	}
+	return c.clock.Now()
+}
+
+// delegationKey identifies a delegation
+func delegationKey(delegator, validator []byte) string {
+	return fmt.Sprintf("%x/%x", delegator, validator)
+}
+
+// orZero returns an amount, or zero for an unset one
+func orZero(amount *big.Int) *big.Int {
+	if amount == nil {
+		return new(big.Int)
+	}
+	return amount
+}
+
+// toZEN returns an amount in whole ZEN, rounded down
+func toZEN(amount *big.Int) *big.Int {
+	return new(big.Int).Quo(orZero(amount), OneZEN)
+}
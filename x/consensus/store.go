@@ -0,0 +1,375 @@
+package consensus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/tendermint/tendermint/types"
+)
+
+// Store key prefixes
+var (
+	stateKey        = []byte("state")
+	blockKeyPrefix  = []byte("block/")
+	pohKeyPrefix    = []byte("poh/")
+	rewardKeyPrefix = []byte("reward/")
+)
+
+// BlockStore keeps committed blocks, the PoH sequence and the consensus
+// state on disk
+type BlockStore struct {
+	db *leveldb.DB
+}
+
+// storedBlock is a committed block with its commit certificate and the
+// validator set that signed it
+type storedBlock struct {
+	Block      *types.Block  `json:"block"`
+	Commit     *types.Commit `json:"commit"`
+	Validators []Validator   `json:"validators"`
+}
+
+// persistedState is the consensus state as of the last committed height
+type persistedState struct {
+	Height            int64                   `json:"height"`
+	Block             *types.Block            `json:"block"`
+	Commit            *types.Commit           `json:"commit"`
+	ValidatorSet      []Validator             `json:"validator_set"`
+	LastValidators    []Validator             `json:"last_validators"`
+	Committees        []Committee             `json:"committees"`
+	ShardHeads        [][]byte                `json:"shard_heads"`
+	VRFBeacon         []byte                  `json:"vrf_beacon"`
+	FinalityVotes     map[int64][]*types.Vote `json:"finality_votes"`
+	PendingEvidence   []Evidence              `json:"pending_evidence"`
+	CommittedEvidence map[string]int64        `json:"committed_evidence"`
+	CanonicalCommits  map[int64]*types.Commit `json:"canonical_commits"`
+	SigningInfos      map[string]*signingInfo `json:"signing_infos"`
+	UnjailQueue       [][]byte                `json:"unjail_queue"`
+	PendingChanges    []validatorChange       `json:"pending_changes"`
+	Delegations       map[string]*Delegation  `json:"delegations"`
+	Unbonding         []UnbondingEntry        `json:"unbonding"`
+	Unbonded          map[string]*big.Int     `json:"unbonded"`
+	Rewards           map[string]*big.Int     `json:"rewards"`
+}
+
+// OpenBlockStore opens or creates a block store in dir
+func OpenBlockStore(dir string) (*BlockStore, error) {
+	db, err := leveldb.OpenFile(dir, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open block store: %w", err)
+	}
+	return &BlockStore{db: db}, nil
+}
+
+// Close closes the store
+func (s *BlockStore) Close() error {
+	return s.db.Close()
+}
+
+// LoadBlock returns a committed block and its commit
+func (s *BlockStore) LoadBlock(height int64) (*types.Block, *types.Commit, error) {
+	stored, err := s.loadStoredBlock(height)
+	if err != nil {
+		return nil, nil, err
+	}
+	return stored.Block, stored.Commit, nil
+}
+
+// LoadValidators returns the validator set that signed a committed block
+func (s *BlockStore) LoadValidators(height int64) ([]Validator, error) {
+	stored, err := s.loadStoredBlock(height)
+	if err != nil {
+		return nil, err
+	}
+	return stored.Validators, nil
+}
+
+// loadStoredBlock reads a committed block entry
+func (s *BlockStore) loadStoredBlock(height int64) (*storedBlock, error) {
+	bz, err := s.db.Get(blockKey(height), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, fmt.Errorf("no block at height %d", height)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stored := &storedBlock{}
+	if err := json.Unmarshal(bz, stored); err != nil {
+		return nil, fmt.Errorf("corrupt block at height %d: %w", height, err)
+	}
+	return stored, nil
+}
+
+// loadRewardRecord returns the reward record of a committed height
+func (s *BlockStore) loadRewardRecord(height int64) (RewardRecord, error) {
+	var record RewardRecord
+	bz, err := s.db.Get(rewardKey(height), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return record, fmt.Errorf("no reward record at height %d", height)
+	}
+	if err != nil {
+		return record, err
+	}
+
+	if err := json.Unmarshal(bz, &record); err != nil {
+		return record, fmt.Errorf("corrupt reward record at height %d: %w", height, err)
+	}
+	return record, nil
+}
+
+// saveBlock atomically stores a committed block with its signers, the PoH
+// entries it added, its reward record and the resulting state
+func (s *BlockStore) saveBlock(block *types.Block, commit *types.Commit, validators []Validator, entries []ProofOfHistoryEntry, reward *RewardRecord, state *persistedState) error {
+	batch := new(leveldb.Batch)
+
+	bz, err := json.Marshal(storedBlock{Block: block, Commit: commit, Validators: validators})
+	if err != nil {
+		return err
+	}
+	batch.Put(blockKey(block.Header.Height), bz)
+
+	for _, entry := range entries {
+		bz, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		batch.Put(pohKey(entry.Index), bz)
+	}
+
+	if reward != nil {
+		bz, err := json.Marshal(reward)
+		if err != nil {
+			return err
+		}
+		batch.Put(rewardKey(reward.Height), bz)
+	}
+
+	bz, err = json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	batch.Put(stateKey, bz)
+
+	return s.db.Write(batch, &opt.WriteOptions{Sync: true})
+}
+
+// loadState returns the persisted state and PoH sequence, or nil state
+// for an empty store
+func (s *BlockStore) loadState() (*persistedState, []ProofOfHistoryEntry, error) {
+	bz, err := s.db.Get(stateKey, nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	state := &persistedState{}
+	if err := json.Unmarshal(bz, state); err != nil {
+		return nil, nil, fmt.Errorf("corrupt consensus state: %w", err)
+	}
+
+	sequence := make([]ProofOfHistoryEntry, 0, state.Height+1)
+	iter := s.db.NewIterator(util.BytesPrefix(pohKeyPrefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		var entry ProofOfHistoryEntry
+		if err := json.Unmarshal(iter.Value(), &entry); err != nil {
+			return nil, nil, fmt.Errorf("corrupt PoH entry: %w", err)
+		}
+		if entry.Index != uint64(len(sequence)) {
+			return nil, nil, fmt.Errorf("PoH sequence gap at index %d", len(sequence))
+		}
+		sequence = append(sequence, entry)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, nil, err
+	}
+	if int64(len(sequence)) != state.Height+1 {
+		return nil, nil, fmt.Errorf("PoH sequence has %d entries for height %d", len(sequence), state.Height)
+	}
+
+	return state, sequence, nil
+}
+
+// OpenStorage opens the block store and WAL in dir and restores the last
+// committed state. Call it before Start; the WAL is replayed on Start.
+func (c *Consensus) OpenStorage(dir string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	store, err := OpenBlockStore(filepath.Join(dir, "blockstore.db"))
+	if err != nil {
+		return err
+	}
+	wal, err := OpenWAL(filepath.Join(dir, "cs.wal"))
+	if err != nil {
+		store.Close()
+		return err
+	}
+
+	state, sequence, err := store.loadState()
+	if err != nil {
+		store.Close()
+		wal.Close()
+		return err
+	}
+	if state != nil {
+		// The stored sequence must still verify before it is trusted
+		if err := VerifyPoHEntries(sequence[0], sequence[1:], c.HashesPerTick, c.TicksPerEntry); err != nil {
+			store.Close()
+			wal.Close()
+			return fmt.Errorf("stored PoH sequence invalid: %w", err)
+		}
+		c.restoreState(state, sequence)
+		fmt.Printf("[CONSENSUS] Restored state at height %d\n", state.Height)
+	}
+
+	c.store = store
+	c.wal = wal
+	return nil
+}
+
+// LoadBlock returns a committed block and its commit from the block store
+func (c *Consensus) LoadBlock(height int64) (*types.Block, *types.Commit, error) {
+	c.mu.RLock()
+	store := c.store
+	c.mu.RUnlock()
+
+	if store == nil {
+		return nil, nil, fmt.Errorf("no block store configured")
+	}
+	return store.LoadBlock(height)
+}
+
+// LoadValidators returns the validator set that signed a committed block
+func (c *Consensus) LoadValidators(height int64) ([]Validator, error) {
+	c.mu.RLock()
+	store := c.store
+	c.mu.RUnlock()
+
+	if store == nil {
+		return nil, fmt.Errorf("no block store configured")
+	}
+	return store.LoadValidators(height)
+}
+
+// persist stores a committed block, the validators that signed it, its
+// reward record and the resulting state, then starts a fresh WAL for the
+// next height
+func (c *Consensus) persist(block *types.Block, commit *types.Commit, validators []Validator, reward *RewardRecord) {
+	if c.store == nil {
+		return
+	}
+
+	// The genesis entry is stored with the first block
+	entries := c.PoHSequence[len(c.PoHSequence)-1:]
+	if block.Header.Height == 1 {
+		entries = c.PoHSequence[:2]
+	}
+
+	if err := c.store.saveBlock(block, commit, validators, entries, reward, c.snapshotState()); err != nil {
+		fmt.Printf("[CONSENSUS] Failed to persist height %d: %v\n", block.Header.Height, err)
+		return
+	}
+	if err := c.wal.Reset(); err != nil {
+		fmt.Printf("[CONSENSUS] Failed to reset WAL: %v\n", err)
+	}
+}
+
+// snapshotState captures the state to persist
+func (c *Consensus) snapshotState() *persistedState {
+	c.muFinality.Lock()
+	finalityVotes := make(map[int64][]*types.Vote, len(c.FinalityVotes))
+	for height, votes := range c.FinalityVotes {
+		finalityVotes[height] = votes
+	}
+	c.muFinality.Unlock()
+
+	return &persistedState{
+		Height:            c.CurrentHeight,
+		Block:             c.CurrentBlock,
+		Commit:            c.Commit,
+		ValidatorSet:      c.ValidatorSet,
+		LastValidators:    c.lastValidators,
+		Committees:        c.Committees,
+		ShardHeads:        c.shardHeads,
+		VRFBeacon:         c.vrfBeacon,
+		FinalityVotes:     finalityVotes,
+		PendingEvidence:   c.pendingEvidence,
+		CommittedEvidence: c.committedEvidence,
+		CanonicalCommits:  c.canonicalCommits,
+		SigningInfos:      c.signingInfos,
+		UnjailQueue:       c.unjailQueue,
+		PendingChanges:    c.pendingChanges,
+		Delegations:       c.delegations,
+		Unbonding:         c.unbonding,
+		Unbonded:          c.unbonded,
+		Rewards:           c.rewards,
+	}
+}
+
+// restoreState loads a persisted state into the engine
+func (c *Consensus) restoreState(state *persistedState, sequence []ProofOfHistoryEntry) {
+	c.CurrentHeight = state.Height
+	c.CurrentBlock = state.Block
+	c.forkChoice = NewForkChoice(state.Block)
+	c.Commit = state.Commit
+	c.PoHSequence = sequence
+	c.ValidatorSet = state.ValidatorSet
+	c.lastValidators = state.LastValidators
+	c.Committees = state.Committees
+	c.shardPool = make(map[uint64]*ShardBlock)
+	if len(state.ShardHeads) == NumShards {
+		c.shardHeads = state.ShardHeads
+	}
+	c.vrfBeacon = state.VRFBeacon
+	c.pendingEvidence = state.PendingEvidence
+	c.unjailQueue = state.UnjailQueue
+	c.pendingChanges = state.PendingChanges
+	c.unbonding = state.Unbonding
+
+	if state.FinalityVotes != nil {
+		c.FinalityVotes = state.FinalityVotes
+	}
+	if state.CommittedEvidence != nil {
+		c.committedEvidence = state.CommittedEvidence
+	}
+	if state.CanonicalCommits != nil {
+		c.canonicalCommits = state.CanonicalCommits
+	}
+	if state.SigningInfos != nil {
+		c.signingInfos = state.SigningInfos
+	}
+	if state.Delegations != nil {
+		c.delegations = state.Delegations
+	}
+	if state.Unbonded != nil {
+		c.unbonded = state.Unbonded
+	}
+	if state.Rewards != nil {
+		c.rewards = state.Rewards
+	}
+}
+
+// blockKey returns the store key of a block
+func blockKey(height int64) []byte {
+	return []byte(fmt.Sprintf("%s%020d", blockKeyPrefix, height))
+}
+
+// rewardKey returns the store key of a block's reward record
+func rewardKey(height int64) []byte {
+	return []byte(fmt.Sprintf("%s%020d", rewardKeyPrefix, height))
+}
+
+// pohKey returns the store key of a PoH entry
+func pohKey(index uint64) []byte {
+	return []byte(fmt.Sprintf("%s%020d", pohKeyPrefix, index))
+}
@@ -0,0 +1,288 @@
+package consensus
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	"github.com/tendermint/tendermint/types"
+)
+
+// Domain separators for vote signatures and validator set hashes
+var (
+	voteSignDomain     = []byte("zen-vote")
+	validatorSetDomain = []byte("zen-validator-set")
+)
+
+// voteSet collects the votes of one type for a height and round,
+// tallied by voting power per block hash (nil votes under "")
+type voteSet struct {
+	height    int64
+	round     int32
+	msgType   tmproto.SignedMsgType
+	votes     map[string]*types.Vote // validator address -> vote
+	conflicts map[string]*types.Vote // validator address -> second, conflicting vote
+	byBlock   map[string]int64       // block hash -> power
+	sum       int64
+}
+
+// newVoteSet creates an empty vote set
+func newVoteSet(height int64, round int32, msgType tmproto.SignedMsgType) *voteSet {
+	return &voteSet{
+		height:    height,
+		round:     round,
+		msgType:   msgType,
+		votes:     make(map[string]*types.Vote),
+		conflicts: make(map[string]*types.Vote),
+		byBlock:   make(map[string]int64),
+	}
+}
+
+// addVote adds a verified vote with the validator's power.
+// Returns the earlier vote if the validator already voted for another block.
+// The first such conflicting vote still counts toward its own block, so
+// nodes that received an equivocator's votes in different orders still see
+// the same polkas. Quorum intersection keeps two blocks from both passing
+// 2/3 while fewer than 1/3 equivocate.
+func (vs *voteSet) addVote(vote *types.Vote, power int64) (*types.Vote, error) {
+	if vote.Height != vs.height || vote.Round != vs.round || vote.Type != vs.msgType {
+		return nil, fmt.Errorf("vote for %d/%d/%d added to set %d/%d/%d",
+			vote.Height, vote.Round, vote.Type, vs.height, vs.round, vs.msgType)
+	}
+
+	key := string(vote.ValidatorAddress)
+	if existing, ok := vs.votes[key]; ok {
+		if bytes.Equal(existing.BlockID.Hash, vote.BlockID.Hash) {
+			return nil, nil
+		}
+		if conflict, ok := vs.conflicts[key]; !ok {
+			vs.conflicts[key] = vote
+			vs.byBlock[string(vote.BlockID.Hash)] += power
+		} else if bytes.Equal(conflict.BlockID.Hash, vote.BlockID.Hash) {
+			return nil, nil
+		}
+		return existing, fmt.Errorf("conflicting vote from %x", vote.ValidatorAddress)
+	}
+
+	vs.votes[key] = vote
+	vs.byBlock[string(vote.BlockID.Hash)] += power
+	vs.sum += power
+	return nil, nil
+}
+
+// twoThirdsMajority returns the block hash holding more than 2/3 of total power.
+// A nil hash with ok set means +2/3 voted nil.
+func (vs *voteSet) twoThirdsMajority(total int64) ([]byte, bool) {
+	for hash, power := range vs.byBlock {
+		if hasTwoThirds(power, total) {
+			if hash == "" {
+				return nil, true
+			}
+			return []byte(hash), true
+		}
+	}
+	return nil, false
+}
+
+// hasTwoThirdsFor reports whether more than 2/3 of total power voted for hash
+func (vs *voteSet) hasTwoThirdsFor(hash []byte, total int64) bool {
+	return hasTwoThirds(vs.byBlock[string(hash)], total)
+}
+
+// hasTwoThirdsAny reports whether more than 2/3 of total power voted at all
+func (vs *voteSet) hasTwoThirdsAny(total int64) bool {
+	return hasTwoThirds(vs.sum, total)
+}
+
+// hasOneThirdAny reports whether more than 1/3 of total power voted at all
+func (vs *voteSet) hasOneThirdAny(total int64) bool {
+	return vs.sum*3 > total
+}
+
+// hasTwoThirds reports whether power is more than 2/3 of total
+func hasTwoThirds(power, total int64) bool {
+	return total > 0 && power*3 > total*2
+}
+
+// VoteSignBytes returns the bytes a validator signs for a vote on a chain.
+// The chain ID keeps a vote from verifying on another network; the
+// validator index is excluded so commit signatures can be re-checked from a
+// CommitSig alone.
+func VoteSignBytes(chainID string, vote *types.Vote) []byte {
+	buf := make([]byte, 0, len(voteSignDomain)+1+len(chainID)+1+8+4+8+1+len(vote.BlockID.Hash)+len(vote.ValidatorAddress))
+	buf = append(buf, voteSignDomain...)
+	buf = append(buf, byte(len(chainID)))
+	buf = append(buf, chainID...)
+	buf = append(buf, byte(vote.Type))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(vote.Height))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(vote.Round))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(vote.Timestamp.UnixNano()))
+	buf = append(buf, byte(len(vote.BlockID.Hash)))
+	buf = append(buf, vote.BlockID.Hash...)
+	buf = append(buf, vote.ValidatorAddress...)
+	return buf
+}
+
+// VerifyVoteSignature checks a vote signature on a chain against an ed25519
+// public key
+func VerifyVoteSignature(chainID string, pubKey []byte, vote *types.Vote) error {
+	if len(pubKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key for %x", vote.ValidatorAddress)
+	}
+	if !ed25519.Verify(pubKey, VoteSignBytes(chainID, vote), vote.Signature) {
+		return fmt.Errorf("invalid vote signature from %x", vote.ValidatorAddress)
+	}
+	return nil
+}
+
+// VotingPower returns the voting power of a validator
+func VotingPower(val Validator) int64 {
+	if val.Jailed || val.Power < 0 {
+		return 0
+	}
+	if val.Power > MaxValidatorPower {
+		return MaxValidatorPower
+	}
+	return val.Power
+}
+
+// TotalVotingPower returns the voting power of a validator set
+func TotalVotingPower(validators []Validator) int64 {
+	var total int64
+	for _, val := range validators {
+		total += VotingPower(val)
+	}
+	return total
+}
+
+// CommitVote reconstructs the precommit behind a commit signature
+func CommitVote(commit *types.Commit, idx int) *types.Vote {
+	sig := commit.Signatures[idx]
+
+	vote := &types.Vote{
+		Type:             tmproto.PrecommitType,
+		Height:           commit.Height,
+		Round:            commit.Round,
+		Timestamp:        sig.Timestamp,
+		ValidatorAddress: sig.ValidatorAddress,
+		ValidatorIndex:   int32(idx),
+		Signature:        sig.Signature,
+	}
+	if sig.BlockIDFlag == types.BlockIDFlagCommit {
+		vote.BlockID = commit.BlockID
+	}
+	return vote
+}
+
+// VerifyCommit checks that more than 2/3 of the voting power of validators
+// signed a precommit on chainID for the commit's block. Signatures must be
+// listed in validator set order, one per validator.
+func VerifyCommit(chainID string, validators []Validator, commit *types.Commit) error {
+	if commit == nil {
+		return fmt.Errorf("missing commit")
+	}
+	if len(commit.Signatures) != len(validators) {
+		return fmt.Errorf("commit has %d signatures for %d validators",
+			len(commit.Signatures), len(validators))
+	}
+	if len(commit.BlockID.Hash) == 0 {
+		return fmt.Errorf("commit for nil block")
+	}
+
+	var signed int64
+	for i, sig := range commit.Signatures {
+		if sig.BlockIDFlag != types.BlockIDFlagCommit {
+			continue
+		}
+
+		val := validators[i]
+		if !bytes.Equal(sig.ValidatorAddress, val.Address) {
+			return fmt.Errorf("commit signature %d from %x, expected %x", i, sig.ValidatorAddress, val.Address)
+		}
+		if err := VerifyVoteSignature(chainID, val.PubKey, CommitVote(commit, i)); err != nil {
+			return err
+		}
+		signed += VotingPower(val)
+	}
+
+	total := TotalVotingPower(validators)
+	if !hasTwoThirds(signed, total) {
+		return fmt.Errorf("insufficient voting power in commit: %d/%d", signed, total)
+	}
+
+	return nil
+}
+
+// ValidatorSetHash returns the hash a header commits to for the validator
+// set that signs it: each validator's address, public key and voting
+// power, in set order
+func ValidatorSetHash(validators []Validator) []byte {
+	h := sha256.New()
+	h.Write(validatorSetDomain)
+	for _, val := range validators {
+		h.Write([]byte{byte(len(val.Address))})
+		h.Write(val.Address)
+		h.Write([]byte{byte(len(val.PubKey))})
+		h.Write(val.PubKey)
+		h.Write(binary.BigEndian.AppendUint64(nil, uint64(VotingPower(val))))
+	}
+	return h.Sum(nil)
+}
+
+// makeCommit builds a commit certificate from the precommits of a round
+func makeCommit(validators []Validator, precommits *voteSet, blockID types.BlockID) *types.Commit {
+	commit := &types.Commit{
+		Height:     precommits.height,
+		Round:      precommits.round,
+		BlockID:    blockID,
+		Signatures: make([]types.CommitSig, len(validators)),
+	}
+
+	for i, val := range validators {
+		vote, ok := precommits.votes[string(val.Address)]
+		if conflict := precommits.conflicts[string(val.Address)]; conflict != nil && bytes.Equal(conflict.BlockID.Hash, blockID.Hash) {
+			vote = conflict
+		}
+		if !ok {
+			commit.Signatures[i] = types.CommitSig{BlockIDFlag: types.BlockIDFlagAbsent}
+			continue
+		}
+
+		flag := types.BlockIDFlagNil
+		if bytes.Equal(vote.BlockID.Hash, blockID.Hash) {
+			flag = types.BlockIDFlagCommit
+		}
+		commit.Signatures[i] = types.CommitSig{
+			BlockIDFlag:      flag,
+			ValidatorAddress: vote.ValidatorAddress,
+			Timestamp:        vote.Timestamp,
+			Signature:        vote.Signature,
+		}
+	}
+
+	return commit
+}
+
+// signVote creates a vote signed with the local validator key.
+// Returns nil when this node is not a validator.
+func (c *Consensus) signVote(msgType tmproto.SignedMsgType, height int64, round int32, hash []byte) *types.Vote {
+	idx := c.validatorIndex(c.selfAddress)
+	if c.signKey == nil || idx < 0 {
+		return nil
+	}
+
+	vote := &types.Vote{
+		Type:             msgType,
+		Height:           height,
+		Round:            round,
+		BlockID:          types.BlockID{Hash: hash},
+		Timestamp:        c.clock.Now(),
+		ValidatorAddress: c.selfAddress,
+		ValidatorIndex:   int32(idx),
+	}
+	vote.Signature = ed25519.Sign(c.signKey, VoteSignBytes(c.ChainID, vote))
+	return vote
+}
@@ -0,0 +1,142 @@
+package fees
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// EIP-1559 defaults
+const (
+	DefaultElasticityMultiplier     = 2 // Gas limit over gas target
+	DefaultBaseFeeChangeDenominator = 8 // Bounds a block's base fee change to 1/8
+)
+
+// ParentBlock is the gas usage of the block a base fee is derived from
+type ParentBlock struct {
+	Height   int64  `json:"height"`
+	GasLimit uint64 `json:"gas_limit"`
+	GasUsed  uint64 `json:"gas_used"`
+	BaseFee  uint64 `json:"base_fee"` // Zero for genesis
+}
+
+// elasticity returns the configured elasticity multiplier or its default
+func (c FeeConfig) elasticity() uint64 {
+	if c.ElasticityMultiplier == 0 {
+		return DefaultElasticityMultiplier
+	}
+	return c.ElasticityMultiplier
+}
+
+// changeDenominator returns the configured max change denominator or its
+// default
+func (c FeeConfig) changeDenominator() uint64 {
+	if c.BaseFeeChangeDenominator == 0 {
+		return DefaultBaseFeeChangeDenominator
+	}
+	return c.BaseFeeChangeDenominator
+}
+
+// nextBaseFee moves the parent's base fee toward the gas target as in
+// EIP-1559. The configured BaseFee is the floor.
+func nextBaseFee(config FeeConfig, parent ParentBlock) uint64 {
+	if parent.BaseFee == 0 {
+		return config.BaseFee
+	}
+	target := parent.GasLimit / config.elasticity()
+	return adjustBaseFee(parent.BaseFee, parent.GasUsed, target, config.BaseFee, config.changeDenominator())
+}
+
+// adjustBaseFee raises or lowers a base fee by the share its block's usage
+// missed the target by, divided by denominator, never below floor
+func adjustBaseFee(baseFee, used, target, floor, denominator uint64) uint64 {
+	if target == 0 || used == target {
+		return max(baseFee, floor)
+	}
+
+	// delta = baseFee * |used - target| / target / denominator
+	base := new(big.Int).SetUint64(baseFee)
+	var diff uint64
+	if used > target {
+		diff = used - target
+	} else {
+		diff = target - used
+	}
+	delta := new(big.Int).Mul(base, new(big.Int).SetUint64(diff))
+	delta.Div(delta, new(big.Int).SetUint64(target))
+	delta.Div(delta, new(big.Int).SetUint64(denominator))
+
+	if used > target {
+		// A full block always raises the fee, by at least 1 wei
+		if delta.Sign() == 0 {
+			delta.SetUint64(1)
+		}
+		next := base.Add(base, delta)
+		if !next.IsUint64() {
+			return ^uint64(0)
+		}
+		return max(next.Uint64(), floor)
+	}
+	next := base.Sub(base, delta).Uint64()
+	return max(next, floor)
+}
+
+// NextBaseFee returns the base fee of the block after parent
+func (f *Fees) NextBaseFee(parent ParentBlock) uint64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return nextBaseFee(f.config, parent)
+}
+
+// UpdateBaseFee advances the current base fee past a committed block and
+// returns it
+func (f *Fees) UpdateBaseFee(parent ParentBlock) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if parent.GasUsed > parent.GasLimit {
+		return 0, fmt.Errorf("block %d used %d gas over its %d limit", parent.Height, parent.GasUsed, parent.GasLimit)
+	}
+	f.baseFee = nextBaseFee(f.config, parent)
+	return f.baseFee, nil
+}
+
+// BaseFee returns the base fee of the next block
+func (f *Fees) BaseFee() uint64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.currentBaseFee()
+}
+
+// currentBaseFee returns the dynamic base fee under the EIP-1559 model,
+// the execution gas fee under the multi-dimensional model and the
+// configured one otherwise
+func (f *Fees) currentBaseFee() uint64 {
+	switch f.feeModel {
+	case Solidity:
+		return max(f.baseFee, f.config.BaseFee)
+	case MultiDimensional:
+		return f.dimensionFee(ResourceGas)
+	default:
+		return f.config.BaseFee
+	}
+}
+
+// SetFeeModel selects the fee model
+func (f *Fees) SetFeeModel(model FeeModel) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.feeModel = model
+	f.recordSettings()
+	fmt.Printf("[FEES] Fee model set to %s\n", f.getFeeModelName())
+}
+
+// FeeModel returns the selected fee model
+func (f *Fees) FeeModel() FeeModel {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.feeModel
+}
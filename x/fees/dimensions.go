@@ -0,0 +1,171 @@
+package fees
+
+import "fmt"
+
+// Resource is a dimension of the multi-dimensional fee market
+type Resource int
+
+const (
+	ResourceGas           Resource = iota // Execution gas
+	ResourceStateBytes                    // Bytes of state growth
+	ResourceCalldataBytes                 // Bytes of calldata
+	ResourceCrossShard                    // Cross-shard messages sent
+	NumResources
+)
+
+var resourceNames = [NumResources]string{"execution_gas", "state_bytes", "calldata_bytes", "cross_shard_messages"}
+
+// String returns the resource name
+func (r Resource) String() string {
+	if r < 0 || r >= NumResources {
+		return fmt.Sprintf("resource(%d)", int(r))
+	}
+	return resourceNames[r]
+}
+
+// MarshalText encodes the resource by name
+func (r Resource) MarshalText() ([]byte, error) {
+	if r < 0 || r >= NumResources {
+		return nil, fmt.Errorf("unknown resource %d", int(r))
+	}
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText decodes a resource name
+func (r *Resource) UnmarshalText(text []byte) error {
+	for i, name := range resourceNames {
+		if string(text) == name {
+			*r = Resource(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown resource %q", text)
+}
+
+// Resources holds an amount of every resource
+type Resources [NumResources]uint64
+
+// DimensionParams prices a resource. Its base fee moves toward the target
+// usage per block as in EIP-1559; blocks may use the target times the
+// elasticity multiplier.
+type DimensionParams struct {
+	BaseFee uint64 `json:"base_fee"` // Minimum wei per unit
+	Target  uint64 `json:"target"`   // Units per block
+}
+
+// DimensionFee is what a transaction pays for one resource
+type DimensionFee struct {
+	Resource Resource `json:"resource"`
+	Units    uint64   `json:"units"`
+	BaseFee  uint64   `json:"base_fee"` // Wei per unit, with any surcharge
+	Amount   uint64   `json:"amount"`
+}
+
+// Default per-block targets and minimum prices of the resources other
+// than gas, whose price is the configured base fee
+const (
+	DefaultGasTarget           = 50000000      // Half the block gas limit
+	DefaultStateBytesFee       = 1000000000000 // 250 gas per byte at 4 gwei
+	DefaultStateBytesTarget    = 1024 * 1024
+	DefaultCalldataBytesFee    = 64000000000 // 16 gas per byte at 4 gwei
+	DefaultCalldataBytesTarget = 2 * 1024 * 1024
+	DefaultCrossShardFee       = 100000000000000 // 25000 gas per message at 4 gwei
+	DefaultCrossShardTarget    = 1000
+)
+
+// DefaultDimensions returns the default resource prices for a gas base fee
+func DefaultDimensions(baseFee uint64) [NumResources]DimensionParams {
+	return [NumResources]DimensionParams{
+		ResourceGas:           {BaseFee: baseFee, Target: DefaultGasTarget},
+		ResourceStateBytes:    {BaseFee: DefaultStateBytesFee, Target: DefaultStateBytesTarget},
+		ResourceCalldataBytes: {BaseFee: DefaultCalldataBytesFee, Target: DefaultCalldataBytesTarget},
+		ResourceCrossShard:    {BaseFee: DefaultCrossShardFee, Target: DefaultCrossShardTarget},
+	}
+}
+
+// dimension returns the configured parameters of a resource, filling unset
+// fields from the defaults
+func (c FeeConfig) dimension(r Resource) DimensionParams {
+	params := c.Dimensions[r]
+	defaults := DefaultDimensions(c.BaseFee)[r]
+	if params.BaseFee == 0 {
+		params.BaseFee = defaults.BaseFee
+	}
+	if params.Target == 0 {
+		params.Target = defaults.Target
+	}
+	return params
+}
+
+// dimensionFee returns the current base fee of a resource
+func (f *Fees) dimensionFee(r Resource) uint64 {
+	return max(f.dimensionFees[r], f.config.dimension(r).BaseFee)
+}
+
+// DimensionBaseFees returns the current base fee of every resource
+func (f *Fees) DimensionBaseFees() Resources {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var fees Resources
+	for r := Resource(0); r < NumResources; r++ {
+		fees[r] = f.dimensionFee(r)
+	}
+	return fees
+}
+
+// UpdateDimensionFees moves every resource's base fee past a committed
+// block that used the given amounts, and returns the new fees
+func (f *Fees) UpdateDimensionFees(used Resources) (Resources, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	elasticity, denominator := f.config.elasticity(), f.config.changeDenominator()
+	for r := Resource(0); r < NumResources; r++ {
+		params := f.config.dimension(r)
+		if limit := params.Target * elasticity; used[r] > limit {
+			return Resources{}, fmt.Errorf("block used %d %s over its %d limit", used[r], r, limit)
+		}
+	}
+
+	var next Resources
+	for r := Resource(0); r < NumResources; r++ {
+		params := f.config.dimension(r)
+		f.dimensionFees[r] = adjustBaseFee(f.dimensionFee(r), used[r], params.Target, params.BaseFee, denominator)
+		next[r] = f.dimensionFees[r]
+	}
+	return next, nil
+}
+
+// CalculateResourceFee prices a transaction's usage of every resource at
+// that resource's base fee and combines them into one fee with a
+// per-resource breakdown. The tip and priority fee are per unit of gas.
+func (f *Fees) CalculateResourceFee(usage Resources, tip uint64, txType string) (*Fee, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.feeModel != MultiDimensional {
+		return nil, fmt.Errorf("resource fees need the multi-dimensional fee model")
+	}
+	fee, err := f.calculateFee(usage[ResourceGas], tip, txType, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	fee.Dimensions = []DimensionFee{{Resource: ResourceGas}}
+	for r := ResourceGas + 1; r < NumResources; r++ {
+		if usage[r] == 0 {
+			continue
+		}
+		price := f.dimensionFee(r)
+		amount, err := gasCost(usage[r], price)
+		if err != nil {
+			return nil, err
+		}
+		fee.Dimensions = append(fee.Dimensions, DimensionFee{Resource: r, Units: usage[r], BaseFee: price, Amount: amount})
+	}
+	if err := f.chargeGas(f.current(), fee, fee.GasLimit); err != nil {
+		return nil, err
+	}
+	return fee, nil
+}
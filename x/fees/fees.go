@@ -1,40 +1,62 @@
 package fees
 
 import (
+	"context"
 	"fmt"
+	"math/big"
+	"math/bits"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/zennetwork/zennetwork/x/service"
 )
 
-// FeeConfig holds fee configuration
+// FeeConfig holds fee configuration. Fees and tips are prices in wei per
+// gas.
 type FeeConfig struct {
-	BaseFee      uint64  `json:"base_fee"`       // 0.0001 ZEN (in wei)
+	BaseFee      uint64  `json:"base_fee"`       // 4 gwei, <0.0001 ZEN per transfer
 	BurnPercent  int     `json:"burn_percent"`   // 20%
 	MinTip       uint64  `json:"min_tip"`        // 0
-	MaxTip       uint64  `json:"max_tip"`        // 0.001 ZEN
+	MaxTip       uint64  `json:"max_tip"`        // 1 gwei
 	PriorityFee  uint64  `json:"priority_fee"`   // Optional priority
-	MaxFee       uint64  `json:"max_fee"`        // 0.01 ZEN
+	MaxFee       uint64  `json:"max_fee"`        // 100 gwei, all prices combined
+	Surcharges   map[string]uint64 `json:"surcharges"` // Opcode class -> percent of the base fee added per gas
+	ElasticityMultiplier     uint64 `json:"elasticity_multiplier"`       // EIP-1559 gas limit over target, 0 for the default
+	BaseFeeChangeDenominator uint64 `json:"base_fee_change_denominator"` // EIP-1559 max change per block, 0 for the default
+	Dimensions   [NumResources]DimensionParams `json:"dimensions"` // Multi-dimensional resource prices, zero fields for the defaults
+	LocalMarkets LocalFeeParams `json:"local_markets"` // Per-shard and hot account fee floors, zero fields for the defaults
+	CommitteeSharePercent int `json:"committee_share_percent"` // Validator share of block fees split among the committee
 }
 
+// TransferGas is the gas a plain transfer uses
+const TransferGas = 21000
+
 // FeeModel represents different fee models
 type FeeModel int
 
 const (
-	Solidity   FeeModel = iota
+	Solidity   FeeModel = iota // EIP-1559 base fee following block congestion
 	Priority          // Priority-based (Solana-style)
 	MultiDimensional  // Different fees for different operations
 )
 
-// Fee represents a transaction fee
+// Fee represents a transaction fee. Prices are per gas; Total, Burned,
+// Validator and Refund are amounts for the gas used.
 type Fee struct {
 	BaseFee     uint64 `json:"base_fee"`
 	Tip         uint64 `json:"tip"`
 	PriorityFee uint64 `json:"priority_fee"`
+	Surcharge   uint64 `json:"surcharge"` // Opcode class surcharge per gas
+	GasLimit    uint64 `json:"gas_limit"`
+	GasUsed     uint64 `json:"gas_used"`
 	Total       uint64 `json:"total"`
 	Burned      uint64 `json:"burned"`
 	Validator   uint64 `json:"validator"`
+	Refund      uint64 `json:"refund"` // Returned for unused gas
+	Dimensions  []DimensionFee `json:"dimensions,omitempty"` // Per-resource breakdown under the multi-dimensional model
 }
 
 // Transaction represents a transaction with fees
@@ -49,13 +71,14 @@ type Transaction struct {
 	BlockNumber  int64         `json:"block_number"`
 	Timestamp    int64         `json:"timestamp"`
 	TxType       string        `json:"tx_type"` // transfer, contract, etc.
+	FeePayer     common.Address `json:"fee_payer,omitempty"` // Sponsor paying the fee, zero for From
 }
 
 // FeeStats tracks fee statistics
 type FeeStats struct {
-	TotalFees      uint64  `json:"total_fees"`
-	TotalBurned    uint64  `json:"total_burned"`
-	TotalToValidators uint64 `json:"total_to_validators"`
+	TotalFees      *big.Int `json:"total_fees"`
+	TotalBurned    *big.Int `json:"total_burned"`
+	TotalToValidators *big.Int `json:"total_to_validators"`
 	AvgFee         uint64  `json:"avg_fee"`
 	MedianFee      uint64  `json:"median_fee"`
 	MinFee         uint64  `json:"min_fee"`
@@ -63,16 +86,26 @@ type FeeStats struct {
 	FeeTPS         float64 `json:"fee_tps"` // Fees per second
 	TotalTx        int64   `json:"total_tx"`
 	BurnRate       float64 `json:"burn_rate"` // Tokens burned per second
+	P10            uint64  `json:"p10"`
+	P50            uint64  `json:"p50"`
+	P90            uint64  `json:"p90"`
+	P99            uint64  `json:"p99"`
+	Windows        map[string]FeeSummary `json:"windows"`    // Window name -> fees in it
+	ByType         map[string]FeeSummary `json:"by_type"`    // TxType -> fees of that type
+	Dimensions     map[string]uint64 `json:"dimensions"` // Resource -> fees paid for it
 }
 
 // FeeTracker tracks fee-related metrics
 type FeeTracker struct {
 	mu              sync.RWMutex
-	transactions    []Transaction
-	feesCollected   uint64
-	tokensBurned    uint64
-	revenueSplit    map[common.Address]uint64 // Validator revenue
-	lastUpdate      time.Time
+	history         *feeRing // Recent fees for percentiles and windows
+	totalTx         int64
+	totalFees       *big.Int
+	dimensions      map[string]uint64 // Resource -> fees paid for it
+	feesCollected   *big.Int
+	tokensBurned    *big.Int
+	revenueSplit    map[common.Address]*big.Int // Validator revenue
+	cached          *FeeStats // Stats since the last settled block, nil until asked for
 }
 
 // Fees handles the low-fee model with burn mechanism
@@ -83,61 +116,63 @@ type Fees struct {
 	running      bool
 	burnEnabled  bool
 	feeModel     FeeModel
+	baseFee      uint64 // EIP-1559 base fee of the next block
+	dimensionFees Resources // Multi-dimensional base fees of the next block
+	local        *localMarkets
+	burner       Burner
+	blocks       []blockFees // Recently settled blocks, for the fee oracle
+	allowances   map[sponsorship]*Allowance
+	height       int64 // Last block height fee proposals were tallied at
+	proposals    []*FeeProposal
+	versions     []FeeConfigVersion // Fee configs by the height they came into force
+	baseFees     map[int64]uint64   // Base fee of every settled block, to recompute fees under old versions
 }
 
 // New creates a new Fees instance
 func New() *Fees {
-	return &Fees{
-		config: FeeConfig{
-			BaseFee:      100000000000000, // 0.0001 ZEN (in wei)
-			BurnPercent:  20,              // 20% burned
-			MinTip:       0,               // No minimum tip
-			MaxTip:       1000000000000,   // 0.001 ZEN max tip
-			PriorityFee:  0,               // Optional
-			MaxFee:       10000000000000,  // 0.01 ZEN max
-		},
-		tracker:     &FeeTracker{
-			revenueSplit: make(map[common.Address]uint64),
-		},
-		running:     false,
-		burnEnabled: true,
-		feeModel:    Priority,
-	}
+	return NewWithConfig(FeeConfig{
+		BaseFee:     4000000000,   // 4 gwei, 0.000084 ZEN per transfer
+		BurnPercent: 20,           // 20% burned
+		MinTip:      0,            // No minimum tip
+		MaxTip:      1000000000,   // 1 gwei max tip
+		PriorityFee: 0,            // Optional
+		MaxFee:      100000000000, // 100 gwei max
+	})
 }
 
 // NewWithConfig creates Fees with custom configuration
 func NewWithConfig(config FeeConfig) *Fees {
 	return &Fees{
 		config: config,
-		tracker: &FeeTracker{
-			revenueSplit: make(map[common.Address]uint64),
-		},
+		versions: []FeeConfigVersion{{Config: copyFeeConfig(config), Model: Priority, BurnEnabled: true}},
+		baseFees: make(map[int64]uint64),
+		tracker:     newFeeTracker(),
 		running:     false,
 		burnEnabled: true,
 		feeModel:    Priority,
+		local:       newLocalMarkets(),
+		allowances:  make(map[sponsorship]*Allowance),
 	}
 }
 
-// Start initializes the fee system
-func (f *Fees) Start() error {
+var _ service.Service = (*Fees)(nil)
+
+// Start initializes the fee system. It runs no goroutines.
+func (f *Fees) Start(ctx context.Context) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
 	fmt.Println("[FEES] Initializing low-fee system")
-	fmt.Printf("  - Base Fee: %d ZEN (%.6f ZEN)\n",
-		f.config.BaseFee, float64(f.config.BaseFee)/1e18)
-	fmt.Printf("  - Burn Rate: %d%% (%d ZEN burned per tx)\n",
-		f.config.BurnPercent, uint64(float64(f.config.BaseFee)*float64(f.config.BurnPercent)/100)/1e18)
-	fmt.Printf("  - Max Tip: %d ZEN (%.6f ZEN)\n",
-		f.config.MaxTip, float64(f.config.MaxTip)/1e18)
-	fmt.Printf("  - Max Fee: %d ZEN (%.6f ZEN)\n",
-		f.config.MaxFee, float64(f.config.MaxFee)/1e18)
+	fmt.Printf("  - Base Fee: %d wei/gas (%.6f ZEN per transfer)\n",
+		f.config.BaseFee, float64(f.config.BaseFee*TransferGas)/1e18)
+	fmt.Printf("  - Burn Rate: %d%% of the base fee\n", f.config.BurnPercent)
+	fmt.Printf("  - Max Tip: %d wei/gas\n", f.config.MaxTip)
+	fmt.Printf("  - Max Fee: %d wei/gas\n", f.config.MaxFee)
 	fmt.Printf("  - Fee Model: %s\n", f.getFeeModelName())
 	fmt.Printf("  - Target: <0.0001 ZEN per transaction\n")
 	fmt.Printf("  - Comparison: 100x cheaper than Ethereum\n")
 
 	f.running = true
-	f.tracker.lastUpdate = time.Now()
 
 	fmt.Println("✓ Low-fee system initialized")
 
@@ -159,108 +194,196 @@ func (f *Fees) Stop() error {
 	return nil
 }
 
-// CalculateFee calculates transaction fee
+// CalculateFee calculates the fee a transaction prepays: its whole gas
+// limit at the base fee, tip and surcharge of its opcode class. SettleFee
+// refunds the gas it leaves unused.
 func (f *Fees) CalculateFee(gasLimit uint64, tip uint64, txType string) (*Fee, error) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 
+	return f.calculateFee(gasLimit, tip, txType, nil)
+}
+
+// calculateFee prices gasLimit at the current prices, adding the local fee
+// floor of the touched accounts to the priority fee
+func (f *Fees) calculateFee(gasLimit uint64, tip uint64, txType string, accounts []common.Address) (*Fee, error) {
+	return f.priceFee(f.current(), f.currentBaseFee(), f.localFloor(accounts), gasLimit, tip, txType)
+}
+
+// priceFee prices a transaction under a fee config version, at a base fee
+// and a local fee floor
+func (f *Fees) priceFee(version FeeConfigVersion, baseFee, localFloor, gasLimit, tip uint64, txType string) (*Fee, error) {
+	config := version.Config
 	if !f.running {
 		return nil, fmt.Errorf("fee system not running")
 	}
+	if gasLimit == 0 {
+		return nil, fmt.Errorf("gas limit cannot be zero")
+	}
 
 	// Check tip limits
-	if tip < f.config.MinTip {
-		tip = f.config.MinTip
-	}
-	if tip > f.config.MaxTip {
-		tip = f.config.MaxTip
+	if tip < config.MinTip {
+		tip = config.MinTip
 	}
-
-	// Calculate base fee
-	baseFee := f.config.BaseFee
-
-	// Apply transaction type modifiers
-	switch txType {
-	case "contract_deploy":
-		// Higher fee for contract deployment
-		baseFee = baseFee * 3
-	case "contract_call":
-		// Medium fee for contract calls
-		baseFee = baseFee * 2
-	case "transfer":
-		// Base fee for transfers
-		break
-	case "nft_mint":
-		// NFT mints have dynamic pricing
-		baseFee = baseFee * 2
-	case "defi_swap":
-		// DeFi swaps have premium pricing
-		baseFee = baseFee * 5
+	if tip > config.MaxTip {
+		tip = config.MaxTip
 	}
 
-	// Priority fee (optional)
-	priorityFee := f.config.PriorityFee
+	// Apply the opcode class surcharge, if one is configured
+	surcharge := baseFee * config.Surcharges[txType] / 100
 
-	// Total fee
-	total := baseFee + tip + priorityFee
+	// Priority fee (optional), at least the local fee floor
+	priorityFee := config.PriorityFee + localFloor
 
 	// Check max fee
-	if total > f.config.MaxFee {
-		return nil, fmt.Errorf("fee exceeds maximum: %d > %d", total, f.config.MaxFee)
+	price := baseFee + surcharge + tip + priorityFee
+	if price > config.MaxFee {
+		return nil, fmt.Errorf("fee exceeds maximum: %d > %d per gas", price, config.MaxFee)
 	}
 
-	// Calculate burn amount
-	burned := uint64(float64(baseFee) * float64(f.config.BurnPercent) / 100.0)
-
-	// Validator gets the rest
-	validator := total - burned
-
-	return &Fee{
+	fee := &Fee{
 		BaseFee:     baseFee,
 		Tip:         tip,
 		PriorityFee: priorityFee,
-		Total:       total,
-		Burned:      burned,
-		Validator:   validator,
-	}, nil
+		Surcharge:   surcharge,
+		GasLimit:    gasLimit,
+	}
+	if version.Model == MultiDimensional {
+		fee.Dimensions = []DimensionFee{{Resource: ResourceGas}}
+	}
+	if err := f.chargeGas(version, fee, gasLimit); err != nil {
+		return nil, err
+	}
+	return fee, nil
 }
 
-// ProcessTransaction processes a transaction and updates metrics
-func (f *Fees) ProcessTransaction(tx *Transaction) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+// SettleFee charges a prepaid fee for the gas a transaction used in the
+// block at height, under the version in force there, and refunds the rest
+// of its gas limit
+func (f *Fees) SettleFee(height int64, prepaid *Fee, gasUsed uint64) (*Fee, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 
-	// Add to tracker
-	f.tracker.transactions = append(f.tracker.transactions, *tx)
+	if gasUsed > prepaid.GasLimit {
+		return nil, fmt.Errorf("gas used %d exceeds limit %d", gasUsed, prepaid.GasLimit)
+	}
+	fee := *prepaid
+	if err := f.chargeGas(f.versionAt(height), &fee, gasUsed); err != nil {
+		return nil, err
+	}
+	fee.Refund = prepaid.Total - fee.Total
+	return &fee, nil
+}
 
-	// Update metrics
-	f.tracker.feesCollected += tx.Fee.Total
-	f.tracker.tokensBurned += tx.Fee.Burned
+// CheckFee checks a prepaid fee pays at least the current prices of its
+// opcode class and the touched accounts, with a tip in the allowed range
+func (f *Fees) CheckFee(fee *Fee, txType string, accounts ...common.Address) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 
-	// Update validator revenue
-	// In production: distribute to actual block proposer
-	f.tracker.revenueSplit[tx.From] += tx.Fee.Validator
+	if fee.Tip < f.config.MinTip || fee.Tip > f.config.MaxTip {
+		return fmt.Errorf("tip %d outside %d-%d wei/gas", fee.Tip, f.config.MinTip, f.config.MaxTip)
+	}
+	required, err := f.calculateFee(fee.GasLimit, fee.Tip, txType, accounts)
+	if err != nil {
+		return err
+	}
+	if fee.BaseFee < required.BaseFee || fee.Surcharge < required.Surcharge || fee.PriorityFee < required.PriorityFee {
+		return fmt.Errorf("fee of %d wei below the %d wei required", fee.Total, required.Total)
+	}
+	return nil
+}
 
-	// Keep only recent transactions
-	if len(f.tracker.transactions) > 10000 {
-		f.tracker.transactions = f.tracker.transactions[1:]
+// chargeGas sets the amounts of a fee for gasUsed at its prices, plus the
+// other resources of its breakdown. The burn share applies to the base fees
+// and surcharge, unless the version disables burning; tips go to the
+// validator.
+func (f *Fees) chargeGas(version FeeConfigVersion, fee *Fee, gasUsed uint64) error {
+	total, err := gasCost(gasUsed, fee.BaseFee+fee.Surcharge+fee.Tip+fee.PriorityFee)
+	if err != nil {
+		return err
 	}
+	protocol, err := gasCost(gasUsed, fee.BaseFee+fee.Surcharge)
+	if err != nil {
+		return err
+	}
+	dimensions := make([]DimensionFee, len(fee.Dimensions))
+	for i, dim := range fee.Dimensions {
+		if dim.Resource == ResourceGas {
+			dim = DimensionFee{Resource: ResourceGas, Units: gasUsed, BaseFee: fee.BaseFee + fee.Surcharge, Amount: protocol}
+		} else {
+			if protocol+dim.Amount < protocol || total+dim.Amount < total {
+				return fmt.Errorf("fee for %d %s overflows", dim.Units, dim.Resource)
+			}
+			protocol += dim.Amount
+			total += dim.Amount
+		}
+		dimensions[i] = dim
+	}
+	fee.Dimensions = dimensions
+
+	fee.GasUsed = gasUsed
+	fee.Total = total
+	fee.Burned = 0
+	if version.BurnEnabled {
+		fee.Burned = protocol / 100 * uint64(version.Config.BurnPercent)
+		fee.Burned += protocol % 100 * uint64(version.Config.BurnPercent) / 100
+	}
+	fee.Validator = total - fee.Burned
+	fee.Refund = 0
+	return nil
+}
+
+// gasCost multiplies gas by a per-gas price, failing on overflow
+func gasCost(gas, price uint64) (uint64, error) {
+	hi, lo := bits.Mul64(gas, price)
+	if hi != 0 {
+		return 0, fmt.Errorf("fee for %d gas at %d wei overflows", gas, price)
+	}
+	return lo, nil
+}
 
+// DefaultSurcharges returns surcharges matching the former flat multiples
+// of the base fee per transaction type
+func DefaultSurcharges() map[string]uint64 {
+	return map[string]uint64{
+		"contract_deploy": 200,
+		"contract_call":   100,
+		"nft_mint":        100,
+		"defi_swap":       400,
+	}
+}
+
+// ProcessTransaction checks a transaction's fee adds up. Its fee enters
+// the statistics when SettleBlock pays it out once its block commits.
+func (f *Fees) ProcessTransaction(tx *Transaction) error {
+	if tx.Fee.Burned > tx.Fee.Total || tx.Fee.Validator != tx.Fee.Total-tx.Fee.Burned {
+		return fmt.Errorf("tx %s fee splits %d burned and %d to validators of %d",
+			tx.Hash.Hex(), tx.Fee.Burned, tx.Fee.Validator, tx.Fee.Total)
+	}
 	return nil
 }
 
-// GetFeeForTransactionType returns fee for a specific transaction type
+// GetFeeForTransactionType returns the fee of a transfer-sized transaction
+// of a type
 func (f *Fees) GetFeeForTransactionType(txType string) (uint64, error) {
-	fee, err := f.CalculateFee(21000, 0, txType)
+	fee, err := f.CalculateFee(TransferGas, 0, txType)
 	if err != nil {
 		return 0, err
 	}
 	return fee.Total, nil
 }
 
-// EstimateFee estimates fee for a transaction
-func (f *Fees) EstimateFee(gasLimit uint64, txType string) (uint64, error) {
-	return f.CalculateFee(gasLimit, 0, txType)
+// EstimateFee estimates fee for a transaction, including the local fee
+// floor of the accounts it touches
+func (f *Fees) EstimateFee(gasLimit uint64, txType string, accounts ...common.Address) (uint64, error) {
+	f.mu.RLock()
+	fee, err := f.calculateFee(gasLimit, 0, txType, accounts)
+	f.mu.RUnlock()
+	if err != nil {
+		return 0, err
+	}
+	return fee.Total, nil
 }
 
 // GetCurrentFees returns current fee structure
@@ -268,97 +391,69 @@ func (f *Fees) GetCurrentFees() map[string]uint64 {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 
+	// Prices per gas, including each class's surcharge
+	baseFee := f.currentBaseFee()
+	price := func(class string) uint64 {
+		return baseFee + baseFee*f.config.Surcharges[class]/100
+	}
 	return map[string]uint64{
-		"transfer":       f.config.BaseFee,
-		"contract_call":  f.config.BaseFee * 2,
-		"contract_deploy": f.config.BaseFee * 3,
-		"nft_mint":       f.config.BaseFee * 2,
-		"defi_swap":      f.config.BaseFee * 5,
+		"transfer":       price("transfer"),
+		"contract_call":  price("contract_call"),
+		"contract_deploy": price("contract_deploy"),
+		"nft_mint":       price("nft_mint"),
+		"defi_swap":      price("defi_swap"),
 		"max_tip":        f.config.MaxTip,
 		"max_fee":        f.config.MaxFee,
 	}
 }
 
-// GetFeeStats returns fee statistics
+// GetFeeStats returns fee statistics: exact totals since start, and the
+// distribution of recent fees overall, per rolling window and per TxType.
+// They are computed once per settled block, with the windows ending at the
+// first call after it.
 func (f *Fees) GetFeeStats() *FeeStats {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 
-	if len(f.tracker.transactions) == 0 {
-		return &FeeStats{}
-	}
-
-	var totalFees, minFee, maxFee, medianFee uint64
-	var fees []uint64
-
-	for _, tx := range f.tracker.transactions {
-		totalFees += tx.Fee.Total
-
-		if minFee == 0 || tx.Fee.Total < minFee {
-			minFee = tx.Fee.Total
-		}
-		if tx.Fee.Total > maxFee {
-			maxFee = tx.Fee.Total
-		}
-
-		fees = append(fees, tx.Fee.Total)
-	}
-
-	// Calculate median
-	medianFee = calculateMedian(fees)
-
-	// Calculate TPS
-	timeDiff := time.Since(f.tracker.lastUpdate).Seconds()
-	feeTPS := float64(len(f.tracker.transactions)) / timeDiff
-
-	// Calculate burn rate
-	burnRate := float64(f.tracker.tokensBurned) / timeDiff
+	f.tracker.mu.Lock()
+	defer f.tracker.mu.Unlock()
 
-	return &FeeStats{
-		TotalFees:       totalFees,
-		TotalBurned:     f.tracker.tokensBurned,
-		TotalToValidators: f.tracker.feesCollected - f.tracker.tokensBurned,
-		AvgFee:          totalFees / uint64(len(f.tracker.transactions)),
-		MedianFee:       medianFee,
-		MinFee:          minFee,
-		MaxFee:          maxFee,
-		FeeTPS:          feeTPS,
-		TotalTx:         int64(len(f.tracker.transactions)),
-		BurnRate:        burnRate,
+	if f.tracker.cached == nil {
+		f.tracker.cached = f.feeStats(time.Now())
 	}
+	return f.tracker.cached.copy()
 }
 
 // GetRevenueSplit returns validator revenue distribution
-func (f *Fees) GetRevenueSplit() map[common.Address]uint64 {
+func (f *Fees) GetRevenueSplit() map[common.Address]*big.Int {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 
-	split := make(map[common.Address]uint64)
+	split := make(map[common.Address]*big.Int)
 	for addr, amount := range f.tracker.revenueSplit {
-		split[addr] = amount
+		split[addr] = new(big.Int).Set(amount)
 	}
 
 	return split
 }
 
-// SetFeeConfig updates fee configuration
+// SetFeeConfig sets the genesis fee configuration. Once blocks have been
+// tallied, changes go through SubmitFeeProposal.
 func (f *Fees) SetFeeConfig(config FeeConfig) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	// Validate configuration
-	if config.BaseFee == 0 {
-		return fmt.Errorf("base fee cannot be zero")
+	// Later changes need a proposal
+	if f.height > 0 {
+		return fmt.Errorf("fee config is governed past genesis, submit a fee proposal")
 	}
-	if config.BurnPercent < 0 || config.BurnPercent > 100 {
-		return fmt.Errorf("burn percent must be 0-100")
-	}
-	if config.MinTip > config.MaxTip {
-		return fmt.Errorf("min tip cannot exceed max tip")
+	if err := validateFeeConfig(config); err != nil {
+		return err
 	}
 
-	f.config = config
-	fmt.Println("[FEES] Fee configuration updated")
+	f.config = copyFeeConfig(config)
+	f.versions[0].Config = copyFeeConfig(config)
+	fmt.Println("[FEES] Genesis fee configuration set")
 
 	return nil
 }
@@ -368,6 +463,7 @@ func (f *Fees) EnableBurn(enabled bool) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	f.burnEnabled = enabled
+	f.recordSettings()
 
 	if enabled {
 		fmt.Println("[FEES] Token burning enabled")
@@ -384,26 +480,26 @@ func (f *Fees) GetBurnStats() map[string]interface{} {
 	return map[string]interface{}{
 		"enabled":              f.burnEnabled,
 		"burn_percent":         f.config.BurnPercent,
-		"total_burned":         f.tracker.tokensBurned / 1e18,
-		"total_burned_wei":     f.tracker.tokensBurned,
-		"fees_collected":       f.tracker.feesCollected / 1e18,
-		"burn_rate_per_second": f.GetFeeStats().BurnRate / 1e18,
+		"total_burned":         new(big.Int).Quo(f.tracker.tokensBurned, big.NewInt(1e18)),
+		"total_burned_wei":     new(big.Int).Set(f.tracker.tokensBurned),
+		"fees_collected":       new(big.Int).Quo(f.tracker.feesCollected, big.NewInt(1e18)),
+		"burn_rate_per_second": f.burnRate(time.Now()) / 1e18,
 	}
 }
 
 // PrintFeeComparison prints comparison with other chains
 func (f *Fees) PrintFeeComparison() {
-	fmt.Println("\n" + "=".repeat(50))
+	fmt.Println("\n" + strings.Repeat("=", 50))
 	fmt.Println("Fee Comparison: ZenNetwork vs Other Chains")
-	fmt.Println("=".repeat(50))
+	fmt.Println(strings.Repeat("=", 50))
 	fmt.Printf("ZenNetwork:   < 0.0001 ZEN (~$0.001)\n")
 	fmt.Printf("Ethereum:     ~0.002 ETH (~$5-50)\n")
 	fmt.Printf("Bitcoin:      ~0.0001 BTC (~$4-10)\n")
 	fmt.Printf("Solana:       ~0.00001 SOL (~$0.001)\n")
 	fmt.Printf("Binance Smart Chain: ~0.0005 BNB (~$0.15)\n")
-	fmt.Println("=".repeat(50))
+	fmt.Println(strings.Repeat("=", 50))
 	fmt.Println("ZenNetwork offers 100-50,000x lower fees!")
-	fmt.Println("=".repeat(50) + "\n")
+	fmt.Println(strings.Repeat("=", 50) + "\n")
 }
 
 // getFeeModelName returns fee model name
@@ -420,21 +516,6 @@ func (f *Fees) getFeeModelName() string {
 	}
 }
 
-// calculateMedian calculates median from slice
-func calculateMedian(fees []uint64) uint64 {
-	if len(fees) == 0 {
-		return 0
-	}
-
-	// Simple median calculation
-	// In production: use more efficient algorithm
-	var sum uint64
-	for _, fee := range fees {
-		sum += fee
-	}
-	return sum / uint64(len(fees))
-}
-
 // SimulateTransaction simulates fee calculation
 func (f *Fees) SimulateTransaction(txType string, gasLimit uint64) error {
 	fee, err := f.CalculateFee(gasLimit, 0, txType)
@@ -444,8 +525,9 @@ func (f *Fees) SimulateTransaction(txType string, gasLimit uint64) error {
 
 	fmt.Printf("\n[FEES] Transaction Simulation: %s\n", txType)
 	fmt.Printf("  Gas Limit: %d\n", gasLimit)
-	fmt.Printf("  Base Fee: %.6f ZEN\n", float64(fee.BaseFee)/1e18)
-	fmt.Printf("  Tip: %.6f ZEN\n", float64(fee.Tip)/1e18)
+	fmt.Printf("  Base Fee: %d wei/gas\n", fee.BaseFee)
+	fmt.Printf("  Surcharge: %d wei/gas\n", fee.Surcharge)
+	fmt.Printf("  Tip: %d wei/gas\n", fee.Tip)
 	fmt.Printf("  Total: %.6f ZEN\n", float64(fee.Total)/1e18)
 	fmt.Printf("  Burned: %.6f ZEN (%.0f%%)\n", float64(fee.Burned)/1e18, float64(f.config.BurnPercent))
 	fmt.Printf("  To Validator: %.6f ZEN\n\n", float64(fee.Validator)/1e18)
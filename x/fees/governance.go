@@ -0,0 +1,312 @@
+package fees
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/zennetwork/zennetwork/x/consensus"
+)
+
+// feeVoteDomain separates fee vote signatures from other signatures
+var feeVoteDomain = []byte("zen-fee-vote")
+
+// ProposalStatus is where a fee proposal is in its lifecycle
+type ProposalStatus string
+
+// Fee proposal statuses
+const (
+	ProposalPending   ProposalStatus = "pending"   // Collecting votes until its activation height
+	ProposalActivated ProposalStatus = "activated" // In force from its activation height
+	ProposalRejected  ProposalStatus = "rejected"  // Short of a quorum at its activation height
+)
+
+// FeeProposal proposes a fee configuration in force from an activation
+// height. It activates if validators holding over 2/3 of the voting power
+// at that height voted for it.
+type FeeProposal struct {
+	ID               uint64           `json:"id"`
+	Proposer         common.Address   `json:"proposer"`
+	Config           FeeConfig        `json:"config"`
+	ActivationHeight int64            `json:"activation_height"`
+	Reason           string           `json:"reason"`
+	Status           ProposalStatus   `json:"status"`
+	Votes            []common.Address `json:"votes"` // Validators in favour, in voting order
+}
+
+// SignBytes returns the bytes a validator signs to vote for the proposal.
+// They cover its config and activation height, so a vote cannot be moved to
+// another proposal.
+func (p *FeeProposal) SignBytes() []byte {
+	config, _ := json.Marshal(p.Config)
+	buf := make([]byte, 16)
+	binary.BigEndian.PutUint64(buf[:8], p.ID)
+	binary.BigEndian.PutUint64(buf[8:], uint64(p.ActivationHeight))
+
+	h := sha256.New()
+	h.Write(feeVoteDomain)
+	h.Write(buf)
+	h.Write(config)
+	return h.Sum(nil)
+}
+
+// FeeConfigVersion is a fee configuration, fee model and burn setting and
+// the first block they are in force at. Version 0 is the genesis one.
+type FeeConfigVersion struct {
+	Version     uint64    `json:"version"`
+	Height      int64     `json:"height"`
+	ProposalID  uint64    `json:"proposal_id"` // Zero for genesis and model or burn changes
+	Config      FeeConfig `json:"config"`
+	Model       FeeModel  `json:"model"`
+	BurnEnabled bool      `json:"burn_enabled"`
+}
+
+// validateFeeConfig checks a fee configuration is usable
+func validateFeeConfig(config FeeConfig) error {
+	if config.BaseFee == 0 {
+		return fmt.Errorf("base fee cannot be zero")
+	}
+	if config.BurnPercent < 0 || config.BurnPercent > 100 {
+		return fmt.Errorf("burn percent must be 0-100")
+	}
+	if config.MinTip > config.MaxTip {
+		return fmt.Errorf("min tip cannot exceed max tip")
+	}
+	if config.CommitteeSharePercent < 0 || config.CommitteeSharePercent > 100 {
+		return fmt.Errorf("committee share percent must be 0-100")
+	}
+	return nil
+}
+
+// SubmitFeeProposal proposes a fee configuration in force from a future
+// height and returns the proposal ID
+func (f *Fees) SubmitFeeProposal(proposer common.Address, config FeeConfig, activationHeight int64, reason string) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := validateFeeConfig(config); err != nil {
+		return 0, fmt.Errorf("invalid fee config: %w", err)
+	}
+	if activationHeight <= f.height {
+		return 0, fmt.Errorf("activation height %d is not after height %d", activationHeight, f.height)
+	}
+
+	id := uint64(len(f.proposals) + 1)
+	f.proposals = append(f.proposals, &FeeProposal{
+		ID:               id,
+		Proposer:         proposer,
+		Config:           copyFeeConfig(config),
+		ActivationHeight: activationHeight,
+		Reason:           reason,
+		Status:           ProposalPending,
+		Votes:            make([]common.Address, 0),
+	})
+	fmt.Printf("[FEES] Fee proposal %d submitted by %s, activating at height %d\n", id, proposer.Hex(), activationHeight)
+	return id, nil
+}
+
+// VoteFeeProposal records a validator's vote for a pending proposal,
+// signed over its SignBytes with the validator's ed25519 consensus key.
+// Only validators at the activation height count.
+func (f *Fees) VoteFeeProposal(id uint64, pubKey ed25519.PublicKey, signature []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	proposal, err := f.proposal(id)
+	if err != nil {
+		return err
+	}
+	if proposal.Status != ProposalPending {
+		return fmt.Errorf("fee proposal %d is %s", id, proposal.Status)
+	}
+	if len(pubKey) != ed25519.PublicKeySize || !ed25519.Verify(pubKey, proposal.SignBytes(), signature) {
+		return fmt.Errorf("invalid vote signature for fee proposal %d", id)
+	}
+	voter := common.BytesToAddress(consensus.ValidatorAddress(pubKey))
+	for _, v := range proposal.Votes {
+		if v == voter {
+			return fmt.Errorf("%s already voted for fee proposal %d", voter.Hex(), id)
+		}
+	}
+	proposal.Votes = append(proposal.Votes, voter)
+	return nil
+}
+
+// ActivateFeeProposals advances to a block height, tallying the pending
+// proposals due by it against the validators' voting power. Passed
+// proposals come into force from their activation heights, in ID order
+// among those due at the same height, so the last one due wins. It returns
+// the versions activated.
+func (f *Fees) ActivateFeeProposals(height int64, validators map[common.Address]uint64) []FeeConfigVersion {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if height <= f.height {
+		return nil
+	}
+	f.height = height
+
+	var total uint64
+	for _, power := range validators {
+		total += power
+	}
+
+	due := make([]*FeeProposal, 0)
+	for _, proposal := range f.proposals {
+		if proposal.Status == ProposalPending && proposal.ActivationHeight <= height {
+			due = append(due, proposal)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].ActivationHeight < due[j].ActivationHeight })
+
+	activated := make([]FeeConfigVersion, 0)
+	for _, proposal := range due {
+		var power uint64
+		for _, voter := range proposal.Votes {
+			power += validators[voter]
+		}
+		if total == 0 || power*3 <= total*2 {
+			proposal.Status = ProposalRejected
+			fmt.Printf("[FEES] Fee proposal %d rejected with %d of %d voting power\n", proposal.ID, power, total)
+			continue
+		}
+
+		proposal.Status = ProposalActivated
+		version := f.current()
+		version.Version = uint64(len(f.versions))
+		version.Height = proposal.ActivationHeight
+		version.ProposalID = proposal.ID
+		version.Config = copyFeeConfig(proposal.Config)
+		f.versions = append(f.versions, version)
+		f.config = copyFeeConfig(proposal.Config)
+		activated = append(activated, version)
+		fmt.Printf("[FEES] Fee config version %d from proposal %d in force from height %d\n", version.Version, proposal.ID, version.Height)
+	}
+	return activated
+}
+
+// FeeProposals returns every fee proposal
+func (f *Fees) FeeProposals() []FeeProposal {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	proposals := make([]FeeProposal, len(f.proposals))
+	for i, proposal := range f.proposals {
+		proposals[i] = *proposal
+		proposals[i].Config = copyFeeConfig(proposal.Config)
+		proposals[i].Votes = append([]common.Address{}, proposal.Votes...)
+	}
+	return proposals
+}
+
+// FeeConfigHistory returns every fee config version, oldest first
+func (f *Fees) FeeConfigHistory() []FeeConfigVersion {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	versions := make([]FeeConfigVersion, len(f.versions))
+	for i, version := range f.versions {
+		versions[i] = version
+		versions[i].Config = copyFeeConfig(version.Config)
+	}
+	return versions
+}
+
+// ConfigAt returns the fee config in force at a block height
+func (f *Fees) ConfigAt(height int64) FeeConfig {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return copyFeeConfig(f.versionAt(height).Config)
+}
+
+// versionAt returns the fee config version in force at a block height
+func (f *Fees) versionAt(height int64) FeeConfigVersion {
+	i := sort.Search(len(f.versions), func(i int) bool { return f.versions[i].Height > height })
+	if i == 0 {
+		return f.versions[0]
+	}
+	return f.versions[i-1]
+}
+
+// current returns the fee config, fee model and burn setting in force now
+func (f *Fees) current() FeeConfigVersion {
+	return FeeConfigVersion{Config: f.config, Model: f.feeModel, BurnEnabled: f.burnEnabled}
+}
+
+// recordSettings records a fee model or burn change: in the genesis version
+// until blocks are tallied, then in a new version from the current height
+func (f *Fees) recordSettings() {
+	if f.height == 0 {
+		f.versions[0].Model, f.versions[0].BurnEnabled = f.feeModel, f.burnEnabled
+		return
+	}
+	version := f.current()
+	version.Version = uint64(len(f.versions))
+	version.Height = f.height
+	version.Config = copyFeeConfig(f.config)
+	f.versions = append(f.versions, version)
+}
+
+// CalculateFeeAt calculates a transaction's fee under the version in force
+// at a block height, so a historical fee recomputes the same. Under the
+// dynamic fee models the base fee is the block's, which must be a settled
+// block or the next one.
+func (f *Fees) CalculateFeeAt(height int64, gasLimit uint64, tip uint64, txType string) (*Fee, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	baseFee, err := f.baseFeeAt(height)
+	if err != nil {
+		return nil, err
+	}
+	return f.priceFee(f.versionAt(height), baseFee, 0, gasLimit, tip, txType)
+}
+
+// baseFeeAt returns the base fee of a block
+func (f *Fees) baseFeeAt(height int64) (uint64, error) {
+	version := f.versionAt(height)
+	var floor uint64
+	switch version.Model {
+	case Solidity:
+		floor = version.Config.BaseFee
+	case MultiDimensional:
+		floor = version.Config.dimension(ResourceGas).BaseFee
+	default:
+		return version.Config.BaseFee, nil
+	}
+	if len(f.blocks) == 0 || height > f.blocks[len(f.blocks)-1].height {
+		return max(f.currentBaseFee(), floor), nil
+	}
+	baseFee, ok := f.baseFees[height]
+	if !ok {
+		return 0, fmt.Errorf("block %d was not settled", height)
+	}
+	return max(baseFee, floor), nil
+}
+
+// proposal returns a fee proposal by ID
+func (f *Fees) proposal(id uint64) (*FeeProposal, error) {
+	if id == 0 || id > uint64(len(f.proposals)) {
+		return nil, fmt.Errorf("unknown fee proposal %d", id)
+	}
+	return f.proposals[id-1], nil
+}
+
+// copyFeeConfig copies a fee config so later changes to its maps do not
+// alter a stored version
+func copyFeeConfig(config FeeConfig) FeeConfig {
+	if config.Surcharges != nil {
+		surcharges := make(map[string]uint64, len(config.Surcharges))
+		for class, percent := range config.Surcharges {
+			surcharges[class] = percent
+		}
+		config.Surcharges = surcharges
+	}
+	return config
+}
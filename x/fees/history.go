@@ -0,0 +1,186 @@
+package fees
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Fee oracle defaults. The tip oracle samples like go-ethereum's gas price
+// oracle: the lowest tips of each recent block, at a percentile.
+const (
+	DefaultFeeHistoryBlocks    = 1024 // Settled blocks kept, and the most one query returns
+	DefaultTipOracleBlocks     = 20   // Recent blocks the tip oracle samples
+	DefaultTipOracleSamples    = 3    // Lowest tips sampled per block
+	DefaultTipOraclePercentile = 60
+)
+
+// FeeHistory is the result of eth_feeHistory. BaseFee has an entry for
+// every block and one for the block after the newest; Reward has the
+// requested tip percentiles of every block, weighted by gas used.
+type FeeHistory struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+	BaseFee      []*hexutil.Big   `json:"baseFeePerGas,omitempty"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+// blockFees is what the fee oracle keeps of a settled block
+type blockFees struct {
+	height   int64
+	baseFee  uint64
+	gasUsed  uint64
+	gasLimit uint64
+	tips     []txTip // Ascending by tip
+}
+
+// txTip is a transaction's tip per gas and the gas it used
+type txTip struct {
+	tip     uint64
+	gasUsed uint64
+}
+
+// recordBlockFees adds a settled block to the fee history
+func (f *Fees) recordBlockFees(height int64, baseFee, gasLimit uint64, txs []*Transaction) {
+	block := blockFees{
+		height:   height,
+		baseFee:  baseFee,
+		gasLimit: gasLimit,
+		tips:     make([]txTip, 0, len(txs)),
+	}
+	for _, tx := range txs {
+		block.gasUsed += tx.Fee.GasUsed
+		block.tips = append(block.tips, txTip{tip: tx.Fee.Tip + tx.Fee.PriorityFee, gasUsed: tx.Fee.GasUsed})
+	}
+	sort.Slice(block.tips, func(i, j int) bool { return block.tips[i].tip < block.tips[j].tip })
+
+	f.baseFees[height] = baseFee
+	f.blocks = append(f.blocks, block)
+	if len(f.blocks) > DefaultFeeHistoryBlocks {
+		f.blocks = f.blocks[1:]
+	}
+}
+
+// FeeHistory returns the fees of up to blockCount settled blocks ending at
+// lastBlock, or at the newest one when lastBlock is negative, as
+// eth_feeHistory does. Percentiles must be 0-100 and ascending.
+func (f *Fees) FeeHistory(blockCount uint64, lastBlock int64, rewardPercentiles []float64) (*FeeHistory, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for i, p := range rewardPercentiles {
+		if p < 0 || p > 100 {
+			return nil, fmt.Errorf("invalid reward percentile %f", p)
+		}
+		if i > 0 && p < rewardPercentiles[i-1] {
+			return nil, fmt.Errorf("reward percentiles not ascending: %f after %f", p, rewardPercentiles[i-1])
+		}
+	}
+	history := &FeeHistory{OldestBlock: (*hexutil.Big)(new(big.Int)), GasUsedRatio: make([]float64, 0)}
+	if blockCount == 0 || len(f.blocks) == 0 {
+		return history, nil
+	}
+
+	newest := f.blocks[len(f.blocks)-1].height
+	if lastBlock < 0 {
+		lastBlock = newest
+	}
+	if lastBlock > newest {
+		return nil, fmt.Errorf("block %d is not settled yet, the newest is %d", lastBlock, newest)
+	}
+	end := sort.Search(len(f.blocks), func(i int) bool { return f.blocks[i].height > lastBlock })
+	if end == 0 || f.blocks[end-1].height != lastBlock {
+		return nil, fmt.Errorf("block %d is not in the fee history", lastBlock)
+	}
+	start := end - int(min(blockCount, uint64(end)))
+	blocks := f.blocks[start:end]
+
+	history.OldestBlock = (*hexutil.Big)(big.NewInt(blocks[0].height))
+	history.BaseFee = make([]*hexutil.Big, 0, len(blocks)+1)
+	if len(rewardPercentiles) > 0 {
+		history.Reward = make([][]*hexutil.Big, 0, len(blocks))
+	}
+	for _, block := range blocks {
+		history.BaseFee = append(history.BaseFee, hexBig(block.baseFee))
+		history.GasUsedRatio = append(history.GasUsedRatio, float64(block.gasUsed)/float64(block.gasLimit))
+		if len(rewardPercentiles) > 0 {
+			history.Reward = append(history.Reward, block.rewards(rewardPercentiles))
+		}
+	}
+	if end < len(f.blocks) {
+		history.BaseFee = append(history.BaseFee, hexBig(f.blocks[end].baseFee))
+	} else {
+		history.BaseFee = append(history.BaseFee, hexBig(f.followingBaseFee(blocks[len(blocks)-1])))
+	}
+	return history, nil
+}
+
+// rewards returns the tips at the given percentiles of the block's gas,
+// as eth_feeHistory computes them
+func (b blockFees) rewards(percentiles []float64) []*hexutil.Big {
+	rewards := make([]*hexutil.Big, len(percentiles))
+	if len(b.tips) == 0 {
+		for i := range rewards {
+			rewards[i] = hexBig(0)
+		}
+		return rewards
+	}
+
+	index, sum := 0, b.tips[0].gasUsed
+	for i, p := range percentiles {
+		threshold := uint64(float64(b.gasUsed) * p / 100)
+		for sum < threshold && index < len(b.tips)-1 {
+			index++
+			sum += b.tips[index].gasUsed
+		}
+		rewards[i] = hexBig(b.tips[index].tip)
+	}
+	return rewards
+}
+
+// followingBaseFee returns the base fee of the block after a settled one
+func (f *Fees) followingBaseFee(block blockFees) uint64 {
+	version := f.versionAt(block.height + 1)
+	switch version.Model {
+	case Solidity:
+		return nextBaseFee(version.Config, ParentBlock{Height: block.height, GasLimit: block.gasLimit, GasUsed: block.gasUsed, BaseFee: block.baseFee})
+	case MultiDimensional:
+		params := version.Config.dimension(ResourceGas)
+		return adjustBaseFee(block.baseFee, block.gasUsed, params.Target, params.BaseFee, version.Config.changeDenominator())
+	default:
+		return version.Config.BaseFee
+	}
+}
+
+// SuggestTip returns a tip per gas likely to get a transaction included
+// soon: a percentile of the lowest tips in recent blocks, within the
+// configured tip bounds. It is the eth_maxPriorityFeePerGas value.
+func (f *Fees) SuggestTip() uint64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	tips := make([]uint64, 0, DefaultTipOracleBlocks*DefaultTipOracleSamples)
+	for _, block := range f.blocks[max(len(f.blocks)-DefaultTipOracleBlocks, 0):] {
+		for _, tx := range block.tips[:min(len(block.tips), DefaultTipOracleSamples)] {
+			tips = append(tips, tx.tip)
+		}
+	}
+	if len(tips) == 0 {
+		return f.config.MinTip
+	}
+	sort.Slice(tips, func(i, j int) bool { return tips[i] < tips[j] })
+	tip := tips[(len(tips)-1)*DefaultTipOraclePercentile/100]
+	return min(max(tip, f.config.MinTip), f.config.MaxTip)
+}
+
+// MaxPriorityFeePerGas returns the suggested tip as eth_maxPriorityFeePerGas
+// encodes it
+func (f *Fees) MaxPriorityFeePerGas() *hexutil.Big {
+	return hexBig(f.SuggestTip())
+}
+
+func hexBig(v uint64) *hexutil.Big {
+	return (*hexutil.Big)(new(big.Int).SetUint64(v))
+}
@@ -0,0 +1,159 @@
+package fees
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Local fee market defaults
+const (
+	DefaultLocalShards         = 64                                    // Execution shards
+	DefaultShardGasTarget      = DefaultGasTarget / DefaultLocalShards // Gas per shard per block
+	DefaultHotAccountGasTarget = DefaultGasTarget / 10                 // Gas per account per block
+	DefaultLocalFloorStep      = 100000000                             // 0.1 gwei, the first floor of a congested market
+)
+
+// LocalFeeParams configures the local fee markets. A shard, or a tracked
+// hot account, that uses more gas per block than its target gets a priority
+// fee floor that rises while it stays congested and decays once it cools
+// down, so one hot contract does not raise fees for every other shard.
+type LocalFeeParams struct {
+	Shards              uint64 `json:"shards"`
+	TrackHotAccounts    bool   `json:"track_hot_accounts"` // Floors for single accounts as well as shards
+	ShardGasTarget      uint64 `json:"shard_gas_target"`
+	HotAccountGasTarget uint64 `json:"hot_account_gas_target"`
+	FloorStep           uint64 `json:"floor_step"` // Wei per gas
+}
+
+// localParams returns the configured local fee parameters, filling unset
+// fields from the defaults
+func (c FeeConfig) localParams() LocalFeeParams {
+	params := c.LocalMarkets
+	if params.Shards == 0 {
+		params.Shards = DefaultLocalShards
+	}
+	if params.ShardGasTarget == 0 {
+		params.ShardGasTarget = DefaultShardGasTarget
+	}
+	if params.HotAccountGasTarget == 0 {
+		params.HotAccountGasTarget = DefaultHotAccountGasTarget
+	}
+	if params.FloorStep == 0 {
+		params.FloorStep = DefaultLocalFloorStep
+	}
+	return params
+}
+
+// localMarkets holds the priority fee floors of congested shards and hot
+// accounts. Markets without a floor are not stored.
+type localMarkets struct {
+	shards   map[uint64]uint64
+	accounts map[common.Address]uint64
+}
+
+func newLocalMarkets() *localMarkets {
+	return &localMarkets{
+		shards:   make(map[uint64]uint64),
+		accounts: make(map[common.Address]uint64),
+	}
+}
+
+// AccountShard returns the execution shard of an account
+func AccountShard(addr common.Address, shards uint64) uint64 {
+	sum := sha256.Sum256(addr.Bytes())
+	return binary.BigEndian.Uint64(sum[24:]) % shards
+}
+
+// nextLocalFloor moves a market's floor by its usage against the target.
+// Congestion starts the floor at step; cooling below step clears it.
+func nextLocalFloor(floor, used, target, step, denominator uint64) uint64 {
+	if used > target && floor < step {
+		return step
+	}
+	next := adjustBaseFee(floor, used, target, 0, denominator)
+	if next < step {
+		return 0
+	}
+	return next
+}
+
+// UpdateLocalFees moves the local fee floors past a committed block, given
+// the gas of its transactions that touched each account. A shard's usage
+// is the gas touching its accounts.
+func (f *Fees) UpdateLocalFees(usage map[common.Address]uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	params := f.config.localParams()
+	denominator := f.config.changeDenominator()
+
+	shardUsed := make(map[uint64]uint64)
+	for addr, gas := range usage {
+		shardUsed[AccountShard(addr, params.Shards)] += gas
+	}
+	for shard := range f.local.shards {
+		if _, ok := shardUsed[shard]; !ok {
+			shardUsed[shard] = 0
+		}
+	}
+	for shard, used := range shardUsed {
+		if floor := nextLocalFloor(f.local.shards[shard], used, params.ShardGasTarget, params.FloorStep, denominator); floor > 0 {
+			f.local.shards[shard] = floor
+		} else {
+			delete(f.local.shards, shard)
+		}
+	}
+
+	if !params.TrackHotAccounts {
+		f.local.accounts = make(map[common.Address]uint64)
+		return
+	}
+	accounts := make(map[common.Address]uint64)
+	for addr := range f.local.accounts {
+		accounts[addr] = 0
+	}
+	for addr, gas := range usage {
+		accounts[addr] = gas
+	}
+	for addr, used := range accounts {
+		if floor := nextLocalFloor(f.local.accounts[addr], used, params.HotAccountGasTarget, params.FloorStep, denominator); floor > 0 {
+			f.local.accounts[addr] = floor
+		} else {
+			delete(f.local.accounts, addr)
+		}
+	}
+}
+
+// LocalFeeFloor returns the priority fee per gas a transaction touching
+// accounts must pay: the highest floor of their shards and of the accounts
+// themselves
+func (f *Fees) LocalFeeFloor(accounts ...common.Address) uint64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.localFloor(accounts)
+}
+
+// localFloor returns the local fee floor of touched accounts
+func (f *Fees) localFloor(accounts []common.Address) uint64 {
+	shards := f.config.localParams().Shards
+	var floor uint64
+	for _, addr := range accounts {
+		floor = max(floor, f.local.shards[AccountShard(addr, shards)], f.local.accounts[addr])
+	}
+	return floor
+}
+
+// HotAccounts returns the accounts with a local fee floor and their floors
+func (f *Fees) HotAccounts() map[common.Address]uint64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	hot := make(map[common.Address]uint64, len(f.local.accounts))
+	for addr, floor := range f.local.accounts {
+		hot[addr] = floor
+	}
+	return hot
+}
@@ -0,0 +1,137 @@
+package fees
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Fee credit roles
+const (
+	CreditProposer  = "proposer"
+	CreditCommittee = "committee"
+)
+
+// Burner destroys burned fees; x/tokenomics implements it
+type Burner interface {
+	BurnTokens(amount string, txHash common.Hash, reason string, block int64) error
+}
+
+// FeeCredit is an amount of a block's fees credited to a validator
+type FeeCredit struct {
+	Account common.Address `json:"account"`
+	Role    string         `json:"role"`
+	Amount  *big.Int       `json:"amount"`
+}
+
+// Settlement is how a block's fees were paid. Debits and Validators and
+// Burned each sum to Total; Credits sum to Validators.
+type Settlement struct {
+	Height     int64          `json:"height"`
+	Proposer   common.Address `json:"proposer"`
+	Total      *big.Int       `json:"total"`
+	Burned     *big.Int       `json:"burned"`
+	Validators *big.Int       `json:"validators"`
+	Credits    []FeeCredit    `json:"credits"`
+	Debits     []FeeDebit     `json:"debits"`
+}
+
+// SetBurner sets where settled burns go. Without one burned fees are only
+// withheld from validators.
+func (f *Fees) SetBurner(burner Burner) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.burner = burner
+}
+
+// SettleBlock charges the fees of a committed block's transactions to their
+// payers and pays them out. Sponsored fees are spent from the sponsors'
+// allowances; one its allowance does not cover is charged to the sender
+// instead. The burn share is burned through the burner, or paid to
+// validators where the block's fee version disables burning. Of the
+// validator share, CommitteeSharePercent is split equally among the
+// committee members and the rest goes to the proposer. All amounts are
+// exact. The block's base fee and gas limit go into the fee history.
+func (f *Fees) SettleBlock(height int64, baseFee, gasLimit uint64, proposer common.Address, committee []common.Address, txs []*Transaction) (*Settlement, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	settlement := &Settlement{
+		Height:     height,
+		Proposer:   proposer,
+		Total:      new(big.Int),
+		Burned:     new(big.Int),
+		Validators: new(big.Int),
+		Credits:    make([]FeeCredit, 0),
+		Debits:     make([]FeeDebit, 0),
+	}
+	version := f.versionAt(height)
+	spends, payers := f.sponsorSpends(txs)
+	for i, tx := range txs {
+		if tx.Fee.Burned > tx.Fee.Total {
+			return nil, fmt.Errorf("tx %s burns %d of a %d fee", tx.Hash.Hex(), tx.Fee.Burned, tx.Fee.Total)
+		}
+		settlement.Total.Add(settlement.Total, new(big.Int).SetUint64(tx.Fee.Total))
+		debit(settlement, payers[i], payers[i] != tx.From, tx.Fee.Total)
+		if version.BurnEnabled {
+			settlement.Burned.Add(settlement.Burned, new(big.Int).SetUint64(tx.Fee.Burned))
+		}
+	}
+	settlement.Validators.Sub(settlement.Total, settlement.Burned)
+
+	if settlement.Burned.Sign() > 0 && f.burner != nil {
+		if err := f.burner.BurnTokens(settlement.Burned.String(), common.Hash{}, "block fees", height); err != nil {
+			return nil, fmt.Errorf("burn failed: %w", err)
+		}
+	}
+
+	// Committee members split their share equally; dust goes to the proposer
+	pool := new(big.Int).Set(settlement.Validators)
+	if len(committee) > 0 && version.Config.CommitteeSharePercent > 0 {
+		share := new(big.Int).Mul(settlement.Validators, big.NewInt(int64(version.Config.CommitteeSharePercent)))
+		share.Quo(share, big.NewInt(100))
+		share.Quo(share, big.NewInt(int64(len(committee))))
+		for _, member := range committee {
+			f.credit(settlement, member, CreditCommittee, share)
+			pool.Sub(pool, share)
+		}
+	}
+	f.credit(settlement, proposer, CreditProposer, pool)
+
+	for _, tx := range txs {
+		if !version.BurnEnabled && tx.Fee.Burned > 0 {
+			// Nothing was burned; the stats must not say otherwise
+			unburned := *tx
+			unburned.Fee.Validator, unburned.Fee.Burned = tx.Fee.Total, 0
+			tx = &unburned
+		}
+		f.recordTransaction(tx)
+	}
+	for key, spent := range spends {
+		f.allowances[key].Spent += spent
+	}
+	f.recordBlockFees(height, baseFee, gasLimit, txs)
+	f.tracker.feesCollected.Add(f.tracker.feesCollected, settlement.Total)
+	f.tracker.tokensBurned.Add(f.tracker.tokensBurned, settlement.Burned)
+	f.tracker.cached = nil
+
+	fmt.Printf("[FEES] Settled block %d: %s wei to validators, %s wei burned\n",
+		height, settlement.Validators, settlement.Burned)
+	return settlement, nil
+}
+
+// credit pays a validator part of a block's fees
+func (f *Fees) credit(settlement *Settlement, account common.Address, role string, amount *big.Int) {
+	if amount.Sign() == 0 {
+		return
+	}
+	settlement.Credits = append(settlement.Credits, FeeCredit{Account: account, Role: role, Amount: new(big.Int).Set(amount)})
+	revenue, ok := f.tracker.revenueSplit[account]
+	if !ok {
+		revenue = new(big.Int)
+		f.tracker.revenueSplit[account] = revenue
+	}
+	revenue.Add(revenue, amount)
+}
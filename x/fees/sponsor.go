@@ -0,0 +1,212 @@
+package fees
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Allowance lets a sponsor pay the fees of a user's transactions, up to a
+// cap, until an expiry and optionally only for calls to some contracts
+type Allowance struct {
+	Sponsor   common.Address   `json:"sponsor"`
+	User      common.Address   `json:"user"`
+	Limit     uint64           `json:"limit"`               // Most wei of fees the sponsor pays
+	Spent     uint64           `json:"spent"`               // Wei of fees paid so far
+	Expiry    int64            `json:"expiry"`              // Unix time it lapses at, 0 for never
+	Contracts []common.Address `json:"contracts,omitempty"` // Allowed recipients, empty for any
+}
+
+// FeeDebit is an amount of a block's fees charged to an account
+type FeeDebit struct {
+	Account   common.Address `json:"account"`
+	Sponsored bool           `json:"sponsored"` // Paid for other senders
+	Amount    *big.Int       `json:"amount"`
+}
+
+// sponsorship keys an allowance
+type sponsorship struct {
+	sponsor common.Address
+	user    common.Address
+}
+
+// Payer returns who pays a transaction's fee: its fee payer, or its sender
+// when it has none
+func (tx *Transaction) Payer() common.Address {
+	if tx.FeePayer != (common.Address{}) {
+		return tx.FeePayer
+	}
+	return tx.From
+}
+
+// Sponsored reports whether someone other than the sender pays the fee
+func (tx *Transaction) Sponsored() bool {
+	return tx.FeePayer != (common.Address{}) && tx.FeePayer != tx.From
+}
+
+// GrantAllowance sets the allowance of a sponsor for a user, replacing any
+// earlier one. Restoring a stored allowance keeps what it has spent.
+func (f *Fees) GrantAllowance(allowance Allowance) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if allowance.Sponsor == (common.Address{}) || allowance.User == (common.Address{}) {
+		return fmt.Errorf("allowance needs a sponsor and a user")
+	}
+	if allowance.Sponsor == allowance.User {
+		return fmt.Errorf("%s cannot sponsor itself", allowance.Sponsor.Hex())
+	}
+	if allowance.Limit == 0 {
+		return fmt.Errorf("allowance limit cannot be zero")
+	}
+	if allowance.Spent > allowance.Limit {
+		return fmt.Errorf("allowance spent %d over its %d limit", allowance.Spent, allowance.Limit)
+	}
+
+	allowance.Contracts = append([]common.Address{}, allowance.Contracts...)
+	f.allowances[sponsorship{allowance.Sponsor, allowance.User}] = &allowance
+	fmt.Printf("[FEES] %s sponsors fees of %s up to %d wei\n",
+		allowance.Sponsor.Hex(), allowance.User.Hex(), allowance.Limit)
+	return nil
+}
+
+// RevokeAllowance removes the allowance of a sponsor for a user
+func (f *Fees) RevokeAllowance(sponsor, user common.Address) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := sponsorship{sponsor, user}
+	if _, ok := f.allowances[key]; !ok {
+		return fmt.Errorf("%s does not sponsor %s", sponsor.Hex(), user.Hex())
+	}
+	delete(f.allowances, key)
+	return nil
+}
+
+// GetAllowance returns the allowance of a sponsor for a user
+func (f *Fees) GetAllowance(sponsor, user common.Address) (Allowance, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	allowance, ok := f.allowances[sponsorship{sponsor, user}]
+	if !ok {
+		return Allowance{}, false
+	}
+	return copyAllowance(allowance), true
+}
+
+// Allowances returns every allowance, ordered by sponsor and user, to be
+// stored and restored with GrantAllowance
+func (f *Fees) Allowances() []Allowance {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	allowances := make([]Allowance, 0, len(f.allowances))
+	for _, allowance := range f.allowances {
+		allowances = append(allowances, copyAllowance(allowance))
+	}
+	sort.Slice(allowances, func(i, j int) bool {
+		if c := bytes.Compare(allowances[i].Sponsor.Bytes(), allowances[j].Sponsor.Bytes()); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(allowances[i].User.Bytes(), allowances[j].User.Bytes()) < 0
+	})
+	return allowances
+}
+
+func copyAllowance(allowance *Allowance) Allowance {
+	copied := *allowance
+	copied.Contracts = append([]common.Address{}, allowance.Contracts...)
+	return copied
+}
+
+// CalculateSponsoredFee calculates the fee of a transaction from user to
+// to whose fee sponsor pays, and checks the allowance covers all of it
+func (f *Fees) CalculateSponsoredFee(gasLimit uint64, tip uint64, txType string, sponsor, user, to common.Address) (*Fee, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	fee, err := f.calculateFee(gasLimit, tip, txType, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.checkAllowance(sponsor, user, to, fee.Total, 0, time.Now().Unix()); err != nil {
+		return nil, err
+	}
+	return fee, nil
+}
+
+// CheckAllowance checks a sponsor's allowance for a user covers a fee of
+// amount for a transaction to to, as mempool admission does
+func (f *Fees) CheckAllowance(sponsor, user, to common.Address, amount uint64) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.checkAllowance(sponsor, user, to, amount, 0, time.Now().Unix())
+}
+
+// checkAllowance checks a sponsor may pay amount more for a user's
+// transaction to to at time at, on top of pending already charged
+func (f *Fees) checkAllowance(sponsor, user, to common.Address, amount, pending uint64, at int64) error {
+	allowance, ok := f.allowances[sponsorship{sponsor, user}]
+	if !ok {
+		return fmt.Errorf("%s does not sponsor %s", sponsor.Hex(), user.Hex())
+	}
+	if allowance.Expiry != 0 && at >= allowance.Expiry {
+		return fmt.Errorf("allowance of %s for %s expired at %d", sponsor.Hex(), user.Hex(), allowance.Expiry)
+	}
+	if len(allowance.Contracts) > 0 {
+		allowed := false
+		for _, contract := range allowance.Contracts {
+			allowed = allowed || contract == to
+		}
+		if !allowed {
+			return fmt.Errorf("allowance of %s for %s does not cover calls to %s", sponsor.Hex(), user.Hex(), to.Hex())
+		}
+	}
+	if left := allowance.Limit - allowance.Spent - pending; amount > left {
+		return fmt.Errorf("allowance of %s for %s has %d wei left, fee needs %d", sponsor.Hex(), user.Hex(), left, amount)
+	}
+	return nil
+}
+
+// sponsorSpends works out who pays each of a block's transactions and what
+// each sponsorship spends, without spending it. A sponsored transaction its
+// allowance does not cover is charged to its sender.
+func (f *Fees) sponsorSpends(txs []*Transaction) (map[sponsorship]uint64, []common.Address) {
+	spends := make(map[sponsorship]uint64)
+	payers := make([]common.Address, len(txs))
+	for i, tx := range txs {
+		payers[i] = tx.From
+		if !tx.Sponsored() {
+			continue
+		}
+		at := tx.Timestamp
+		if at == 0 {
+			at = time.Now().Unix()
+		}
+		key := sponsorship{tx.FeePayer, tx.From}
+		if err := f.checkAllowance(tx.FeePayer, tx.From, tx.To, tx.Fee.Total, spends[key], at); err != nil {
+			fmt.Printf("[FEES] Tx %s charged to its sender: %v\n", tx.Hash.Hex(), err)
+			continue
+		}
+		spends[key] += tx.Fee.Total
+		payers[i] = tx.FeePayer
+	}
+	return spends, payers
+}
+
+// debit charges a payer part of a block's fees
+func debit(settlement *Settlement, account common.Address, sponsored bool, amount uint64) {
+	for i := range settlement.Debits {
+		if d := &settlement.Debits[i]; d.Account == account && d.Sponsored == sponsored {
+			d.Amount.Add(d.Amount, new(big.Int).SetUint64(amount))
+			return
+		}
+	}
+	settlement.Debits = append(settlement.Debits, FeeDebit{Account: account, Sponsored: sponsored, Amount: new(big.Int).SetUint64(amount)})
+}
@@ -0,0 +1,266 @@
+package fees
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultStatsCapacity bounds the fee samples kept for statistics. Windows
+// and percentiles cover at most this many recent transactions.
+const DefaultStatsCapacity = 1 << 17
+
+// StatsWindow is a rolling window fee statistics are reported over
+type StatsWindow struct {
+	Name     string
+	Duration time.Duration
+}
+
+// StatsWindows are the rolling windows of FeeStats
+var StatsWindows = []StatsWindow{
+	{"1m", time.Minute},
+	{"1h", time.Hour},
+	{"24h", 24 * time.Hour},
+}
+
+// FeeSummary describes the fees of a set of transactions. Fee rates are
+// per second over the time the set spans.
+type FeeSummary struct {
+	Count    int64   `json:"count"`
+	Total    uint64  `json:"total"`
+	Burned   uint64  `json:"burned"`
+	Min      uint64  `json:"min"`
+	Max      uint64  `json:"max"`
+	Avg      uint64  `json:"avg"`
+	Median   uint64  `json:"median"`
+	P10      uint64  `json:"p10"`
+	P50      uint64  `json:"p50"`
+	P90      uint64  `json:"p90"`
+	P99      uint64  `json:"p99"`
+	FeeTPS   float64 `json:"fee_tps"`
+	BurnRate float64 `json:"burn_rate"`
+}
+
+// feeSample is the part of a transaction fee statistics need
+type feeSample struct {
+	at     time.Time
+	total  uint64
+	burned uint64
+	txType string
+}
+
+// feeRing keeps the most recent fee samples, up to its capacity. Its
+// buffer grows as samples arrive and is then reused.
+type feeRing struct {
+	samples  []feeSample
+	capacity int
+	next     int
+}
+
+func newFeeRing(capacity int) *feeRing {
+	return &feeRing{capacity: capacity}
+}
+
+// add stores a sample, overwriting the oldest once full
+func (r *feeRing) add(s feeSample) {
+	if len(r.samples) < r.capacity {
+		r.samples = append(r.samples, s)
+		return
+	}
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % r.capacity
+}
+
+// all returns the stored samples, oldest first
+func (r *feeRing) all() []feeSample {
+	all := make([]feeSample, 0, len(r.samples))
+	all = append(all, r.samples[r.next:]...)
+	return append(all, r.samples[:r.next]...)
+}
+
+// summarize computes the fee summary of samples spanning span
+func summarize(samples []feeSample, span time.Duration) FeeSummary {
+	summary := FeeSummary{Count: int64(len(samples))}
+	if len(samples) == 0 {
+		return summary
+	}
+
+	fees := make([]uint64, len(samples))
+	for i, s := range samples {
+		fees[i] = s.total
+		summary.Total += s.total
+		summary.Burned += s.burned
+	}
+	sort.Slice(fees, func(i, j int) bool { return fees[i] < fees[j] })
+
+	summary.Min = fees[0]
+	summary.Max = fees[len(fees)-1]
+	summary.Avg = summary.Total / uint64(len(fees))
+	summary.Median = calculateMedian(fees)
+	summary.P10 = percentile(fees, 10)
+	summary.P50 = percentile(fees, 50)
+	summary.P90 = percentile(fees, 90)
+	summary.P99 = percentile(fees, 99)
+
+	seconds := max(span.Seconds(), 1)
+	summary.FeeTPS = float64(summary.Total) / seconds
+	summary.BurnRate = float64(summary.Burned) / seconds
+	return summary
+}
+
+// percentile returns the nearest-rank percentile of sorted fees
+func percentile(sorted []uint64, p int) uint64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// calculateMedian returns the median of sorted fees, the mean of the middle
+// two for an even count
+func calculateMedian(sorted []uint64) uint64 {
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	a, b := sorted[n/2-1], sorted[n/2]
+	return a + (b-a)/2
+}
+
+func newFeeTracker() *FeeTracker {
+	return &FeeTracker{
+		history:       newFeeRing(DefaultStatsCapacity),
+		totalFees:     new(big.Int),
+		dimensions:    make(map[string]uint64),
+		feesCollected: new(big.Int),
+		tokensBurned:  new(big.Int),
+		revenueSplit:  make(map[common.Address]*big.Int),
+	}
+}
+
+// recordTransaction adds a transaction to the statistics. Its timestamp
+// places it in the windows; transactions without one count as now.
+func (f *Fees) recordTransaction(tx *Transaction) {
+	at := time.Now()
+	if tx.Timestamp != 0 {
+		at = time.Unix(tx.Timestamp, 0)
+	}
+	txType := tx.TxType
+	if txType == "" {
+		txType = "unknown"
+	}
+	f.tracker.history.add(feeSample{at: at, total: tx.Fee.Total, burned: tx.Fee.Burned, txType: txType})
+	f.tracker.totalTx++
+	f.tracker.totalFees.Add(f.tracker.totalFees, new(big.Int).SetUint64(tx.Fee.Total))
+	for _, dim := range tx.Fee.Dimensions {
+		f.tracker.dimensions[dim.Resource.String()] += dim.Amount
+	}
+}
+
+// burnRate returns the wei burned per second over the kept samples, as the
+// overall BurnRate of feeStats, without sorting them
+func (f *Fees) burnRate(now time.Time) float64 {
+	samples := f.tracker.history.samples
+	if len(samples) == 0 {
+		return 0
+	}
+	oldest := samples[0].at
+	var burned uint64
+	for _, sample := range samples {
+		burned += sample.burned
+		if sample.at.Before(oldest) {
+			oldest = sample.at
+		}
+	}
+	return float64(burned) / max(now.Sub(oldest).Seconds(), 1)
+}
+
+// copy returns a copy of the stats that shares nothing with them
+func (s *FeeStats) copy() *FeeStats {
+	copied := *s
+	copied.TotalFees = new(big.Int).Set(s.TotalFees)
+	copied.TotalBurned = new(big.Int).Set(s.TotalBurned)
+	copied.TotalToValidators = new(big.Int).Set(s.TotalToValidators)
+	copied.Windows = make(map[string]FeeSummary, len(s.Windows))
+	for name, summary := range s.Windows {
+		copied.Windows[name] = summary
+	}
+	copied.ByType = make(map[string]FeeSummary, len(s.ByType))
+	for txType, summary := range s.ByType {
+		copied.ByType[txType] = summary
+	}
+	copied.Dimensions = make(map[string]uint64, len(s.Dimensions))
+	for resource, amount := range s.Dimensions {
+		copied.Dimensions[resource] = amount
+	}
+	return &copied
+}
+
+// feeStats computes the fee statistics at now
+func (f *Fees) feeStats(now time.Time) *FeeStats {
+	samples := f.tracker.history.all()
+	if len(samples) == 0 {
+		return &FeeStats{TotalFees: new(big.Int), TotalBurned: new(big.Int), TotalToValidators: new(big.Int)}
+	}
+
+	// Timestamps come from transactions, so samples need not be in order
+	oldest := samples[0].at
+	for _, sample := range samples {
+		if sample.at.Before(oldest) {
+			oldest = sample.at
+		}
+	}
+	overall := summarize(samples, now.Sub(oldest))
+	stats := &FeeStats{
+		TotalFees:         new(big.Int).Set(f.tracker.totalFees),
+		TotalBurned:       new(big.Int).Set(f.tracker.tokensBurned),
+		TotalToValidators: new(big.Int).Sub(f.tracker.feesCollected, f.tracker.tokensBurned),
+		AvgFee:            overall.Avg,
+		MedianFee:         overall.Median,
+		MinFee:            overall.Min,
+		MaxFee:            overall.Max,
+		FeeTPS:            overall.FeeTPS,
+		TotalTx:           f.tracker.totalTx,
+		BurnRate:          overall.BurnRate,
+		P10:               overall.P10,
+		P50:               overall.P50,
+		P90:               overall.P90,
+		P99:               overall.P99,
+		Windows:           make(map[string]FeeSummary, len(StatsWindows)),
+		ByType:            make(map[string]FeeSummary),
+		Dimensions:        make(map[string]uint64, len(f.tracker.dimensions)),
+	}
+
+	for _, w := range StatsWindows {
+		start := now.Add(-w.Duration)
+		var windowed []feeSample
+		for _, sample := range samples {
+			if sample.at.After(start) {
+				windowed = append(windowed, sample)
+			}
+		}
+		stats.Windows[w.Name] = summarize(windowed, min(w.Duration, now.Sub(oldest)))
+	}
+
+	byType := make(map[string][]feeSample)
+	for _, sample := range samples {
+		byType[sample.txType] = append(byType[sample.txType], sample)
+	}
+	for txType, typed := range byType {
+		stats.ByType[txType] = summarize(typed, now.Sub(oldest))
+	}
+
+	for resource, amount := range f.tracker.dimensions {
+		stats.Dimensions[resource] = amount
+	}
+	return stats
+}
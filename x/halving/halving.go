@@ -1,12 +1,13 @@
 package halving
 
 import (
+	"context"
 	"fmt"
-	"math"
+	"math/big"
 	"sync"
 	"time"
 
-	"github.com/ethereum/go-ethereum/common"
+	"github.com/zennetwork/zennetwork/x/service"
 )
 
 // HalvingPhase represents the current halving phase
@@ -14,17 +15,17 @@ type HalvingPhase struct {
 	Phase            int       `json:"phase"`
 	StartBlock       int64     `json:"start_block"`
 	EndBlock         int64     `json:"end_block"`
-	InitialReward    uint64    `json:"initial_reward"` // in wei (ZEN base unit)
-	CurrentReward    uint64    `json:"current_reward"`
-	TotalDistributed uint64    `json:"total_distributed"`
-	RemainingPool    uint64    `json:"remaining_pool"`
+	InitialReward    *big.Int  `json:"initial_reward"` // in wei (ZEN base unit)
+	CurrentReward    *big.Int  `json:"current_reward"`
+	TotalDistributed *big.Int  `json:"total_distributed"`
+	RemainingPool    *big.Int  `json:"remaining_pool"`
 	NextHalving      int64     `json:"next_halving"`
 }
 
 // AEHConfig holds Adaptive Exponential Halving configuration
 type AEHConfig struct {
-	TotalPool         uint64  `json:"total_pool"`         // 200M ZEN total pool
-	InitialReward     uint64  `json:"initial_reward"`     // Initial reward per block
+	TotalPool         *big.Int `json:"total_pool"`        // 200M ZEN total pool
+	InitialReward     *big.Int `json:"initial_reward"`    // Initial reward per block
 	HalvingFactor     float64 `json:"halving_factor"`     // 0.95 (5% reduction)
 	HalvingInterval   int64   `json:"halving_interval"`   // ~3 months in blocks
 	AdaptiveEnabled   bool    `json:"adaptive_enabled"`   // AI-based adjustment
@@ -35,7 +36,7 @@ type AEHConfig struct {
 type RewardRecord struct {
 	BlockNumber   int64     `json:"block_number"`
 	Validator     []byte    `json:"validator"`
-	Amount        uint64    `json:"amount"`
+	Amount        *big.Int  `json:"amount"`
 	Phase         int       `json:"phase"`
 	Timestamp     int64     `json:"timestamp"`
 }
@@ -47,8 +48,9 @@ type Halving struct {
 	phases         []HalvingPhase
 	currentPhase   int
 	currentBlock   int64
-	rewardPool     uint64
-	distributed    uint64
+	lastPaid       int64 // Highest block rewarded, -1 before the first
+	rewardPool     *big.Int
+	distributed    *big.Int
 	rewardHistory  []RewardRecord
 	aiAdapter      *AIAdapter
 	adaptiveActive bool
@@ -59,28 +61,29 @@ type AIAdapter struct {
 	mu            sync.RWMutex
 	tvlPercent    float64
 	validatorCount int
-	networkTVL    uint64
+	networkTVL    *big.Int
 	adjustmentFactor float64
 	learningRate  float64
 }
 
+// oneZEN is 1 ZEN in wei
+var oneZEN = big.NewInt(1e18)
+
+// zen returns an amount of whole ZEN in wei
+func zen(amount int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(amount), oneZEN)
+}
+
 // New creates a new halving instance
 func New() *Halving {
-	return &Halving{
-		config: AEHConfig{
-			TotalPool:         200000000000000000000000000, // 200M ZEN
-			InitialReward:     1000000000000000000000,       // 1000 ZEN per block
-			HalvingFactor:     0.95,                         // 5% reduction
-			HalvingInterval:   7889400,                      // ~3 months
-			AdaptiveEnabled:   true,
-			AdaptiveThreshold: 0.50,                         // 50% TVL
-		},
-		phases:        make([]HalvingPhase, 0),
-		rewardPool:    200000000000000000000000000,
-		distributed:   0,
-		rewardHistory: make([]RewardRecord, 0),
-		aiAdapter:     &AIAdapter{adjustmentFactor: 1.0, learningRate: 0.1},
-	}
+	return NewWithConfig(AEHConfig{
+		TotalPool:         zen(200000000), // 200M ZEN
+		InitialReward:     zen(1000),      // 1000 ZEN per block
+		HalvingFactor:     0.95,           // 5% reduction
+		HalvingInterval:   7889400,        // ~3 months
+		AdaptiveEnabled:   true,
+		AdaptiveThreshold: 0.50, // 50% TVL
+	})
 }
 
 // NewWithConfig creates halving with custom configuration
@@ -88,36 +91,45 @@ func NewWithConfig(config AEHConfig) *Halving {
 	return &Halving{
 		config:        config,
 		phases:        make([]HalvingPhase, 0),
-		rewardPool:    config.TotalPool,
-		distributed:   0,
+		lastPaid:      -1,
+		rewardPool:    new(big.Int).Set(config.TotalPool),
+		distributed:   new(big.Int),
 		rewardHistory: make([]RewardRecord, 0),
-		aiAdapter:     &AIAdapter{adjustmentFactor: 1.0, learningRate: 0.1},
+		aiAdapter:     &AIAdapter{networkTVL: new(big.Int), adjustmentFactor: 1.0, learningRate: 0.1},
 	}
 }
 
-// Start initializes the halving engine
-func (h *Halving) Start() error {
+var _ service.Service = (*Halving)(nil)
+
+// Start initializes the halving engine. It runs no goroutines.
+func (h *Halving) Start(ctx context.Context) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	fmt.Println("[HALVING] Initializing Adaptive Exponential Halving (AEH)")
-	fmt.Printf("  - Total Pool: %d ZEN (%.0fM)\n",
-		h.config.TotalPool/1e18, float64(h.config.TotalPool)/1e18)
-	fmt.Printf("  - Initial Reward: %d ZEN\n", h.config.InitialReward/1e18)
+	fmt.Printf("  - Total Pool: %s ZEN (%.0fM)\n",
+		toZEN(h.config.TotalPool), ratio(h.config.TotalPool, zen(1000000)))
+	fmt.Printf("  - Initial Reward: %s ZEN\n", toZEN(h.config.InitialReward))
 	fmt.Printf("  - Halving Factor: %.2f (%.1f%% reduction)\n",
 		h.config.HalvingFactor, (1-h.config.HalvingFactor)*100)
 	fmt.Printf("  - Halving Interval: %d blocks (~3 months)\n", h.config.HalvingInterval)
 	fmt.Printf("  - Adaptive: %v\n", h.config.AdaptiveEnabled)
 
+	// A restarted engine resumes its current phase
+	if len(h.phases) > 0 {
+		fmt.Printf("  Resuming phase %d at block %d\n", h.currentPhase, h.currentBlock)
+		return nil
+	}
+
 	// Initialize phase 0
 	phase0 := HalvingPhase{
 		Phase:            0,
 		StartBlock:       0,
 		EndBlock:         h.config.HalvingInterval - 1,
-		InitialReward:    h.config.InitialReward,
-		CurrentReward:    h.config.InitialReward,
-		TotalDistributed: 0,
-		RemainingPool:    h.config.TotalPool,
+		InitialReward:    new(big.Int).Set(h.config.InitialReward),
+		CurrentReward:    new(big.Int).Set(h.config.InitialReward),
+		TotalDistributed: new(big.Int),
+		RemainingPool:    new(big.Int).Set(h.config.TotalPool),
 		NextHalving:      h.config.HalvingInterval,
 	}
 	h.phases = append(h.phases, phase0)
@@ -140,49 +152,65 @@ func (h *Halving) Stop() error {
 	return nil
 }
 
-// CalculateReward calculates reward for a validator at given block
-func (h *Halving) CalculateReward(blockNumber int64, validator []byte) (uint64, error) {
+// CalculateReward calculates reward for a validator at given block. Each
+// block is paid once: asking again for a paid block returns its recorded
+// reward without debiting the pool again.
+func (h *Halving) CalculateReward(blockNumber int64, validator []byte) (*big.Int, error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	if len(h.phases) == 0 {
+		return nil, fmt.Errorf("halving engine not started")
+	}
+	if blockNumber <= h.lastPaid {
+		for i := len(h.rewardHistory) - 1; i >= 0; i-- {
+			if h.rewardHistory[i].BlockNumber == blockNumber {
+				return new(big.Int).Set(h.rewardHistory[i].Amount), nil
+			}
+		}
+		return nil, fmt.Errorf("reward of block %d already paid", blockNumber)
+	}
+	h.currentBlock = blockNumber
+
 	// Check if halving should occur
 	if h.shouldHalve(blockNumber) {
 		if err := h.performHalving(blockNumber); err != nil {
-			return 0, fmt.Errorf("halving failed: %w", err)
+			return nil, fmt.Errorf("halving failed: %w", err)
 		}
 	}
 
 	// Calculate base reward
 	phase := h.phases[h.currentPhase]
-	reward := phase.CurrentReward
+	reward := new(big.Int).Set(phase.CurrentReward)
 
 	// Apply AI-based adaptive adjustment if enabled
 	if h.config.AdaptiveEnabled {
 		adjustment := h.aiAdapter.calculateAdjustment()
-		reward = uint64(float64(reward) * adjustment)
+		reward = scale(reward, adjustment)
 	}
 
 	// Check if we have enough in pool
-	if reward > h.rewardPool {
-		reward = h.rewardPool
-		if reward == 0 {
-			return 0, fmt.Errorf("reward pool exhausted")
+	if reward.Cmp(h.rewardPool) > 0 {
+		reward.Set(h.rewardPool)
+		if reward.Sign() == 0 {
+			return nil, fmt.Errorf("reward pool exhausted")
 		}
 	}
 
 	// Update pool and distributed
-	h.rewardPool -= reward
-	h.distributed += reward
+	h.rewardPool.Sub(h.rewardPool, reward)
+	h.distributed.Add(h.distributed, reward)
+	h.lastPaid = blockNumber
 
 	// Update phase
-	h.phases[h.currentPhase].TotalDistributed += reward
-	h.phases[h.currentPhase].RemainingPool -= reward
+	h.phases[h.currentPhase].TotalDistributed.Add(h.phases[h.currentPhase].TotalDistributed, reward)
+	h.phases[h.currentPhase].RemainingPool.Sub(h.phases[h.currentPhase].RemainingPool, reward)
 
 	// Record reward
 	record := RewardRecord{
 		BlockNumber: blockNumber,
 		Validator:   validator,
-		Amount:      reward,
+		Amount:      new(big.Int).Set(reward),
 		Phase:       h.currentPhase,
 		Timestamp:   time.Now().Unix(),
 	}
@@ -220,18 +248,21 @@ func (h *Halving) performHalving(blockNumber int64) error {
 
 	// Calculate new reward using exponential decay
 	// reward_n = initial_reward * (halving_factor)^n
-	n := float64(h.currentPhase)
-	newReward := h.config.InitialReward * math.Pow(h.config.HalvingFactor, n)
+	newReward := new(big.Float).SetPrec(256).SetInt(h.config.InitialReward)
+	for n := 0; n < h.currentPhase; n++ {
+		newReward.Mul(newReward, big.NewFloat(h.config.HalvingFactor))
+	}
+	currentReward, _ := newReward.Int(nil)
 
 	// Create new phase
 	newPhase := HalvingPhase{
 		Phase:            h.currentPhase + 1,
 		StartBlock:       blockNumber,
 		EndBlock:         blockNumber + h.config.HalvingInterval - 1,
-		InitialReward:    h.config.InitialReward,
-		CurrentReward:    uint64(newReward),
-		TotalDistributed: 0,
-		RemainingPool:    h.rewardPool,
+		InitialReward:    new(big.Int).Set(h.config.InitialReward),
+		CurrentReward:    currentReward,
+		TotalDistributed: new(big.Int),
+		RemainingPool:    new(big.Int).Set(h.rewardPool),
 		NextHalving:      blockNumber + h.config.HalvingInterval,
 	}
 
@@ -240,11 +271,11 @@ func (h *Halving) performHalving(blockNumber int64) error {
 
 	fmt.Printf("[HALVING] Halving event at block %d!\n", blockNumber)
 	fmt.Printf("  Phase: %d → %d\n", currentPhase.Phase, newPhase.Phase)
-	fmt.Printf("  Reward: %d → %d ZEN (%.1f%% reduction)\n",
-		currentPhase.CurrentReward/1e18,
-		newPhase.CurrentReward/1e18,
+	fmt.Printf("  Reward: %s → %s ZEN (%.1f%% reduction)\n",
+		toZEN(currentPhase.CurrentReward),
+		toZEN(newPhase.CurrentReward),
 		(1-h.config.HalvingFactor)*100)
-	fmt.Printf("  Remaining pool: %d ZEN\n", h.rewardPool/1e18)
+	fmt.Printf("  Remaining pool: %s ZEN\n", toZEN(h.rewardPool))
 
 	return nil
 }
@@ -253,7 +284,7 @@ func (h *Halving) performHalving(blockNumber int64) error {
 func (h *Halving) GetCurrentPhase() HalvingPhase {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	return h.phases[h.currentPhase]
+	return copyPhase(h.phases[h.currentPhase])
 }
 
 // GetAllPhases returns all halving phases
@@ -261,21 +292,32 @@ func (h *Halving) GetAllPhases() []HalvingPhase {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 	phases := make([]HalvingPhase, len(h.phases))
-	copy(phases, h.phases)
+	for i, phase := range h.phases {
+		phases[i] = copyPhase(phase)
+	}
 	return phases
 }
 
+// copyPhase copies a phase so callers cannot change its amounts
+func copyPhase(phase HalvingPhase) HalvingPhase {
+	phase.InitialReward = new(big.Int).Set(phase.InitialReward)
+	phase.CurrentReward = new(big.Int).Set(phase.CurrentReward)
+	phase.TotalDistributed = new(big.Int).Set(phase.TotalDistributed)
+	phase.RemainingPool = new(big.Int).Set(phase.RemainingPool)
+	return phase
+}
+
 // GetRewardPoolStatus returns current pool status
 func (h *Halving) GetRewardPoolStatus() map[string]interface{} {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
 	return map[string]interface{}{
-		"total_pool":       h.config.TotalPool / 1e18,
-		"reward_pool":      h.rewardPool / 1e18,
-		"distributed":      h.distributed / 1e18,
-		"distributed_pct":  float64(h.distributed) / float64(h.config.TotalPool) * 100,
-		"remaining_pct":    float64(h.rewardPool) / float64(h.config.TotalPool) * 100,
+		"total_pool":       toZEN(h.config.TotalPool),
+		"reward_pool":      toZEN(h.rewardPool),
+		"distributed":      toZEN(h.distributed),
+		"distributed_pct":  ratio(h.distributed, h.config.TotalPool) * 100,
+		"remaining_pct":    ratio(h.rewardPool, h.config.TotalPool) * 100,
 		"current_phase":    h.currentPhase,
 		"phases_remaining": h.estimatePhasesRemaining(),
 	}
@@ -287,25 +329,28 @@ func (h *Halving) estimatePhasesRemaining() int {
 	// Pool depletes when: sum(reward * interval) >= total_pool
 	// Approximate calculation
 
-	avgReward := float64(h.phases[h.currentPhase].CurrentReward)
-	rewardPerPeriod := avgReward * float64(h.config.HalvingInterval)
-	periodsRemaining := float64(h.rewardPool) / rewardPerPeriod
+	avgReward := h.phases[h.currentPhase].CurrentReward
+	rewardPerPeriod := new(big.Int).Mul(avgReward, big.NewInt(h.config.HalvingInterval))
+	if rewardPerPeriod.Sign() == 0 {
+		return 0
+	}
+	periodsRemaining := new(big.Int).Quo(h.rewardPool, rewardPerPeriod)
 
-	return int(periodsRemaining)
+	return int(periodsRemaining.Int64())
 }
 
 // UpdateTVL updates total value locked (for adaptive mode)
-func (h *Halving) UpdateTVL(tvl uint64, validatorCount int) {
+func (h *Halving) UpdateTVL(tvl *big.Int, validatorCount int) {
 	h.aiAdapter.mu.Lock()
 	defer h.aiAdapter.mu.Unlock()
 
-	h.aiAdapter.networkTVL = tvl
+	h.aiAdapter.networkTVL = new(big.Int).Set(tvl)
 	h.aiAdapter.validatorCount = validatorCount
 
 	// Calculate TVL percentage of total supply
 	// Assuming total supply is 1B ZEN
-	totalSupply := uint64(1000000000000000000000000000) // 1B ZEN
-	h.aiAdapter.tvlPercent = float64(tvl) / float64(totalSupply)
+	totalSupply := zen(1000000000) // 1B ZEN
+	h.aiAdapter.tvlPercent = ratio(tvl, totalSupply)
 
 	// Adjust based on TVL
 	if h.config.AdaptiveEnabled {
@@ -339,15 +384,15 @@ func (h *Halving) PredictExhaustion() (int64, error) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	if h.rewardPool == 0 {
+	currentReward := h.phases[h.currentPhase].CurrentReward
+	if h.rewardPool.Sign() == 0 || currentReward.Sign() == 0 {
 		return h.currentBlock, nil
 	}
 
 	// Simple linear projection
 	// In production: use AI model for better prediction
-	currentReward := h.phases[h.currentPhase].CurrentReward
-	blocksRemaining := h.rewardPool / currentReward
-	estimatedExhaustion := h.currentBlock + int64(blocksRemaining)
+	blocksRemaining := new(big.Int).Quo(h.rewardPool, currentReward)
+	estimatedExhaustion := h.currentBlock + blocksRemaining.Int64()
 
 	return estimatedExhaustion, nil
 }
@@ -380,7 +425,7 @@ func (h *Halving) GetStats() map[string]interface{} {
 		"current_phase":     h.currentPhase,
 		"current_block":     h.currentBlock,
 		"next_halving":      h.phases[h.currentPhase].NextHalving,
-		"current_reward":    h.phases[h.currentPhase].CurrentReward / 1e18,
+		"current_reward":    toZEN(h.phases[h.currentPhase].CurrentReward),
 		"halving_factor":    h.config.HalvingFactor,
 		"adaptive_enabled":  h.config.AdaptiveEnabled,
 		"adaptive_factor":   h.aiAdapter.adjustmentFactor,
@@ -404,5 +449,27 @@ func (h *Halving) SetBlockNumber(blockNumber int64) {
 func (h *Halving) IsExhausted() bool {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	return h.rewardPool == 0
+	return h.rewardPool.Sign() == 0
+}
+
+// scale multiplies an amount by a factor, rounding down
+func scale(amount *big.Int, factor float64) *big.Int {
+	scaled := new(big.Float).SetPrec(256).SetInt(amount)
+	scaled.Mul(scaled, big.NewFloat(factor))
+	result, _ := scaled.Int(nil)
+	return result
+}
+
+// ratio returns a / b as a float
+func ratio(a, b *big.Int) float64 {
+	if b.Sign() == 0 {
+		return 0
+	}
+	r, _ := new(big.Float).Quo(new(big.Float).SetInt(a), new(big.Float).SetInt(b)).Float64()
+	return r
+}
+
+// toZEN returns an amount in whole ZEN, rounded down
+func toZEN(amount *big.Int) *big.Int {
+	return new(big.Int).Quo(amount, oneZEN)
 }
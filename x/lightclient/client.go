@@ -0,0 +1,328 @@
+package lightclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zennetwork/zennetwork/x/consensus"
+)
+
+// Client defaults
+const (
+	DefaultTrustingPeriod = 14 * 24 * time.Hour // Below the unbonding period, so signers stay slashable
+	DefaultMaxClockDrift  = 10 * time.Second
+	DefaultMaxTrusted     = 1000 // Trusted light blocks kept in memory
+)
+
+// TrustOptions is the root of trust a client starts from, obtained out of
+// band from a source the user trusts
+type TrustOptions struct {
+	Period time.Duration `json:"period"` // How long a trusted header can be verified from
+	Height int64         `json:"height"`
+	Hash   []byte        `json:"hash"`
+}
+
+// ForkError is returned when a witness proves a different header at a
+// height the primary's header verified for: the chain forked or the
+// primary is attacking the client. Both blocks are proof of the fork.
+type ForkError struct {
+	Primary *LightBlock
+	Witness *LightBlock
+}
+
+func (e *ForkError) Error() string {
+	return fmt.Sprintf("fork detected at height %d: primary %X, witness %X",
+		e.Primary.Height(), e.Primary.Hash(), e.Witness.Hash())
+}
+
+// Client follows a chain from a trusted header, verifying new headers from
+// a primary provider and cross-checking them against witnesses
+type Client struct {
+	mu             sync.Mutex
+	TrustLevel     Fraction
+	MaxClockDrift  time.Duration
+	trustingPeriod time.Duration
+	primary        Provider
+	witnesses      []Provider
+	trusted        map[int64]*LightBlock
+	latest         *LightBlock
+}
+
+// NewClient creates a client trusting the primary's header at the trusted
+// height and hash
+func NewClient(ctx context.Context, options TrustOptions, primary Provider, witnesses []Provider) (*Client, error) {
+	if options.Period <= 0 {
+		return nil, fmt.Errorf("trusting period must be positive")
+	}
+	if len(options.Hash) == 0 {
+		return nil, fmt.Errorf("trusted hash required")
+	}
+
+	root, err := primary.LightBlock(ctx, options.Height)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch trusted block: %w", err)
+	}
+	if err := root.ValidateBasic(); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(root.Hash(), options.Hash) {
+		return nil, fmt.Errorf("primary header %X at height %d does not match the trusted hash %X",
+			root.Hash(), options.Height, options.Hash)
+	}
+	if err := consensus.VerifyCommit(root.Header.ChainID, root.Validators, root.Commit); err != nil {
+		return nil, fmt.Errorf("trusted block: %w", err)
+	}
+
+	c := &Client{
+		TrustLevel:     DefaultTrustLevel,
+		MaxClockDrift:  DefaultMaxClockDrift,
+		trustingPeriod: options.Period,
+		primary:        primary,
+		witnesses:      witnesses,
+		trusted:        make(map[int64]*LightBlock),
+	}
+	c.saveTrusted(root)
+
+	fmt.Printf("[LIGHTCLIENT] Trusting height %d (%X)\n", root.Height(), root.Hash())
+	return c, nil
+}
+
+// LatestTrusted returns the highest verified light block
+func (c *Client) LatestTrusted() *LightBlock {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.latest
+}
+
+// TrustedLightBlock returns a verified light block
+func (c *Client) TrustedLightBlock(height int64) (*LightBlock, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lb, ok := c.trusted[height]
+	if !ok {
+		return nil, fmt.Errorf("no trusted light block at height %d", height)
+	}
+	return lb, nil
+}
+
+// Witnesses returns the number of witnesses still cross-checking the primary
+func (c *Client) Witnesses() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.witnesses)
+}
+
+// Update verifies the primary's latest header, returning nil if the client
+// is already at it
+func (c *Client) Update(ctx context.Context, now time.Time) (*LightBlock, error) {
+	latest, err := c.primary.LightBlock(ctx, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch latest block: %w", err)
+	}
+	if err := latest.ValidateBasic(); err != nil {
+		return nil, err
+	}
+	if latest.Height() <= c.LatestTrusted().Height() {
+		return nil, nil
+	}
+	return c.verifyLightBlock(ctx, latest, now)
+}
+
+// VerifyLightBlockAtHeight fetches and verifies the primary's header at a
+// height. Heights above the latest trusted one are verified by skipping
+// with bisection; lower ones by following hashes back from a trusted one.
+func (c *Client) VerifyLightBlockAtHeight(ctx context.Context, height int64, now time.Time) (*LightBlock, error) {
+	if height <= 0 {
+		return nil, fmt.Errorf("invalid height %d", height)
+	}
+	if lb, err := c.TrustedLightBlock(height); err == nil {
+		return lb, nil
+	}
+
+	if height < c.LatestTrusted().Height() {
+		return c.verifyBackwards(ctx, height)
+	}
+
+	lb, err := c.primary.LightBlock(ctx, height)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch block %d: %w", height, err)
+	}
+	if err := lb.ValidateBasic(); err != nil {
+		return nil, err
+	}
+	if lb.Height() != height {
+		return nil, fmt.Errorf("%w: asked for height %d, got %d", ErrInvalidHeader, height, lb.Height())
+	}
+	return c.verifyLightBlock(ctx, lb, now)
+}
+
+// verifyLightBlock verifies a primary light block from the latest trusted
+// one, checks the witnesses agree and trusts every header on the way
+func (c *Client) verifyLightBlock(ctx context.Context, target *LightBlock, now time.Time) (*LightBlock, error) {
+	root := c.LatestTrusted()
+	if target.Height() <= root.Height() {
+		return nil, fmt.Errorf("height %d not above trusted height %d", target.Height(), root.Height())
+	}
+
+	trace, err := c.verifySkipping(ctx, c.primary, root, target, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.detectDivergence(ctx, trace, now); err != nil {
+		return nil, err
+	}
+
+	for _, lb := range trace[1:] {
+		c.saveTrusted(lb)
+	}
+	fmt.Printf("[LIGHTCLIENT] Verified height %d in %d steps\n", target.Height(), len(trace)-1)
+	return target, nil
+}
+
+// verifySkipping verifies target from trusted, bisecting towards trusted
+// whenever too little of the trusted set signed the next header. It returns
+// the verified trace from trusted to target.
+func (c *Client) verifySkipping(ctx context.Context, source Provider, trusted, target *LightBlock, now time.Time) ([]*LightBlock, error) {
+	pending := []*LightBlock{target} // Headers still to verify, target first
+	verified := trusted
+	trace := []*LightBlock{trusted}
+
+	for {
+		next := pending[len(pending)-1]
+		err := Verify(verified, next, c.trustingPeriod, now, c.MaxClockDrift, c.TrustLevel)
+		switch {
+		case err == nil:
+			verified = next
+			trace = append(trace, next)
+			pending = pending[:len(pending)-1]
+			if len(pending) == 0 {
+				return trace, nil
+			}
+
+		case errors.Is(err, ErrNotEnoughTrust):
+			pivot := (verified.Height() + next.Height()) / 2
+			if pivot == verified.Height() {
+				return nil, fmt.Errorf("cannot verify height %d from %d: %w", next.Height(), verified.Height(), err)
+			}
+			lb, err := source.LightBlock(ctx, pivot)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch block %d: %w", pivot, err)
+			}
+			if err := lb.ValidateBasic(); err != nil {
+				return nil, err
+			}
+			if lb.Height() != pivot {
+				return nil, fmt.Errorf("%w: asked for height %d, got %d", ErrInvalidHeader, pivot, lb.Height())
+			}
+			pending = append(pending, lb)
+
+		default:
+			return nil, err
+		}
+	}
+}
+
+// verifyBackwards verifies a height below the latest trusted one by
+// following LastBlockID hashes down from the closest trusted header above it
+func (c *Client) verifyBackwards(ctx context.Context, height int64) (*LightBlock, error) {
+	c.mu.Lock()
+	var above *LightBlock
+	for h, lb := range c.trusted {
+		if h > height && (above == nil || h < above.Height()) {
+			above = lb
+		}
+	}
+	c.mu.Unlock()
+
+	for above.Height() > height {
+		lb, err := c.primary.LightBlock(ctx, above.Height()-1)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch block %d: %w", above.Height()-1, err)
+		}
+		if err := lb.ValidateBasic(); err != nil {
+			return nil, err
+		}
+		if err := VerifyBackwards(above.Header, lb.Header); err != nil {
+			return nil, err
+		}
+		above = lb
+	}
+
+	c.saveTrusted(above)
+	return above, nil
+}
+
+// detectDivergence compares the verified header with each witness. A
+// witness with a different header that also verifies from the same root
+// proves a fork; one whose header does not verify is dropped as faulty.
+func (c *Client) detectDivergence(ctx context.Context, trace []*LightBlock, now time.Time) error {
+	root, target := trace[0], trace[len(trace)-1]
+
+	c.mu.Lock()
+	witnesses := append([]Provider{}, c.witnesses...)
+	c.mu.Unlock()
+
+	faulty := make(map[int]bool)
+	for i, witness := range witnesses {
+		lb, err := witness.LightBlock(ctx, target.Height())
+		if err != nil {
+			// An unreachable or lagging witness proves nothing either way
+			fmt.Printf("[LIGHTCLIENT] Witness %d has no block %d: %v\n", i, target.Height(), err)
+			continue
+		}
+		if err := lb.ValidateBasic(); err == nil && bytes.Equal(lb.Hash(), target.Hash()) {
+			continue
+		}
+
+		if _, err := c.verifySkipping(ctx, witness, root, lb, now); err != nil {
+			fmt.Printf("[LIGHTCLIENT] Dropping witness %d: conflicting header does not verify: %v\n", i, err)
+			faulty[i] = true
+			continue
+		}
+		fmt.Printf("[LIGHTCLIENT] Witness %d proves a different header at height %d\n", i, target.Height())
+		return &ForkError{Primary: target, Witness: lb}
+	}
+
+	if len(faulty) > 0 {
+		remaining := make([]Provider, 0, len(witnesses))
+		for i, witness := range witnesses {
+			if !faulty[i] {
+				remaining = append(remaining, witness)
+			}
+		}
+		c.mu.Lock()
+		c.witnesses = remaining
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// saveTrusted stores a verified light block, pruning the oldest ones
+func (c *Client) saveTrusted(lb *LightBlock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.trusted[lb.Height()] = lb
+	if c.latest == nil || lb.Height() > c.latest.Height() {
+		c.latest = lb
+	}
+
+	if len(c.trusted) > DefaultMaxTrusted {
+		heights := make([]int64, 0, len(c.trusted))
+		for h := range c.trusted {
+			heights = append(heights, h)
+		}
+		sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+		for _, h := range heights[:len(heights)-DefaultMaxTrusted] {
+			delete(c.trusted, h)
+		}
+	}
+}
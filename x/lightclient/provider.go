@@ -0,0 +1,190 @@
+package lightclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zennetwork/zennetwork/x/consensus"
+	"github.com/zennetwork/zennetwork/x/service"
+)
+
+// LightBlockPath is the RPC stand-in endpoint serving light blocks
+const LightBlockPath = "/light_block"
+
+// maxLightBlockSize bounds light block responses
+const maxLightBlockSize = 16 * 1024 * 1024
+
+// ErrLightBlockNotFound is returned for heights a provider does not have
+var ErrLightBlockNotFound = errors.New("light block not found")
+
+// Provider serves light blocks from a full node. Height 0 is the latest.
+type Provider interface {
+	LightBlock(ctx context.Context, height int64) (*LightBlock, error)
+}
+
+// NodeProvider serves light blocks from a local node's block store
+type NodeProvider struct {
+	node *consensus.Consensus
+}
+
+// NewNodeProvider creates a provider backed by a consensus node
+func NewNodeProvider(node *consensus.Consensus) *NodeProvider {
+	return &NodeProvider{node: node}
+}
+
+// LightBlock returns the committed header, commit and signers at a height
+func (p *NodeProvider) LightBlock(ctx context.Context, height int64) (*LightBlock, error) {
+	if height == 0 {
+		height = p.node.Height()
+	}
+
+	block, commit, err := p.node.LoadBlock(height)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLightBlockNotFound, err)
+	}
+	validators, err := p.node.LoadValidators(height)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLightBlockNotFound, err)
+	}
+	return &LightBlock{Header: block.Header, Commit: commit, Validators: validators}, nil
+}
+
+// HTTPProvider fetches light blocks from a Server
+type HTTPProvider struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPProvider creates a provider for a Server at a base URL
+func NewHTTPProvider(url string) *HTTPProvider {
+	return &HTTPProvider{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// LightBlock fetches the light block at a height
+func (p *HTTPProvider) LightBlock(ctx context.Context, height int64) (*LightBlock, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		fmt.Sprintf("%s%s?height=%d", p.url, LightBlockPath, height), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxLightBlockSize))
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: height %d", ErrLightBlockNotFound, height)
+	default:
+		return nil, fmt.Errorf("light block request failed: %s", resp.Status)
+	}
+
+	lb := &LightBlock{}
+	if err := json.Unmarshal(body, lb); err != nil {
+		return nil, fmt.Errorf("invalid light block: %w", err)
+	}
+	return lb, nil
+}
+
+// Server serves a provider's light blocks over HTTP, a local stand-in for
+// the node RPC that light clients connect to
+type Server struct {
+	addr     string
+	provider Provider
+	listener net.Listener
+	server   *http.Server
+	routines service.Routines
+}
+
+var _ service.Service = (*Server)(nil)
+
+// NewServer creates a light block server listening on addr
+func NewServer(addr string, provider Provider) *Server {
+	return &Server{addr: addr, provider: provider}
+}
+
+// Start listens and serves until ctx is cancelled or Stop is called
+func (s *Server) Start(ctx context.Context) error {
+	ctx, err := s.routines.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("light block server: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		s.routines.End()
+		return fmt.Errorf("light block server: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(LightBlockPath, s.handleLightBlock)
+	s.listener = listener
+	s.server = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	server := s.server
+	s.routines.Go(func(context.Context) {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("[LIGHTCLIENT] Light block server failed: %v\n", err)
+		}
+	})
+	s.routines.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		server.Close()
+	})
+
+	fmt.Printf("[LIGHTCLIENT] Serving light blocks on %s\n", listener.Addr())
+	return nil
+}
+
+// Stop closes the server and waits for it to exit
+func (s *Server) Stop() error {
+	s.routines.End()
+	return nil
+}
+
+// Addr returns the address the server listens on, once started
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return s.addr
+	}
+	return s.listener.Addr().String()
+}
+
+// handleLightBlock serves the light block at the height query parameter
+func (s *Server) handleLightBlock(w http.ResponseWriter, r *http.Request) {
+	var height int64
+	if q := r.URL.Query().Get("height"); q != "" {
+		h, err := strconv.ParseInt(q, 10, 64)
+		if err != nil || h < 0 {
+			http.Error(w, "invalid height", http.StatusBadRequest)
+			return
+		}
+		height = h
+	}
+
+	lb, err := s.provider.LightBlock(r.Context(), height)
+	if errors.Is(err, ErrLightBlockNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lb)
+}
@@ -0,0 +1,166 @@
+package lightclient
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tendermint/tendermint/types"
+
+	"github.com/zennetwork/zennetwork/x/consensus"
+)
+
+// Verification errors
+var (
+	ErrOldHeaderExpired = errors.New("trusted header expired")
+	ErrNotEnoughTrust   = errors.New("trusted validators signed too little of the new header")
+	ErrInvalidHeader    = errors.New("invalid header")
+)
+
+// Fraction is a share of voting power
+type Fraction struct {
+	Numerator   int64 `json:"numerator"`
+	Denominator int64 `json:"denominator"`
+}
+
+// DefaultTrustLevel is the share of a trusted validator set that must sign
+// a header to skip to it: at least one honest validator when fewer than a
+// third are faulty
+var DefaultTrustLevel = Fraction{Numerator: 1, Denominator: 3}
+
+// ValidateTrustLevel checks a trust level is within [1/3, 1]
+func ValidateTrustLevel(level Fraction) error {
+	if level.Denominator <= 0 || level.Numerator*3 < level.Denominator || level.Numerator > level.Denominator {
+		return fmt.Errorf("trust level must be within [1/3, 1], got %d/%d", level.Numerator, level.Denominator)
+	}
+	return nil
+}
+
+// LightBlock is a header with the commit and validator set that signed it
+type LightBlock struct {
+	Header     *types.Header         `json:"header"`
+	Commit     *types.Commit         `json:"commit"`
+	Validators []consensus.Validator `json:"validators"`
+}
+
+// Height returns the height of the light block
+func (lb *LightBlock) Height() int64 {
+	return lb.Header.Height
+}
+
+// Hash returns the header hash
+func (lb *LightBlock) Hash() []byte {
+	return lb.Header.Hash()
+}
+
+// ValidateBasic checks that the header, commit and validator set belong
+// together. It does not check the signatures.
+func (lb *LightBlock) ValidateBasic() error {
+	if lb == nil || lb.Header == nil || lb.Commit == nil {
+		return fmt.Errorf("%w: missing header or commit", ErrInvalidHeader)
+	}
+	if lb.Commit.Height != lb.Header.Height {
+		return fmt.Errorf("%w: commit for height %d, header at %d", ErrInvalidHeader, lb.Commit.Height, lb.Header.Height)
+	}
+	if !bytes.Equal(lb.Commit.BlockID.Hash, lb.Hash()) {
+		return fmt.Errorf("%w: commit signs another block at height %d", ErrInvalidHeader, lb.Height())
+	}
+	if !bytes.Equal(lb.Header.ValidatorsHash, consensus.ValidatorSetHash(lb.Validators)) {
+		return fmt.Errorf("%w: validator set does not match the header at height %d", ErrInvalidHeader, lb.Height())
+	}
+	return nil
+}
+
+// Verify checks an untrusted light block against a trusted one. Adjacent
+// headers must also link to the trusted header by hash. A header signed by
+// a different validator set needs trustLevel of the trusted set's power in
+// its commit; ErrNotEnoughTrust means an intermediate header is needed.
+func Verify(trusted, untrusted *LightBlock, trustingPeriod time.Duration, now time.Time, maxClockDrift time.Duration, trustLevel Fraction) error {
+	if err := ValidateTrustLevel(trustLevel); err != nil {
+		return err
+	}
+	if expired(trusted, trustingPeriod, now) {
+		return fmt.Errorf("%w at height %d", ErrOldHeaderExpired, trusted.Height())
+	}
+	if err := untrusted.ValidateBasic(); err != nil {
+		return err
+	}
+
+	switch {
+	case untrusted.Header.ChainID != trusted.Header.ChainID:
+		return fmt.Errorf("%w: header of chain %q, trusted chain is %q", ErrInvalidHeader, untrusted.Header.ChainID, trusted.Header.ChainID)
+	case untrusted.Height() <= trusted.Height():
+		return fmt.Errorf("%w: height %d not above trusted height %d", ErrInvalidHeader, untrusted.Height(), trusted.Height())
+	case !untrusted.Header.Time.After(trusted.Header.Time):
+		return fmt.Errorf("%w: time at height %d not after the trusted header", ErrInvalidHeader, untrusted.Height())
+	case untrusted.Header.Time.After(now.Add(maxClockDrift)):
+		return fmt.Errorf("%w: time at height %d is in the future", ErrInvalidHeader, untrusted.Height())
+	}
+
+	if untrusted.Height() == trusted.Height()+1 && !bytes.Equal(untrusted.Header.LastBlockID.Hash, trusted.Hash()) {
+		return fmt.Errorf("%w: height %d does not link to the trusted header", ErrInvalidHeader, untrusted.Height())
+	}
+
+	if !bytes.Equal(untrusted.Header.ValidatorsHash, trusted.Header.ValidatorsHash) {
+		if err := verifyCommitTrusting(untrusted.Header.ChainID, trusted.Validators, untrusted.Commit, trustLevel); err != nil {
+			return err
+		}
+	}
+	if err := consensus.VerifyCommit(untrusted.Header.ChainID, untrusted.Validators, untrusted.Commit); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+	return nil
+}
+
+// VerifyBackwards checks an untrusted header is the parent of a trusted one
+func VerifyBackwards(trusted, untrusted *types.Header) error {
+	if untrusted == nil || untrusted.Height != trusted.Height-1 {
+		return fmt.Errorf("%w: not the parent of height %d", ErrInvalidHeader, trusted.Height)
+	}
+	if !bytes.Equal(trusted.LastBlockID.Hash, untrusted.Hash()) {
+		return fmt.Errorf("%w: height %d does not link to the trusted header", ErrInvalidHeader, untrusted.Height)
+	}
+	if !untrusted.Time.Before(trusted.Time) {
+		return fmt.Errorf("%w: time at height %d not before the trusted header", ErrInvalidHeader, untrusted.Height)
+	}
+	return nil
+}
+
+// verifyCommitTrusting checks that validators from a trusted set holding
+// more than trustLevel of its power signed a commit
+func verifyCommitTrusting(chainID string, trusted []consensus.Validator, commit *types.Commit, trustLevel Fraction) error {
+	byAddress := make(map[string]consensus.Validator, len(trusted))
+	for _, val := range trusted {
+		byAddress[string(val.Address)] = val
+	}
+
+	var signed int64
+	seen := make(map[string]bool)
+	for i, sig := range commit.Signatures {
+		if sig.BlockIDFlag != types.BlockIDFlagCommit {
+			continue
+		}
+		val, ok := byAddress[string(sig.ValidatorAddress)]
+		if !ok || seen[string(sig.ValidatorAddress)] {
+			continue
+		}
+		seen[string(sig.ValidatorAddress)] = true
+
+		if err := consensus.VerifyVoteSignature(chainID, val.PubKey, consensus.CommitVote(commit, i)); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+		}
+		signed += consensus.VotingPower(val)
+	}
+
+	total := consensus.TotalVotingPower(trusted)
+	if total == 0 || signed*trustLevel.Denominator <= total*trustLevel.Numerator {
+		return fmt.Errorf("%w: %d/%d at height %d", ErrNotEnoughTrust, signed, total, commit.Height)
+	}
+	return nil
+}
+
+// expired reports whether a trusted header is older than the trusting period
+func expired(trusted *LightBlock, trustingPeriod time.Duration, now time.Time) bool {
+	return !trusted.Header.Time.Add(trustingPeriod).After(now)
+}
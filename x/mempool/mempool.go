@@ -0,0 +1,510 @@
+package mempool
+
+import (
+	"container/heap"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/zennetwork/zennetwork/x/fees"
+)
+
+// Mempool defaults
+const (
+	DefaultMaxTxs          = 10000
+	DefaultMaxBytes        = 64 * 1024 * 1024 // 64MB
+	DefaultMaxTxBytes      = 128 * 1024       // 128KB
+	DefaultMaxTxsPerSender = 64
+	DefaultMaxGas          = 100000000  // Block gas limit
+	DefaultMinBaseFee      = 4000000000 // 4 gwei per gas, the fees module base fee
+	DefaultPriceBump       = 10         // Percent tip increase to replace a tx
+)
+
+// Insert errors
+var (
+	ErrTxTooLarge    = errors.New("tx too large")
+	ErrTxExists      = errors.New("tx already in mempool")
+	ErrNonceTooLow   = errors.New("nonce too low")
+	ErrUnderpriced   = errors.New("replacement tx underpriced")
+	ErrSenderLimit   = errors.New("too many pending txs from sender")
+	ErrMempoolFull   = errors.New("mempool is full")
+	ErrInvalidGas    = errors.New("invalid gas limit")
+	ErrBaseFeeTooLow = errors.New("base fee below minimum")
+	ErrFeeMismatch   = errors.New("fee does not price the gas limit")
+	ErrFeePayer      = errors.New("fee payer has not signed")
+)
+
+// Config holds mempool limits
+type Config struct {
+	MaxTxs          int    `json:"max_txs"`
+	MaxBytes        int64  `json:"max_bytes"`
+	MaxTxBytes      int    `json:"max_tx_bytes"`
+	MaxTxsPerSender int    `json:"max_txs_per_sender"`
+	MaxGas          uint64 `json:"max_gas"` // Per tx, at most the block gas limit
+	MinBaseFee      uint64 `json:"min_base_fee"`
+	PriceBump       uint64 `json:"price_bump"` // Percent
+}
+
+// DefaultConfig returns the default mempool limits
+func DefaultConfig() Config {
+	return Config{
+		MaxTxs:          DefaultMaxTxs,
+		MaxBytes:        DefaultMaxBytes,
+		MaxTxBytes:      DefaultMaxTxBytes,
+		MaxTxsPerSender: DefaultMaxTxsPerSender,
+		MaxGas:          DefaultMaxGas,
+		MinBaseFee:      DefaultMinBaseFee,
+		PriceBump:       DefaultPriceBump,
+	}
+}
+
+// Tx is a transaction as gossiped and included in blocks. A sponsored tx
+// carries its fee payer's signature over the rest of it.
+type Tx struct {
+	From              common.Address `json:"from"`
+	To                common.Address `json:"to"`
+	Nonce             uint64         `json:"nonce"`
+	GasLimit          uint64         `json:"gas_limit"`
+	Fee               fees.Fee       `json:"fee"`
+	FeePayer          common.Address `json:"fee_payer,omitempty"` // Sponsor paying the fee, zero for From
+	FeePayerSignature []byte         `json:"fee_payer_signature,omitempty"`
+	Data              []byte         `json:"data"`
+}
+
+// Encode returns the wire encoding of a transaction
+func (tx *Tx) Encode() ([]byte, error) {
+	return json.Marshal(tx)
+}
+
+// Sponsored reports whether someone other than the sender pays the fee
+func (tx *Tx) Sponsored() bool {
+	return tx.FeePayer != (common.Address{}) && tx.FeePayer != tx.From
+}
+
+// SponsorHash returns the hash a fee payer signs: the encoding of the tx
+// without the signature, sender and nonce included
+func (tx *Tx) SponsorHash() common.Hash {
+	unsigned := *tx
+	unsigned.FeePayerSignature = nil
+	bz, _ := unsigned.Encode()
+	return crypto.Keccak256Hash(bz)
+}
+
+// SignFeePayer signs a sponsored tx as its fee payer, agreeing to pay its fee
+func (tx *Tx) SignFeePayer(key *ecdsa.PrivateKey) error {
+	if signer := crypto.PubkeyToAddress(key.PublicKey); signer != tx.FeePayer {
+		return fmt.Errorf("key of %s cannot sign for fee payer %s", signer.Hex(), tx.FeePayer.Hex())
+	}
+	sig, err := crypto.Sign(tx.SponsorHash().Bytes(), key)
+	if err != nil {
+		return fmt.Errorf("fee payer signing failed: %w", err)
+	}
+	tx.FeePayerSignature = sig
+	return nil
+}
+
+// VerifyFeePayer checks a sponsored tx was signed by its fee payer
+func (tx *Tx) VerifyFeePayer() error {
+	if !tx.Sponsored() {
+		return nil
+	}
+	if len(tx.FeePayerSignature) != crypto.SignatureLength {
+		return fmt.Errorf("%w: %s", ErrFeePayer, tx.FeePayer.Hex())
+	}
+	pub, err := crypto.SigToPub(tx.SponsorHash().Bytes(), tx.FeePayerSignature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrFeePayer, err)
+	}
+	if signer := crypto.PubkeyToAddress(*pub); signer != tx.FeePayer {
+		return fmt.Errorf("%w: signed by %s, not %s", ErrFeePayer, signer.Hex(), tx.FeePayer.Hex())
+	}
+	return nil
+}
+
+// Type returns the opcode class a transaction is priced as: a deployment
+// without a recipient, a contract call with data, otherwise a transfer
+func (tx *Tx) Type() string {
+	switch {
+	case tx.To == (common.Address{}):
+		return "contract_deploy"
+	case len(tx.Data) > 0:
+		return "contract_call"
+	default:
+		return "transfer"
+	}
+}
+
+// DecodeTx parses a transaction from its wire encoding
+func DecodeTx(bz []byte) (*Tx, error) {
+	tx := &Tx{}
+	if err := json.Unmarshal(bz, tx); err != nil {
+		return nil, fmt.Errorf("invalid tx encoding: %w", err)
+	}
+	return tx, nil
+}
+
+// TxHash returns the hash identifying an encoded transaction
+func TxHash(bz []byte) common.Hash {
+	sum := sha256.Sum256(bz)
+	return common.BytesToHash(sum[:])
+}
+
+// ValidatorFees returns the validator share of the fees paid by encoded
+// transactions. Undecodable txs pay nothing.
+func ValidatorFees(txs [][]byte) *big.Int {
+	total := new(big.Int)
+	for _, bz := range txs {
+		if tx, err := DecodeTx(bz); err == nil {
+			total.Add(total, new(big.Int).SetUint64(tx.Fee.Validator))
+		}
+	}
+	return total
+}
+
+// mempoolTx is a pending transaction
+type mempoolTx struct {
+	tx    *Tx
+	bytes []byte
+	hash  common.Hash
+	seq   uint64 // Arrival order, breaks priority ties
+}
+
+// Mempool holds pending transactions ordered by sender nonce and tip
+type Mempool struct {
+	mu      sync.RWMutex
+	config  Config
+	senders map[common.Address]map[uint64]*mempoolTx // Sender -> nonce -> tx
+	hashes  map[common.Hash]*mempoolTx
+	nonces  map[common.Address]uint64 // Next nonce of each sender after committed blocks
+	bytes   int64
+	seq     uint64
+	checkTx func(*Tx) error
+}
+
+// New creates a mempool with default limits
+func New() *Mempool {
+	return NewWithConfig(DefaultConfig())
+}
+
+// NewWithConfig creates a mempool with custom limits
+func NewWithConfig(config Config) *Mempool {
+	return &Mempool{
+		config:  config,
+		senders: make(map[common.Address]map[uint64]*mempoolTx),
+		hashes:  make(map[common.Hash]*mempoolTx),
+		nonces:  make(map[common.Address]uint64),
+	}
+}
+
+// SetCheckTx sets an extra validity check run on insert and after each commit
+func (m *Mempool) SetCheckTx(check func(*Tx) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.checkTx = check
+}
+
+// Insert validates an encoded transaction and adds it to the pool. A tx
+// reusing a pending nonce replaces it if its tip is PriceBump percent
+// higher. A full pool evicts its lowest-tip txs to make room.
+func (m *Mempool) Insert(bz []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(bz) > m.config.MaxTxBytes {
+		return fmt.Errorf("%w: %d > %d bytes", ErrTxTooLarge, len(bz), m.config.MaxTxBytes)
+	}
+	tx, err := DecodeTx(bz)
+	if err != nil {
+		return err
+	}
+	if err := m.validate(tx); err != nil {
+		return err
+	}
+
+	hash := TxHash(bz)
+	if _, ok := m.hashes[hash]; ok {
+		return ErrTxExists
+	}
+	if next := m.nonces[tx.From]; tx.Nonce < next {
+		return fmt.Errorf("%w: %d < %d", ErrNonceTooLow, tx.Nonce, next)
+	}
+
+	pending := m.senders[tx.From]
+	old, replacing := pending[tx.Nonce]
+	if replacing {
+		bump := old.tx.Fee.Tip + old.tx.Fee.Tip*m.config.PriceBump/100
+		if tx.Fee.Tip <= old.tx.Fee.Tip || tx.Fee.Tip < bump {
+			return fmt.Errorf("%w: tip %d, need %d", ErrUnderpriced, tx.Fee.Tip, bump)
+		}
+	} else if len(pending) >= m.config.MaxTxsPerSender {
+		return ErrSenderLimit
+	}
+
+	// Make room, replacing frees the old tx first
+	freed := 0
+	var freedBytes int64
+	if replacing {
+		freed, freedBytes = 1, int64(len(old.bytes))
+	}
+	for len(m.hashes)-freed+1 > m.config.MaxTxs || m.bytes-freedBytes+int64(len(bz)) > m.config.MaxBytes {
+		victim := m.evictionCandidate(tx.From)
+		if victim == nil || victim.tx.Fee.Tip >= tx.Fee.Tip {
+			return ErrMempoolFull
+		}
+		m.remove(victim)
+	}
+
+	if replacing {
+		m.remove(old)
+	}
+	m.add(&mempoolTx{tx: tx, bytes: bz, hash: hash, seq: m.seq})
+	m.seq++
+	return nil
+}
+
+// ReapMaxGas returns pending transactions whose gas limits fit maxGas.
+// Each sender's txs are taken in nonce order starting at its next nonce;
+// across senders the highest tip goes first.
+func (m *Mempool) ReapMaxGas(maxGas uint64) [][]byte {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	queue := make(txQueue, 0, len(m.senders))
+	for from, pending := range m.senders {
+		if head, ok := pending[m.nonces[from]]; ok {
+			queue = append(queue, head)
+		}
+	}
+	heap.Init(&queue)
+
+	txs := make([][]byte, 0)
+	var gas uint64
+	for queue.Len() > 0 {
+		next := heap.Pop(&queue).(*mempoolTx)
+		if next.tx.GasLimit > maxGas-gas {
+			// The sender's later nonces cannot go ahead of this tx
+			continue
+		}
+		gas += next.tx.GasLimit
+		txs = append(txs, next.bytes)
+
+		if following, ok := m.senders[next.tx.From][next.tx.Nonce+1]; ok {
+			heap.Push(&queue, following)
+		}
+	}
+	return txs
+}
+
+// Update removes transactions committed at height, drops txs whose nonce
+// is now stale and re-checks the rest
+func (m *Mempool) Update(height int64, txs [][]byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, bz := range txs {
+		tx, err := DecodeTx(bz)
+		if err != nil {
+			continue
+		}
+		if tx.Nonce+1 > m.nonces[tx.From] {
+			m.nonces[tx.From] = tx.Nonce + 1
+		}
+	}
+
+	removed := 0
+	for from, pending := range m.senders {
+		next := m.nonces[from]
+		for _, nonce := range sortedNonces(pending) {
+			mtx := pending[nonce]
+			if nonce < next {
+				m.remove(mtx)
+				removed++
+				continue
+			}
+			if m.checkTx != nil && m.checkTx(mtx.tx) != nil {
+				// Later nonces can never become ready without it
+				removed += m.removeFrom(from, nonce)
+				break
+			}
+		}
+	}
+
+	if len(txs) > 0 || removed > 0 {
+		fmt.Printf("[MEMPOOL] Height %d: %d txs committed, %d removed, %d pending\n",
+			height, len(txs), removed, len(m.hashes))
+	}
+}
+
+// Has reports whether a transaction is pending
+func (m *Mempool) Has(hash common.Hash) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.hashes[hash]
+	return ok
+}
+
+// Size returns the number of pending transactions
+func (m *Mempool) Size() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.hashes)
+}
+
+// SizeBytes returns the total size of pending transactions
+func (m *Mempool) SizeBytes() int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.bytes
+}
+
+// NextNonce returns the next nonce of a sender after committed blocks
+func (m *Mempool) NextNonce(from common.Address) uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.nonces[from]
+}
+
+// validate checks a transaction against the pool limits
+func (m *Mempool) validate(tx *Tx) error {
+	if tx.GasLimit == 0 || tx.GasLimit > m.config.MaxGas {
+		return fmt.Errorf("%w: %d", ErrInvalidGas, tx.GasLimit)
+	}
+	if tx.Fee.BaseFee < m.config.MinBaseFee {
+		return fmt.Errorf("%w: %d < %d", ErrBaseFeeTooLow, tx.Fee.BaseFee, m.config.MinBaseFee)
+	}
+	if err := checkFeeTotal(tx); err != nil {
+		return err
+	}
+	if err := tx.VerifyFeePayer(); err != nil {
+		return err
+	}
+	if m.checkTx != nil {
+		if err := m.checkTx(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFeeTotal checks a transaction's fee is for its gas limit and totals
+// that gas at the fee's price, plus any other resources it breaks down
+func checkFeeTotal(tx *Tx) error {
+	fee := &tx.Fee
+	if fee.GasLimit != tx.GasLimit {
+		return fmt.Errorf("%w: fee for %d gas, tx limit %d", ErrFeeMismatch, fee.GasLimit, tx.GasLimit)
+	}
+
+	price := new(big.Int).SetUint64(fee.BaseFee)
+	price.Add(price, new(big.Int).SetUint64(fee.Surcharge))
+	price.Add(price, new(big.Int).SetUint64(fee.Tip))
+	price.Add(price, new(big.Int).SetUint64(fee.PriorityFee))
+	total := price.Mul(price, new(big.Int).SetUint64(tx.GasLimit))
+	for _, dim := range fee.Dimensions {
+		if dim.Resource != fees.ResourceGas {
+			total.Add(total, new(big.Int).SetUint64(dim.Amount))
+		}
+	}
+	if !total.IsUint64() || total.Uint64() != fee.Total {
+		return fmt.Errorf("%w: total %d, gas at its price is %s", ErrFeeMismatch, fee.Total, total)
+	}
+	return nil
+}
+
+// evictionCandidate returns the lowest-tip tx that can leave without
+// opening a nonce gap: the highest nonce of a sender other than except
+func (m *Mempool) evictionCandidate(except common.Address) *mempoolTx {
+	var victim *mempoolTx
+	for from, pending := range m.senders {
+		if from == except {
+			continue
+		}
+		var tail *mempoolTx
+		for _, mtx := range pending {
+			if tail == nil || mtx.tx.Nonce > tail.tx.Nonce {
+				tail = mtx
+			}
+		}
+		if victim == nil || tail.tx.Fee.Tip < victim.tx.Fee.Tip ||
+			(tail.tx.Fee.Tip == victim.tx.Fee.Tip && tail.seq > victim.seq) {
+			victim = tail
+		}
+	}
+	return victim
+}
+
+// add indexes a pending transaction
+func (m *Mempool) add(mtx *mempoolTx) {
+	pending, ok := m.senders[mtx.tx.From]
+	if !ok {
+		pending = make(map[uint64]*mempoolTx)
+		m.senders[mtx.tx.From] = pending
+	}
+	pending[mtx.tx.Nonce] = mtx
+	m.hashes[mtx.hash] = mtx
+	m.bytes += int64(len(mtx.bytes))
+}
+
+// remove drops a pending transaction
+func (m *Mempool) remove(mtx *mempoolTx) {
+	pending := m.senders[mtx.tx.From]
+	delete(pending, mtx.tx.Nonce)
+	if len(pending) == 0 {
+		delete(m.senders, mtx.tx.From)
+	}
+	delete(m.hashes, mtx.hash)
+	m.bytes -= int64(len(mtx.bytes))
+}
+
+// removeFrom drops a sender's txs from nonce on and returns how many
+func (m *Mempool) removeFrom(from common.Address, nonce uint64) int {
+	removed := 0
+	for n, mtx := range m.senders[from] {
+		if n >= nonce {
+			m.remove(mtx)
+			removed++
+		}
+	}
+	return removed
+}
+
+// sortedNonces returns the nonces of a sender's pending txs in order
+func sortedNonces(pending map[uint64]*mempoolTx) []uint64 {
+	nonces := make([]uint64, 0, len(pending))
+	for nonce := range pending {
+		nonces = append(nonces, nonce)
+	}
+	sort.Slice(nonces, func(i, j int) bool { return nonces[i] < nonces[j] })
+	return nonces
+}
+
+// txQueue orders ready txs by tip, then arrival
+type txQueue []*mempoolTx
+
+func (q txQueue) Len() int { return len(q) }
+func (q txQueue) Less(i, j int) bool {
+	if q[i].tx.Fee.Tip != q[j].tx.Fee.Tip {
+		return q[i].tx.Fee.Tip > q[j].tx.Fee.Tip
+	}
+	return q[i].seq < q[j].seq
+}
+func (q txQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *txQueue) Push(x interface{}) { *q = append(*q, x.(*mempoolTx)) }
+func (q *txQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -7,6 +7,7 @@ import (
 	"crypto/tls"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
@@ -17,6 +18,8 @@ import (
 	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/p2p/security/tls"
 	"github.com/multiformats/go-multiaddr"
+
+	"github.com/zennetwork/zennetwork/x/service"
 )
 
 // NetworkProtocol defines ZenNetwork P2P protocols
@@ -28,6 +31,9 @@ const (
 	StateProtocol     = "/zennetwork/state/1.0.0"
 )
 
+// MaxMessageSize bounds a message read from a stream
+const MaxMessageSize = 16 * 1024 * 1024
+
 // Message types for P2P communication
 type MessageType uint8
 
@@ -66,8 +72,7 @@ type PeerInfo struct {
 type Network struct {
 	mu           sync.RWMutex
 	host         host.Host
-	ctx          context.Context
-	cancel       context.CancelFunc
+	routines     service.Routines // Peer manager, message handler and broadcasts
 	selfID       peer.ID
 	privateKey   ed25519.PrivateKey
 	listener     network.Listener
@@ -80,14 +85,10 @@ type Network struct {
 
 // New creates a new Network instance
 func New() *Network {
-	ctx, cancel := context.WithCancel(context.Background())
-
 	// Generate random keypair for node identity
 	_, priv, _ := ed25519.GenerateKey(rand.Reader)
 
 	n := &Network{
-		ctx:         ctx,
-		cancel:      cancel,
 		privateKey:  priv,
 		peers:       make(map[peer.ID]*PeerInfo),
 		messageCh:   make(chan NetworkMessage, 1000),
@@ -98,11 +99,18 @@ func New() *Network {
 	return n
 }
 
-// Start initializes and starts the P2P network
-func (n *Network) Start() error {
+var _ service.Service = (*Network)(nil)
+
+// Start initializes and starts the P2P network. Its goroutines exit when
+// ctx is cancelled or Stop is called.
+func (n *Network) Start(ctx context.Context) error {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
+	if _, err := n.routines.Begin(ctx); err != nil {
+		return fmt.Errorf("network: %w", err)
+	}
+
 	fmt.Println("[NETWORK] Starting libp2p P2P network")
 
 	// Create libp2p host with security
@@ -128,6 +136,7 @@ func (n *Network) Start() error {
 		// In production: implement scoring system
 	)
 	if err != nil {
+		n.routines.End()
 		return fmt.Errorf("failed to create libp2p host: %w", err)
 	}
 
@@ -139,14 +148,16 @@ func (n *Network) Start() error {
 
 	// Start listening on default ports
 	if err := n.startListening(); err != nil {
+		host.Close()
+		n.routines.End()
 		return fmt.Errorf("failed to start listening: %w", err)
 	}
 
 	// Start peer management
-	go n.peerManager()
+	n.routines.Go(n.peerManager)
 
 	// Start message handling
-	go n.messageHandler()
+	n.routines.Go(n.messageHandler)
 
 	// Connect to bootstrap peers
 	// In production: actual bootstrap nodes
@@ -163,17 +174,24 @@ func (n *Network) Start() error {
 	return nil
 }
 
-// Stop halts the network
+// Stop halts the network and waits for its goroutines to exit
 func (n *Network) Stop() error {
 	n.mu.Lock()
-	defer n.mu.Unlock()
-
 	if !n.running {
+		n.mu.Unlock()
 		return nil
 	}
+	n.running = false
+	n.mu.Unlock()
 
 	fmt.Println("[NETWORK] Stopping P2P network")
 
+	// The peer manager takes n.mu, so wait before locking
+	n.routines.End()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
 	// Close all peer connections
 	for peerID := range n.peers {
 		n.host.Network().ClosePeer(peerID)
@@ -184,10 +202,6 @@ func (n *Network) Stop() error {
 		n.host.Close()
 	}
 
-	// Cancel context
-	n.cancel()
-
-	n.running = false
 	return nil
 }
 
@@ -286,19 +300,39 @@ func (n *Network) handleStateStream(stream network.Stream) {
 	}
 }
 
+// protocolFor returns the stream protocol whose handler accepts a message type
+func protocolFor(msgType MessageType) protocol.ID {
+	switch msgType {
+	case MsgTypeTx:
+		return TxProtocol
+	case MsgTypeConsensus:
+		return ConsensusProtocol
+	case MsgTypeSync:
+		return SyncProtocol
+	case MsgTypeState:
+		return StateProtocol
+	}
+	return ProtocolID
+}
+
 // readMessage reads a message from a stream
 func (n *Network) readMessage(stream network.Stream) (NetworkMessage, error) {
 	// In production: implement proper binary encoding
-	// For now: simplified message reading
-	buf := make([]byte, 4096)
-	read, err := stream.Read(buf)
+	// For now: one message per stream, read until the sender closes it
+	buf, err := io.ReadAll(io.LimitReader(stream, MaxMessageSize+1))
 	if err != nil {
 		return NetworkMessage{}, err
 	}
+	if len(buf) == 0 {
+		return NetworkMessage{}, fmt.Errorf("empty message")
+	}
+	if len(buf) > MaxMessageSize {
+		return NetworkMessage{}, fmt.Errorf("message exceeds %d bytes", MaxMessageSize)
+	}
 
 	msg := NetworkMessage{
 		Type:      MessageType(buf[0]),
-		Data:      buf[1:read],
+		Data:      buf[1:],
 		Timestamp: time.Now().Unix(),
 		PeerID:    stream.Conn().RemotePeer(),
 	}
@@ -326,7 +360,7 @@ func (n *Network) SendMessage(peerID peer.ID, msg NetworkMessage) error {
 		return fmt.Errorf("not connected to peer: %s", peerID.String())
 	}
 
-	stream, err := n.host.NewStream(context.Background(), peerID, ProtocolID)
+	stream, err := n.host.NewStream(context.Background(), peerID, protocolFor(msg.Type))
 	if err != nil {
 		return fmt.Errorf("failed to create stream: %w", err)
 	}
@@ -343,14 +377,14 @@ func (n *Network) BroadcastMessage(msg NetworkMessage) error {
 	for peerID := range n.peers {
 		if n.host.Network().Connectedness(peerID).IsConnected() {
 			// Fire and forget - don't block on each peer
-			go func(pid peer.ID) {
-				stream, err := n.host.NewStream(context.Background(), pid, ProtocolID)
+			n.routines.Go(func(ctx context.Context) {
+				stream, err := n.host.NewStream(ctx, peerID, protocolFor(msg.Type))
 				if err != nil {
 					return
 				}
 				defer stream.Close()
 				n.writeMessage(stream, msg)
-			}(peerID)
+			})
 		}
 	}
 
@@ -434,19 +468,19 @@ func (n *Network) dispatchMessage(msg NetworkMessage) {
 }
 
 // messageHandler processes incoming messages
-func (n *Network) messageHandler() {
+func (n *Network) messageHandler(ctx context.Context) {
 	for {
 		select {
 		case msg := <-n.messageCh:
 			n.dispatchMessage(msg)
-		case <-n.ctx.Done():
+		case <-ctx.Done():
 			return
 		}
 	}
 }
 
 // peerManager manages peer connections and health
-func (n *Network) peerManager() {
+func (n *Network) peerManager(ctx context.Context) {
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
 
@@ -473,7 +507,7 @@ func (n *Network) peerManager() {
 				info.Score = 1.0
 			}
 			n.mu.Unlock()
-		case <-n.ctx.Done():
+		case <-ctx.Done():
 			return
 		}
 	}
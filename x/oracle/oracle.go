@@ -1,6 +1,7 @@
 package oracle
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -8,6 +9,8 @@ import (
 	"time"
 
 	"github.com/owulveryck/onnx-go"
+
+	"github.com/zennetwork/zennetwork/x/service"
 )
 
 // OracleType represents different oracle types
@@ -61,6 +64,7 @@ type Oracle struct {
 	predictions     map[string]*MLPrediction
 	anomalyDetector *AnomalyDetector
 	running         bool
+	routines        service.Routines // Update loop
 	updateInterval  time.Duration
 }
 
@@ -119,43 +123,44 @@ func (o *Oracle) Initialize(dataDir string) error {
 		return fmt.Errorf("failed to load models: %w", err)
 	}
 
-	// Start update loop
-	o.running = true
-	go o.updateLoop()
-
 	fmt.Println("✓ Oracle initialized")
 
 	return nil
 }
 
-// Start begins oracle operation
-func (o *Oracle) Start() error {
-	if o.running {
-		return nil
-	}
+var _ service.Service = (*Oracle)(nil)
 
+// Start begins oracle operation. The update loop runs until ctx is
+// cancelled or Stop is called.
+func (o *Oracle) Start(ctx context.Context) error {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
+	if _, err := o.routines.Begin(ctx); err != nil {
+		return fmt.Errorf("oracle: %w", err)
+	}
+
 	fmt.Println("[ORACLE] Starting AI-native oracle")
 	o.running = true
-	go o.updateLoop()
+	o.routines.Go(o.updateLoop)
 
 	return nil
 }
 
-// Stop halts the oracle
+// Stop halts the oracle and waits for the update loop to exit
 func (o *Oracle) Stop() error {
 	o.mu.Lock()
-	defer o.mu.Unlock()
-
 	if !o.running {
+		o.mu.Unlock()
 		return nil
 	}
 
 	fmt.Println("[ORACLE] Stopping oracle")
 	o.running = false
+	o.mu.Unlock()
 
+	// Updates take o.mu, so wait without holding it
+	o.routines.End()
 	return nil
 }
 
@@ -375,17 +380,16 @@ func (o *Oracle) loadModels() error {
 }
 
 // updateLoop continuously updates oracle data
-func (o *Oracle) updateLoop() {
+func (o *Oracle) updateLoop(ctx context.Context) {
 	ticker := time.NewTicker(o.updateInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			if !o.running {
-				return
-			}
 			o.update()
+		case <-ctx.Done():
+			return
 		}
 	}
 }
@@ -1,6 +1,9 @@
 package security
 
 import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
@@ -11,6 +14,8 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"golang.org/x/crypto/ed25519"
+
+	"github.com/zennetwork/zennetwork/x/service"
 )
 
 // SecurityLevel defines security level
@@ -85,7 +90,9 @@ type Security struct {
 	anomalies        []Anomaly
 	attackPatterns   []AttackPattern
 	blocksanitizer   *BlockSanitizer
+	vrfKey           *ecdsa.PrivateKey
 	running          bool
+	routines         service.Routines // Detector, sanitizer and attack monitor
 }
 
 // AnomalyDetector detects anomalous behavior
@@ -173,43 +180,47 @@ func (s *Security) Initialize(dataDir string) error {
 	return nil
 }
 
-// Start begins security monitoring
-func (s *Security) Start() error {
+var _ service.Service = (*Security)(nil)
+
+// Start begins security monitoring. The monitors run until ctx is
+// cancelled or Stop is called.
+func (s *Security) Start(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.running {
-		return nil
+	if _, err := s.routines.Begin(ctx); err != nil {
+		return fmt.Errorf("security: %w", err)
 	}
 
 	fmt.Println("[SECURITY] Starting security monitoring")
 
 	// Start anomaly detection
-	go s.anomalyDetector.run()
+	s.routines.Go(s.anomalyDetector.run)
 
 	// Start block sanitization
-	go s.blocksanitizer.run()
+	s.routines.Go(s.blocksanitizer.run)
 
 	// Start attack pattern monitoring
-	go s.monitorAttackPatterns()
+	s.routines.Go(s.monitorAttackPatterns)
 
 	s.running = true
 
 	return nil
 }
 
-// Stop halts security monitoring
+// Stop halts security monitoring and waits for the monitors to exit
 func (s *Security) Stop() error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	if !s.running {
+		s.mu.Unlock()
 		return nil
 	}
 
 	fmt.Println("[SECURITY] Stopping security monitoring")
 	s.running = false
+	s.mu.Unlock()
 
+	s.routines.End()
 	return nil
 }
 
@@ -465,8 +476,8 @@ func (ad *AnomalyDetector) initializeModels() error {
 	return nil
 }
 
-// run starts anomaly detection loop
-func (ad *AnomalyDetector) run() {
+// run runs the anomaly detection loop until ctx is cancelled
+func (ad *AnomalyDetector) run(ctx context.Context) {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
@@ -474,6 +485,8 @@ func (ad *AnomalyDetector) run() {
 		select {
 		case <-ticker.C:
 			// In production: continuous model inference
+		case <-ctx.Done():
+			return
 		}
 	}
 }
@@ -511,9 +524,10 @@ func (bs *BlockSanitizer) initialize() error {
 	return nil
 }
 
-// run starts block sanitization loop
-func (bs *BlockSanitizer) run() {
+// run runs block sanitization until ctx is cancelled
+func (bs *BlockSanitizer) run(ctx context.Context) {
 	// Continuous sanitization
+	<-ctx.Done()
 }
 
 // scan scans a transaction for violations
@@ -522,8 +536,8 @@ func (bs *BlockSanitizer) scan(tx []byte) bool {
 	return true
 }
 
-// monitorAttackPatterns monitors for attack patterns
-func (s *Security) monitorAttackPatterns() {
+// monitorAttackPatterns monitors for attack patterns until ctx is cancelled
+func (s *Security) monitorAttackPatterns(ctx context.Context) {
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
@@ -531,6 +545,8 @@ func (s *Security) monitorAttackPatterns() {
 		select {
 		case <-ticker.C:
 			// In production: pattern matching
+		case <-ctx.Done():
+			return
 		}
 	}
 }
@@ -558,21 +574,64 @@ func (s *Security) HashData(data []byte) []byte {
 
 // GenerateVRF generates Verifiable Random Function for consensus
 func (s *Security) GenerateVRF(seed []byte) ([]byte, []byte, error) {
-	// In production: actual VRF generation
-	// For now: mock
-	output := make([]byte, 32)
-	proof := make([]byte, 64)
-
-	rand.Read(output)
-	rand.Read(proof)
+	key, err := s.getVRFKey()
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return output, proof, nil
+	return VRFProve(key, seed)
 }
 
-// VerifyVRF verifies a VRF proof
+// VerifyVRF verifies a VRF proof against this node's VRF key
 func (s *Security) VerifyVRF(seed, output, proof []byte) bool {
-	// In production: actual VRF verification
-	return true
+	key, err := s.getVRFKey()
+	if err != nil {
+		return false
+	}
+
+	return s.VerifyVRFWithKey(VRFPublicKeyBytes(&key.PublicKey), seed, output, proof)
+}
+
+// VerifyVRFWithKey verifies a VRF proof produced by another node
+func (s *Security) VerifyVRFWithKey(publicKey, seed, output, proof []byte) bool {
+	beta, err := VRFVerify(publicKey, seed, proof)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(beta, output)
+}
+
+// SetVRFKey sets the key used for VRF proofs
+func (s *Security) SetVRFKey(key *ecdsa.PrivateKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.vrfKey = key
+}
+
+// VRFPublicKey returns the compressed VRF public key of this node
+func (s *Security) VRFPublicKey() ([]byte, error) {
+	key, err := s.getVRFKey()
+	if err != nil {
+		return nil, err
+	}
+	return VRFPublicKeyBytes(&key.PublicKey), nil
+}
+
+// getVRFKey returns the VRF key, generating one on first use
+func (s *Security) getVRFKey() (*ecdsa.PrivateKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.vrfKey == nil {
+		key, err := GenerateVRFKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate VRF key: %w", err)
+		}
+		s.vrfKey = key
+	}
+
+	return s.vrfKey, nil
 }
 
 // GetMetrics returns security metrics
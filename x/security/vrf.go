@@ -0,0 +1,241 @@
+package security
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+// ECVRF-P256-SHA256-TAI (RFC 9381, section 5.5)
+const (
+	vrfSuite     = 0x01
+	vrfCLen      = 16 // Challenge length in bytes
+	vrfQLen      = 32 // Scalar length in bytes
+	vrfPtLen     = 33 // Compressed point length in bytes
+	VRFProofLen  = vrfPtLen + vrfCLen + vrfQLen
+	VRFOutputLen = sha256.Size
+)
+
+var vrfCurve = elliptic.P256()
+
+// GenerateVRFKey generates a new P-256 VRF key
+func GenerateVRFKey() (*ecdsa.PrivateKey, error) {
+	return ecdsa.GenerateKey(vrfCurve, rand.Reader)
+}
+
+// VRFPrivateKeyBytes encodes a VRF private key as a 32-byte scalar
+func VRFPrivateKeyBytes(sk *ecdsa.PrivateKey) []byte {
+	return sk.D.FillBytes(make([]byte, vrfQLen))
+}
+
+// VRFPrivateKeyFromBytes decodes a VRF private key encoded by VRFPrivateKeyBytes
+func VRFPrivateKeyFromBytes(b []byte) (*ecdsa.PrivateKey, error) {
+	d := new(big.Int).SetBytes(b)
+	if len(b) != vrfQLen || d.Sign() == 0 || d.Cmp(vrfCurve.Params().N) >= 0 {
+		return nil, fmt.Errorf("invalid VRF private key")
+	}
+
+	sk := &ecdsa.PrivateKey{D: d}
+	sk.Curve = vrfCurve
+	sk.X, sk.Y = vrfCurve.ScalarBaseMult(b)
+	return sk, nil
+}
+
+// VRFPublicKeyBytes encodes a VRF public key as a compressed point
+func VRFPublicKeyBytes(pub *ecdsa.PublicKey) []byte {
+	return elliptic.MarshalCompressed(vrfCurve, pub.X, pub.Y)
+}
+
+// VRFProve computes the VRF output (beta) and proof (pi) for alpha
+func VRFProve(sk *ecdsa.PrivateKey, alpha []byte) ([]byte, []byte, error) {
+	if sk == nil || sk.Curve != vrfCurve {
+		return nil, nil, fmt.Errorf("VRF key must be a P-256 key")
+	}
+
+	params := vrfCurve.Params()
+	pk := VRFPublicKeyBytes(&sk.PublicKey)
+
+	// H = encode_to_curve(PK, alpha)
+	hx, hy, err := vrfEncodeToCurve(pk, alpha)
+	if err != nil {
+		return nil, nil, err
+	}
+	hString := elliptic.MarshalCompressed(vrfCurve, hx, hy)
+
+	// Gamma = x*H
+	x := vrfScalar(sk.D)
+	gx, gy := vrfCurve.ScalarMult(hx, hy, x)
+
+	// k = nonce(SK, h_string), c = challenge(Y, H, Gamma, k*B, k*H)
+	k := vrfNonce(sk.D, hString)
+	kb := vrfScalar(k)
+	ux, uy := vrfCurve.ScalarBaseMult(kb)
+	vx, vy := vrfCurve.ScalarMult(hx, hy, kb)
+	c := vrfChallenge(
+		pk,
+		hString,
+		elliptic.MarshalCompressed(vrfCurve, gx, gy),
+		elliptic.MarshalCompressed(vrfCurve, ux, uy),
+		elliptic.MarshalCompressed(vrfCurve, vx, vy),
+	)
+
+	// s = (k + c*x) mod q
+	s := new(big.Int).Mul(c, sk.D)
+	s.Add(s, k)
+	s.Mod(s, params.N)
+
+	pi := make([]byte, 0, VRFProofLen)
+	pi = append(pi, elliptic.MarshalCompressed(vrfCurve, gx, gy)...)
+	pi = append(pi, c.FillBytes(make([]byte, vrfCLen))...)
+	pi = append(pi, s.FillBytes(make([]byte, vrfQLen))...)
+
+	return vrfProofToHash(gx, gy), pi, nil
+}
+
+// VRFVerify verifies proof pi for alpha under the compressed public key pk.
+// Returns the VRF output (beta) when the proof is valid.
+func VRFVerify(pk, alpha, pi []byte) ([]byte, error) {
+	params := vrfCurve.Params()
+
+	yx, yy := elliptic.UnmarshalCompressed(vrfCurve, pk)
+	if yx == nil {
+		return nil, fmt.Errorf("invalid VRF public key")
+	}
+	if len(pi) != VRFProofLen {
+		return nil, fmt.Errorf("invalid VRF proof length: %d", len(pi))
+	}
+
+	gx, gy := elliptic.UnmarshalCompressed(vrfCurve, pi[:vrfPtLen])
+	if gx == nil {
+		return nil, fmt.Errorf("invalid VRF proof point")
+	}
+	c := new(big.Int).SetBytes(pi[vrfPtLen : vrfPtLen+vrfCLen])
+	s := new(big.Int).SetBytes(pi[vrfPtLen+vrfCLen:])
+	if s.Cmp(params.N) >= 0 {
+		return nil, fmt.Errorf("invalid VRF proof scalar")
+	}
+
+	hx, hy, err := vrfEncodeToCurve(pk, alpha)
+	if err != nil {
+		return nil, err
+	}
+
+	// U = s*B - c*Y, V = s*H - c*Gamma
+	negC := vrfScalar(new(big.Int).Sub(params.N, c))
+	sb := vrfScalar(s)
+
+	sbx, sby := vrfCurve.ScalarBaseMult(sb)
+	cyx, cyy := vrfCurve.ScalarMult(yx, yy, negC)
+	ux, uy := vrfCurve.Add(sbx, sby, cyx, cyy)
+
+	shx, shy := vrfCurve.ScalarMult(hx, hy, sb)
+	cgx, cgy := vrfCurve.ScalarMult(gx, gy, negC)
+	vx, vy := vrfCurve.Add(shx, shy, cgx, cgy)
+
+	expected := vrfChallenge(
+		pk,
+		elliptic.MarshalCompressed(vrfCurve, hx, hy),
+		pi[:vrfPtLen],
+		elliptic.MarshalCompressed(vrfCurve, ux, uy),
+		elliptic.MarshalCompressed(vrfCurve, vx, vy),
+	)
+	if expected.Cmp(c) != 0 {
+		return nil, fmt.Errorf("VRF proof verification failed")
+	}
+
+	return vrfProofToHash(gx, gy), nil
+}
+
+// vrfEncodeToCurve hashes (PK, alpha) to a curve point using try-and-increment
+func vrfEncodeToCurve(pk, alpha []byte) (*big.Int, *big.Int, error) {
+	for ctr := 0; ctr < 256; ctr++ {
+		h := sha256.New()
+		h.Write([]byte{vrfSuite, 0x01})
+		h.Write(pk)
+		h.Write(alpha)
+		h.Write([]byte{byte(ctr), 0x00})
+
+		// interpret_hash_value_as_a_point: 0x02 || hash
+		candidate := append([]byte{0x02}, h.Sum(nil)...)
+		if x, y := elliptic.UnmarshalCompressed(vrfCurve, candidate); x != nil {
+			return x, y, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("VRF encode_to_curve failed")
+}
+
+// vrfChallenge computes the truncated challenge over the given points
+func vrfChallenge(points ...[]byte) *big.Int {
+	h := sha256.New()
+	h.Write([]byte{vrfSuite, 0x02})
+	for _, p := range points {
+		h.Write(p)
+	}
+	h.Write([]byte{0x00})
+
+	return new(big.Int).SetBytes(h.Sum(nil)[:vrfCLen])
+}
+
+// vrfProofToHash derives the VRF output from Gamma (P-256 has cofactor 1)
+func vrfProofToHash(gx, gy *big.Int) []byte {
+	h := sha256.New()
+	h.Write([]byte{vrfSuite, 0x03})
+	h.Write(elliptic.MarshalCompressed(vrfCurve, gx, gy))
+	h.Write([]byte{0x00})
+	return h.Sum(nil)
+}
+
+// vrfNonce generates the deterministic nonce of RFC 6979, section 3.2
+func vrfNonce(x *big.Int, hString []byte) *big.Int {
+	q := vrfCurve.Params().N
+
+	h1 := sha256.Sum256(hString)
+	bx := vrfScalar(x)
+	bh := vrfScalar(new(big.Int).Mod(vrfBits2Int(h1[:], q), q))
+
+	v := bytes.Repeat([]byte{0x01}, sha256.Size)
+	k := make([]byte, sha256.Size)
+
+	mac := func(key []byte, parts ...[]byte) []byte {
+		m := hmac.New(sha256.New, key)
+		for _, p := range parts {
+			m.Write(p)
+		}
+		return m.Sum(nil)
+	}
+
+	k = mac(k, v, []byte{0x00}, bx, bh)
+	v = mac(k, v)
+	k = mac(k, v, []byte{0x01}, bx, bh)
+	v = mac(k, v)
+
+	for {
+		v = mac(k, v)
+		nonce := vrfBits2Int(v, q)
+		if nonce.Sign() > 0 && nonce.Cmp(q) < 0 {
+			return nonce
+		}
+		k = mac(k, v, []byte{0x00})
+		v = mac(k, v)
+	}
+}
+
+// vrfBits2Int implements bits2int of RFC 6979, section 2.3.2
+func vrfBits2Int(b []byte, q *big.Int) *big.Int {
+	v := new(big.Int).SetBytes(b)
+	if excess := len(b)*8 - q.BitLen(); excess > 0 {
+		v.Rsh(v, uint(excess))
+	}
+	return v
+}
+
+// vrfScalar encodes a scalar as a fixed-length big-endian string
+func vrfScalar(v *big.Int) []byte {
+	return new(big.Int).Set(v).FillBytes(make([]byte, vrfQLen))
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsPath is where MetricsServer serves metrics
+const MetricsPath = "/metrics"
+
+// MetricsServer serves Prometheus metrics over HTTP
+type MetricsServer struct {
+	addr     string
+	registry *prometheus.Registry
+	listener net.Listener
+	server   *http.Server
+	routines Routines
+}
+
+var _ Service = (*MetricsServer)(nil)
+
+// NewMetricsServer creates a server listening on addr exporting collectors
+func NewMetricsServer(addr string, collectors ...prometheus.Collector) *MetricsServer {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors...)
+	return &MetricsServer{addr: addr, registry: registry}
+}
+
+// Start listens and serves until ctx is cancelled or Stop is called
+func (s *MetricsServer) Start(ctx context.Context) error {
+	ctx, err := s.routines.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("metrics server: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		s.routines.End()
+		return fmt.Errorf("metrics server: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{}))
+	s.listener = listener
+	s.server = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	server := s.server
+	s.routines.Go(func(context.Context) {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("[METRICS] Metrics server failed: %v\n", err)
+		}
+	})
+	s.routines.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		server.Close()
+	})
+
+	fmt.Printf("[METRICS] Serving metrics on %s\n", listener.Addr())
+	return nil
+}
+
+// Stop closes the server and waits for it to exit
+func (s *MetricsServer) Stop() error {
+	s.routines.End()
+	return nil
+}
+
+// Addr returns the address the server listens on, once started
+func (s *MetricsServer) Addr() string {
+	if s.listener == nil {
+		return s.addr
+	}
+	return s.listener.Addr().String()
+}
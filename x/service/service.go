@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Service is a node module with a context-driven lifecycle
+type Service interface {
+	// Start launches the module's goroutines. They exit when ctx is
+	// cancelled or Stop is called.
+	Start(ctx context.Context) error
+
+	// Stop cancels the module's goroutines and waits for them to exit
+	Stop() error
+}
+
+// Routines tracks the goroutines of a running service so Stop can wait
+// for them. The zero value is ready to use and can be restarted after End.
+type Routines struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Begin derives the service context from parent
+func (r *Routines) Begin(parent context.Context) (context.Context, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cancel != nil {
+		return nil, fmt.Errorf("service already running")
+	}
+
+	r.ctx, r.cancel = context.WithCancel(parent)
+	return r.ctx, nil
+}
+
+// Go runs fn in a tracked goroutine with the service context. It returns
+// false without running fn once the context is cancelled.
+func (r *Routines) Go(fn func(ctx context.Context)) bool {
+	r.mu.Lock()
+	if r.cancel == nil || r.ctx.Err() != nil {
+		r.mu.Unlock()
+		return false
+	}
+	ctx := r.ctx
+	r.wg.Add(1)
+	r.mu.Unlock()
+
+	go func() {
+		defer r.wg.Done()
+		fn(ctx)
+	}()
+	return true
+}
+
+// Do runs fn in the calling goroutine, tracked like Go, for callbacks that
+// already run on a goroutine of their own. It returns false without running
+// fn once the context is cancelled.
+func (r *Routines) Do(fn func(ctx context.Context)) bool {
+	r.mu.Lock()
+	if r.cancel == nil || r.ctx.Err() != nil {
+		r.mu.Unlock()
+		return false
+	}
+	ctx := r.ctx
+	r.wg.Add(1)
+	r.mu.Unlock()
+
+	defer r.wg.Done()
+	fn(ctx)
+	return true
+}
+
+// Running reports whether the service has begun and its context is live
+func (r *Routines) Running() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.cancel != nil && r.ctx.Err() == nil
+}
+
+// End cancels the service context and waits for every tracked goroutine
+// to return. It must not be called while holding a lock those goroutines take.
+func (r *Routines) End() {
+	r.mu.Lock()
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+}
@@ -0,0 +1,53 @@
+package sim
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/tendermint/tendermint/types"
+
+	"github.com/zennetwork/zennetwork/x/consensus"
+)
+
+// equivocationDomain separates the block hashes equivocators vote for
+var equivocationDomain = []byte("zen-sim-equivocation")
+
+// Behaviour rewrites the messages a faulty node sends to a peer
+type Behaviour interface {
+	Outgoing(from *Node, peer int, msg consensus.ConsensusMessage) []consensus.ConsensusMessage
+}
+
+// Silent never sends anything: a crashed or withholding validator that
+// still counts towards the total voting power
+type Silent struct{}
+
+// Outgoing drops the message
+func (Silent) Outgoing(*Node, int, consensus.ConsensusMessage) []consensus.ConsensusMessage {
+	return nil
+}
+
+// Equivocate double-signs every vote: peers with an odd index get a
+// conflicting vote instead of the real one, splitting the network's view
+type Equivocate struct{}
+
+// Outgoing swaps votes for signed conflicting ones on odd peers
+func (Equivocate) Outgoing(from *Node, peer int, msg consensus.ConsensusMessage) []consensus.ConsensusMessage {
+	if msg.Vote == nil || peer%2 == 0 {
+		return []consensus.ConsensusMessage{msg}
+	}
+
+	// Vote nil instead of for a block, and for a block nobody proposed
+	// instead of nil
+	conflict := *msg.Vote
+	conflict.BlockID = types.BlockID{}
+	if len(msg.Vote.BlockID.Hash) == 0 {
+		buf := make([]byte, 12)
+		binary.BigEndian.PutUint64(buf, uint64(msg.Vote.Height))
+		binary.BigEndian.PutUint32(buf[8:], uint32(msg.Vote.Round))
+		sum := sha256.Sum256(append(append([]byte{}, equivocationDomain...), buf...))
+		conflict.BlockID = types.BlockID{Hash: sum[:]}
+	}
+	conflict.Signature = ed25519.Sign(from.Key, consensus.VoteSignBytes(from.Consensus.ChainID, &conflict))
+	return []consensus.ConsensusMessage{{Vote: &conflict}}
+}
@@ -0,0 +1,137 @@
+package sim
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"github.com/zennetwork/zennetwork/x/consensus"
+)
+
+// Genesis is the virtual time every simulation starts at
+var Genesis = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Clock is a virtual clock that only moves when the simulation runs its
+// next event. Events at the same instant run in the order they were scheduled.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	seq    uint64
+	events eventQueue
+}
+
+var _ consensus.Clock = (*Clock)(nil)
+
+// NewClock creates a clock at Genesis
+func NewClock() *Clock {
+	return &Clock{now: Genesis}
+}
+
+// Now returns the virtual time
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// AfterFunc schedules f to run once the clock has advanced by d
+func (c *Clock) AfterFunc(d time.Duration, f func()) consensus.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if d < 0 {
+		d = 0
+	}
+	ev := &event{clock: c, at: c.now.Add(d), seq: c.seq, fn: f}
+	c.seq++
+	heap.Push(&c.events, ev)
+	return ev
+}
+
+// next returns the time of the next pending event
+func (c *Clock) next() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for c.events.Len() > 0 {
+		if ev := c.events[0]; !ev.done {
+			return ev.at, true
+		}
+		heap.Pop(&c.events)
+	}
+	return time.Time{}, false
+}
+
+// step advances to the next pending event and runs it
+func (c *Clock) step() bool {
+	c.mu.Lock()
+	var ev *event
+	for c.events.Len() > 0 && ev == nil {
+		if e := heap.Pop(&c.events).(*event); !e.done {
+			ev = e
+		}
+	}
+	if ev == nil {
+		c.mu.Unlock()
+		return false
+	}
+	ev.done = true
+	c.now = ev.at
+	c.mu.Unlock()
+
+	ev.fn()
+	return true
+}
+
+// advance moves the clock forward to t without running anything
+func (c *Clock) advance(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if t.After(c.now) {
+		c.now = t
+	}
+}
+
+// event is a scheduled call
+type event struct {
+	clock *Clock
+	at    time.Time
+	seq   uint64
+	fn    func()
+	done  bool // Run or stopped
+}
+
+// Stop cancels the event, reporting whether it was still pending
+func (e *event) Stop() bool {
+	e.clock.mu.Lock()
+	defer e.clock.mu.Unlock()
+
+	pending := !e.done
+	e.done = true
+	return pending
+}
+
+// eventQueue orders events by time, then by scheduling order
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if !q[i].at.Equal(q[j].at) {
+		return q[i].at.Before(q[j].at)
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(*event)) }
+
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	ev := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return ev
+}
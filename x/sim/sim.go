@@ -0,0 +1,388 @@
+// Package sim runs networks of consensus nodes in a single process over an
+// in-memory transport. Message delivery and round timeouts are events on a
+// virtual clock run one at a time, so a seed replays a run exactly,
+// including its latencies, drops, partitions and Byzantine behaviour.
+package sim
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/tendermint/tendermint/types"
+
+	"github.com/zennetwork/zennetwork/x/consensus"
+	"github.com/zennetwork/zennetwork/x/security"
+)
+
+// Simulation defaults
+const (
+	DefaultLatency      = 50 * time.Millisecond
+	DefaultJitter       = 100 * time.Millisecond
+	DefaultSyncInterval = time.Second
+)
+
+// Config describes a simulated network
+type Config struct {
+	Seed       int64
+	Validators int
+	Latency    time.Duration // Minimum delivery delay
+	Jitter     time.Duration // Random extra delay, up to
+	DropRate   float64       // Share of consensus messages lost in transit
+	Byzantine  map[int]Behaviour
+	// Lagging nodes fetch committed blocks from reachable peers at this
+	// interval, as the block sync of a real node would
+	SyncInterval time.Duration
+	Configure    func(*consensus.Consensus) // Applied to every node before Start
+}
+
+// Node is a simulated validator
+type Node struct {
+	Index     int
+	Consensus *consensus.Consensus
+	Validator consensus.Validator
+	Key       ed25519.PrivateKey
+	Behaviour Behaviour // nil for an honest node
+	crashed   bool
+	committed []syncedBlock // Index i holds height i+1
+}
+
+// syncedBlock is a committed block with its commit certificate
+type syncedBlock struct {
+	block  *types.Block
+	commit *types.Commit
+}
+
+// Honest reports whether the node follows the protocol
+func (n *Node) Honest() bool {
+	return n.Behaviour == nil
+}
+
+// Committed returns the blocks the node committed, in height order
+func (n *Node) Committed() []*types.Block {
+	blocks := make([]*types.Block, len(n.committed))
+	for i, sb := range n.committed {
+		blocks[i] = sb.block
+	}
+	return blocks
+}
+
+// Height returns the node's last committed height
+func (n *Node) Height() int64 {
+	if len(n.committed) == 0 {
+		return 0
+	}
+	return n.committed[len(n.committed)-1].block.Header.Height
+}
+
+// Stats counts messages on the simulated transport
+type Stats struct {
+	Sent      int
+	Delivered int
+	Dropped   int
+}
+
+// Network is a simulated network of consensus nodes
+type Network struct {
+	config     Config
+	clock      *Clock
+	rng        *rand.Rand
+	nodes      []*Node
+	partitions map[int]int // Node -> partition, empty when healed
+	decided    map[int64][]byte
+	violations []error
+	stats      Stats
+	running    bool
+}
+
+// New creates a network of equally weighted validators with keys derived
+// from the seed
+func New(config Config) (*Network, error) {
+	if config.Validators <= 0 {
+		return nil, fmt.Errorf("simulation needs at least one validator")
+	}
+	if config.DropRate < 0 || config.DropRate >= 1 {
+		return nil, fmt.Errorf("drop rate must be within [0, 1)")
+	}
+	if config.SyncInterval <= 0 {
+		config.SyncInterval = DefaultSyncInterval
+	}
+
+	n := &Network{
+		config:     config,
+		clock:      NewClock(),
+		rng:        rand.New(rand.NewSource(config.Seed)),
+		partitions: make(map[int]int),
+		decided:    make(map[int64][]byte),
+	}
+
+	validators := make([]consensus.Validator, config.Validators)
+	keys := make([]ed25519.PrivateKey, config.Validators)
+	vrfKeys := make([]*ecdsa.PrivateKey, config.Validators)
+	for i := range validators {
+		seed := make([]byte, ed25519.SeedSize)
+		n.rng.Read(seed)
+		keys[i] = ed25519.NewKeyFromSeed(seed)
+		vrfKey, err := n.vrfKey()
+		if err != nil {
+			return nil, err
+		}
+		vrfKeys[i] = vrfKey
+
+		pub := keys[i].Public().(ed25519.PublicKey)
+		validators[i] = consensus.Validator{
+			Address:   consensus.ValidatorAddress(pub),
+			PubKey:    pub,
+			Power:     10,
+			VRFPubKey: security.VRFPublicKeyBytes(&vrfKey.PublicKey),
+		}
+	}
+
+	for i := range validators {
+		c := consensus.New()
+		c.HashesPerTick = 10
+		c.TicksPerEntry = 4
+		c.ValidatorSet = append(c.ValidatorSet, validators...)
+		c.SetClock(n.clock)
+		c.SetValidatorKeys(validators[i].Address, keys[i], vrfKeys[i])
+		if config.Configure != nil {
+			config.Configure(c)
+		}
+
+		node := &Node{Index: i, Consensus: c, Validator: validators[i], Key: keys[i], Behaviour: config.Byzantine[i]}
+		c.SetBroadcaster(func(msg consensus.ConsensusMessage) { n.broadcast(node, msg) })
+		c.RegisterCommitListener(func(block *types.Block, commit *types.Commit) { n.recordCommit(node, block, commit) })
+		n.nodes = append(n.nodes, node)
+	}
+	return n, nil
+}
+
+// vrfKey derives a VRF key from the seed
+func (n *Network) vrfKey() (*ecdsa.PrivateKey, error) {
+	for i := 0; i < 16; i++ {
+		scalar := make([]byte, 32)
+		n.rng.Read(scalar)
+		if key, err := security.VRFPrivateKeyFromBytes(scalar); err == nil {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to derive a VRF key")
+}
+
+// Nodes returns the simulated validators
+func (n *Network) Nodes() []*Node {
+	return n.nodes
+}
+
+// Stats returns the transport counters
+func (n *Network) Stats() Stats {
+	return n.stats
+}
+
+// Elapsed returns the virtual time since the simulation began
+func (n *Network) Elapsed() time.Duration {
+	return n.clock.Now().Sub(Genesis)
+}
+
+// Start starts every node and block sync
+func (n *Network) Start() error {
+	for _, node := range n.nodes {
+		if err := node.Consensus.Start(context.Background()); err != nil {
+			return fmt.Errorf("node %d: %w", node.Index, err)
+		}
+	}
+	n.running = true
+	n.clock.AfterFunc(n.config.SyncInterval, n.syncTick)
+	return nil
+}
+
+// Stop stops every running node
+func (n *Network) Stop() {
+	n.running = false
+	for _, node := range n.nodes {
+		if !node.crashed {
+			node.Consensus.Stop()
+		}
+	}
+}
+
+// Run runs events for up to d of virtual time, returning early with true
+// once done reports true. A nil done runs for the whole duration.
+func (n *Network) Run(d time.Duration, done func() bool) bool {
+	deadline := n.clock.Now().Add(d)
+	for {
+		if done != nil && done() {
+			return true
+		}
+		next, ok := n.clock.next()
+		if !ok || next.After(deadline) {
+			n.clock.advance(deadline)
+			return done != nil && done()
+		}
+		n.clock.step()
+	}
+}
+
+// Partition splits the network into groups that cannot reach each other.
+// Nodes left out of every group are isolated.
+func (n *Network) Partition(groups ...[]int) {
+	n.partitions = make(map[int]int)
+	for _, node := range n.nodes {
+		n.partitions[node.Index] = -1 - node.Index
+	}
+	for g, group := range groups {
+		for _, idx := range group {
+			n.partitions[idx] = g
+		}
+	}
+}
+
+// Heal reconnects every partition
+func (n *Network) Heal() {
+	n.partitions = make(map[int]int)
+}
+
+// Crash stops a node; it neither sends nor receives until Recover. Call it
+// between runs.
+func (n *Network) Crash(idx int) {
+	node := n.nodes[idx]
+	if node.crashed {
+		return
+	}
+	node.crashed = true
+	node.Consensus.Stop()
+}
+
+// Recover restarts a crashed node from its in-memory state
+func (n *Network) Recover(idx int) error {
+	node := n.nodes[idx]
+	if !node.crashed {
+		return nil
+	}
+	node.crashed = false
+	return node.Consensus.Start(context.Background())
+}
+
+// MinHonestHeight returns the lowest height committed by a running honest node
+func (n *Network) MinHonestHeight() int64 {
+	min := int64(-1)
+	for _, node := range n.nodes {
+		if node.Honest() && !node.crashed && (min < 0 || node.Height() < min) {
+			min = node.Height()
+		}
+	}
+	return min
+}
+
+// CheckSafety returns an error if two honest nodes committed different
+// blocks at a height
+func (n *Network) CheckSafety() error {
+	if len(n.violations) > 0 {
+		return n.violations[0]
+	}
+	return nil
+}
+
+// connected reports whether two nodes are in the same partition
+func (n *Network) connected(a, b int) bool {
+	return n.partitions[a] == n.partitions[b]
+}
+
+// broadcast sends a node's message to every peer through its behaviour
+func (n *Network) broadcast(from *Node, msg consensus.ConsensusMessage) {
+	if from.crashed {
+		return
+	}
+	for _, peer := range n.nodes {
+		if peer == from {
+			continue
+		}
+		msgs := []consensus.ConsensusMessage{msg}
+		if from.Behaviour != nil {
+			msgs = from.Behaviour.Outgoing(from, peer.Index, msg)
+		}
+		for _, m := range msgs {
+			n.send(from, peer, m)
+		}
+	}
+}
+
+// send schedules delivery of a message, unless it is lost or partitioned
+func (n *Network) send(from, to *Node, msg consensus.ConsensusMessage) {
+	n.stats.Sent++
+	if !n.connected(from.Index, to.Index) || n.rng.Float64() < n.config.DropRate {
+		n.stats.Dropped++
+		return
+	}
+
+	// Peers decode their own copy, as they would off the wire
+	data, err := json.Marshal(msg)
+	if err != nil {
+		n.stats.Dropped++
+		return
+	}
+	delay := n.config.Latency
+	if n.config.Jitter > 0 {
+		delay += time.Duration(n.rng.Int63n(int64(n.config.Jitter)))
+	}
+	n.clock.AfterFunc(delay, func() {
+		var received consensus.ConsensusMessage
+		if to.crashed || json.Unmarshal(data, &received) != nil {
+			n.stats.Dropped++
+			return
+		}
+		n.stats.Delivered++
+		to.Consensus.HandleMessage(received)
+	})
+}
+
+// syncTick lets every running node catch up on blocks it missed
+func (n *Network) syncTick() {
+	if !n.running {
+		return
+	}
+	for _, node := range n.nodes {
+		if !node.crashed {
+			n.catchUp(node)
+		}
+	}
+	n.clock.AfterFunc(n.config.SyncInterval, n.syncTick)
+}
+
+// catchUp commits the blocks reachable peers committed above the node's
+// height. Sync runs over a reliable stream, so only partitions stop it.
+func (n *Network) catchUp(node *Node) {
+	for _, peer := range n.nodes {
+		if peer == node || peer.crashed || !n.connected(node.Index, peer.Index) {
+			continue
+		}
+		for int64(len(peer.committed)) > node.Height() {
+			sb := peer.committed[node.Height()]
+			if err := node.Consensus.CommitBlock(sb.block, sb.commit); err != nil {
+				break
+			}
+		}
+	}
+}
+
+// recordCommit tracks a committed block and checks it against the other
+// honest nodes
+func (n *Network) recordCommit(node *Node, block *types.Block, commit *types.Commit) {
+	node.committed = append(node.committed, syncedBlock{block, commit})
+	if !node.Honest() {
+		return
+	}
+
+	height, hash := block.Header.Height, block.Header.Hash()
+	if decided, ok := n.decided[height]; ok && !bytes.Equal(decided, hash) {
+		n.violations = append(n.violations, fmt.Errorf("node %d committed %X at height %d, another honest node committed %X",
+			node.Index, hash, height, decided))
+		return
+	}
+	n.decided[height] = hash
+}
@@ -2,6 +2,8 @@ package tokenomics
 
 import (
 	"fmt"
+	"math/big"
+	"strings"
 	"sync"
 	"time"
 
@@ -40,6 +42,7 @@ type Tokenomics struct {
 	totalSupply  TotalSupply
 	distributions []Distribution
 	burnEvents   []BurnEvent
+	totalBurned  *big.Int // Exact sum of every burn, including evicted events
 	minting      MintingConfig
 }
 
@@ -61,6 +64,7 @@ func New() *Tokenomics {
 		},
 		distributions: getInitialDistributions(),
 		burnEvents:    make([]BurnEvent, 0),
+		totalBurned:   new(big.Int),
 		minting: MintingConfig{
 			Enabled:        false, // Minting disabled
 			MaxSupply:      "1000000000000000000000000000",
@@ -124,9 +128,11 @@ func (t *Tokenomics) BurnTokens(amount string, txHash common.Hash, reason string
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	// Parse amount
-	// In production: use big.Int for precise calculation
-	// For now: just record the event
+	burned, ok := new(big.Int).SetString(amount, 10)
+	if !ok || burned.Sign() <= 0 {
+		return fmt.Errorf("invalid burn amount %q", amount)
+	}
+	t.totalBurned.Add(t.totalBurned, burned)
 
 	event := BurnEvent{
 		Amount:    amount,
@@ -146,6 +152,14 @@ func (t *Tokenomics) BurnTokens(amount string, txHash common.Hash, reason string
 	return nil
 }
 
+// GetTotalBurned returns the exact amount burned in wei
+func (t *Tokenomics) GetTotalBurned() *big.Int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return new(big.Int).Set(t.totalBurned)
+}
+
 // GetBurnEvents returns all burn events
 func (t *Tokenomics) GetBurnEvents(limit int) []BurnEvent {
 	t.mu.RLock()
@@ -206,46 +220,40 @@ func (t *Tokenomics) GetBurnStats() map[string]interface{} {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
-	var totalBurned float64
-	now := time.Now()
-
-	for _, event := range t.burnEvents {
-		// In production: parse actual amount
-		totalBurned += 0 // Mock
-	}
-
-	return map[string]interface{}{
+	stats := map[string]interface{}{
 		"total_events":   len(t.burnEvents),
-		"total_burned":   totalBurned,
-		"burn_rate":      totalBurned / float64(len(t.burnEvents)),
-		"last_burn":      time.Unix(t.burnEvents[len(t.burnEvents)-1].Timestamp, 0).Unix(),
+		"total_burned":   t.totalBurned.String(),
+	}
+	if len(t.burnEvents) > 0 {
+		stats["last_burn"] = t.burnEvents[len(t.burnEvents)-1].Timestamp
 	}
+	return stats
 }
 
 // PrintSummary prints tokenomics summary
 func (t *Tokenomics) PrintSummary() {
-	fmt.Println("\n" + "=".repeat(60))
+	fmt.Println("\n" + strings.Repeat("=", 60))
 	fmt.Println("ZenNetwork Tokenomics Summary (ZEN)")
-	fmt.Println("=".repeat(60))
+	fmt.Println(strings.Repeat("=", 60))
 	fmt.Printf("Total Supply: 1,000,000,000 ZEN (Fixed & Immutable)\n")
 	fmt.Printf("Decimals: 18\n")
 	fmt.Printf("Minting: DISABLED (Hard-capped)\n")
 	fmt.Printf("Burning: ENABLED (20%% of all fees)\n")
-	fmt.Println("=".repeat(60))
+	fmt.Println(strings.Repeat("=", 60))
 	fmt.Println("\nDistribution:")
 
 	for _, dist := range t.distributions {
 		fmt.Printf("  %-20s: %8.1f%%  (%s ZEN)\n",
 			dist.Category, dist.AllocationPercent, t.formatAmount(dist.Amount))
 	}
-	fmt.Println("=".repeat(60))
+	fmt.Println(strings.Repeat("=", 60))
 	fmt.Println("\nHalving System:")
 	fmt.Println("  - Adaptive Exponential Halving (AEH)")
 	fmt.Println("  - Total Reward Pool: 200M ZEN")
 	fmt.Println("  - Initial Reward: 1000 ZEN/block")
 	fmt.Println("  - Reduction: 5% per quarter")
 	fmt.Println("  - Habis: ~2033")
-	fmt.Println("=".repeat(60))
+	fmt.Println(strings.Repeat("=", 60))
 }
 
 // formatAmount formats amount for display
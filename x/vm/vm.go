@@ -1,6 +1,7 @@
 package vm
 
 import (
+	"context"
 	"encoding/hex"
 	"fmt"
 	"sync"
@@ -11,6 +12,8 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/zennetwork/zennetwork/x/service"
 )
 
 // VMConfig holds EVM configuration
@@ -51,7 +54,25 @@ type EVM struct {
 	stateFactory  StateFactory
 	mu            sync.RWMutex
 	running       bool
+	routines      service.Routines // Benchmark collector
 	benchmarks    []Benchmark
+	journal       []blockJournal // Non-final blocks, oldest first
+	finalized     int64
+}
+
+// blockJournal records what executing a non-final block changed, so it can
+// be rolled back when the fork choice abandons the block
+type blockJournal struct {
+	number int64
+	shards []shardJournal
+}
+
+// shardJournal is a shard's state before a block
+type shardJournal struct {
+	blockNumber int64
+	txCount     int
+	snapshot    int           // State snapshot to revert to
+	receipts    []common.Hash // Results added by the block
 }
 
 // StateFactory creates state instances
@@ -86,6 +107,7 @@ func (s *MockStateDB) GetNonce(addr common.Address) uint64 { return 0 }
 func (s *MockStateDB) Delete(addr common.Address) {}
 func (s *MockStateDB) Exist(addr common.Address) bool { return false }
 func (s *MockStateDB) Empty(addr common.Address) bool { return false }
+func (s *MockStateDB) Snapshot() int { return 0 }
 func (s *MockStateDB) RevertToSnapshot(int) {}
 
 // Benchmark holds performance metrics
@@ -122,11 +144,18 @@ func NewEVMWithConfig(config VMConfig) *EVM {
 	return evm
 }
 
-// Start initializes the EVM
-func (e *EVM) Start() error {
+var _ service.Service = (*EVM)(nil)
+
+// Start initializes the EVM. The benchmark collector runs until ctx is
+// cancelled or Stop is called.
+func (e *EVM) Start(ctx context.Context) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	if _, err := e.routines.Begin(ctx); err != nil {
+		return fmt.Errorf("evm: %w", err)
+	}
+
 	fmt.Println("[EVM] Initializing parallel EVM executor")
 	fmt.Printf("  - Chain ID: %d\n", e.config.ChainID)
 	fmt.Printf("  - Shards: %d\n", e.config.Shards)
@@ -138,6 +167,7 @@ func (e *EVM) Start() error {
 	for i := 0; i < e.config.Shards; i++ {
 		state, err := e.stateFactory.NewState()
 		if err != nil {
+			e.routines.End()
 			return fmt.Errorf("failed to create state for shard %d: %w", i, err)
 		}
 
@@ -151,7 +181,7 @@ func (e *EVM) Start() error {
 	}
 
 	// Start benchmark collector
-	go e.benchmarkCollector()
+	e.routines.Go(e.benchmarkCollector)
 
 	e.running = true
 	fmt.Println("✓ EVM initialized with parallel execution")
@@ -159,17 +189,20 @@ func (e *EVM) Start() error {
 	return nil
 }
 
-// Stop halts the EVM
+// Stop halts the EVM and waits for the benchmark collector to exit
 func (e *EVM) Stop() error {
 	e.mu.Lock()
-	defer e.mu.Unlock()
-
 	if !e.running {
+		e.mu.Unlock()
 		return nil
 	}
 
 	fmt.Println("[EVM] Stopping EVM executor")
 	e.running = false
+	e.mu.Unlock()
+
+	// The collector takes e.mu, so wait without holding it
+	e.routines.End()
 	return nil
 }
 
@@ -184,6 +217,11 @@ func (e *EVM) ExecuteTransaction(tx *types.Transaction) (*ExecutionResult, error
 	shard.mu.Lock()
 	defer shard.mu.Unlock()
 
+	return e.executeTransaction(shard, tx, startTime)
+}
+
+// executeTransaction executes a transaction on a shard whose lock is held
+func (e *EVM) executeTransaction(shard *Shard, tx *types.Transaction, startTime time.Time) (*ExecutionResult, error) {
 	// Create EVM context
 	evmContext := createEVMContext(tx, e.currentBlock, shard.State)
 
@@ -195,7 +233,7 @@ func (e *EVM) ExecuteTransaction(tx *types.Transaction) (*ExecutionResult, error
 		GasUsed:     21000, // Simple transfer
 		ReturnData:  []byte{},
 		Logs:        make([]*types.Log, 0),
-		ShardID:     shardID,
+		ShardID:     shard.ID,
 		ExecutionTime: time.Since(startTime),
 	}
 
@@ -210,13 +248,24 @@ func (e *EVM) ExecuteTransaction(tx *types.Transaction) (*ExecutionResult, error
 	return result, nil
 }
 
-// ExecuteBlock executes a block with parallel transactions
+// ExecuteBlock executes a block with parallel transactions. The block stays
+// revertible with RollbackTo until Finalize reaches its number.
 func (e *EVM) ExecuteBlock(block *types.Block) ([]*ExecutionResult, error) {
 	e.mu.Lock()
-	e.currentBlock = block.Number().Int64()
-	e.mu.Unlock()
+	defer e.mu.Unlock()
+
+	number := block.Number().Int64()
+	if number <= e.finalized {
+		return nil, fmt.Errorf("block %d is at or below finalized block %d", number, e.finalized)
+	}
+	if n := len(e.journal); n > 0 && e.journal[n-1].number >= number {
+		return nil, fmt.Errorf("block %d already executed, roll back first", number)
+	}
+	e.currentBlock = number
 
 	txs := block.Transactions()
+	journal := e.journalBlock(number, txs)
+	e.journal = append(e.journal, journal)
 	if len(txs) == 0 {
 		return []*ExecutionResult{}, nil
 	}
@@ -246,7 +295,7 @@ func (e *EVM) ExecuteBlock(block *types.Block) ([]*ExecutionResult, error) {
 			defer shard.mu.Unlock()
 
 			for _, tx := range txList {
-				result, _ := e.ExecuteTransaction(tx)
+				result, _ := e.executeTransaction(shard, tx, time.Now())
 				resultsCh <- result
 			}
 		}(shardID, shardTxList)
@@ -308,6 +357,77 @@ func (e *EVM) ExecuteTransactions(txs []*types.Transaction) ([]*ExecutionResult,
 	return results, nil
 }
 
+// journalBlock snapshots every shard before a block executes
+func (e *EVM) journalBlock(number int64, txs []*types.Transaction) blockJournal {
+	journal := blockJournal{number: number, shards: make([]shardJournal, len(e.shards))}
+	for i, shard := range e.shards {
+		shard.mu.Lock()
+		journal.shards[i] = shardJournal{
+			blockNumber: shard.BlockNumber,
+			txCount:     len(shard.Transactions),
+			snapshot:    shard.State.Snapshot(),
+		}
+		shard.mu.Unlock()
+	}
+	for _, tx := range txs {
+		id := e.selectShard(tx.Hash())
+		journal.shards[id].receipts = append(journal.shards[id].receipts, tx.Hash())
+	}
+	return journal
+}
+
+// RollbackTo reverts the executed blocks above a block number, newest
+// first, e.g. after a fork choice reorg. Finalized blocks cannot be reverted.
+func (e *EVM) RollbackTo(number int64) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if number < e.finalized {
+		return fmt.Errorf("cannot roll back below finalized block %d", e.finalized)
+	}
+
+	reverted := 0
+	for len(e.journal) > 0 && e.journal[len(e.journal)-1].number > number {
+		journal := e.journal[len(e.journal)-1]
+		e.journal = e.journal[:len(e.journal)-1]
+
+		for i, sj := range journal.shards {
+			shard := e.shards[i]
+			shard.mu.Lock()
+			shard.State.RevertToSnapshot(sj.snapshot)
+			shard.BlockNumber = sj.blockNumber
+			shard.Transactions = shard.Transactions[:sj.txCount]
+			for _, hash := range sj.receipts {
+				delete(shard.Results, hash)
+			}
+			shard.mu.Unlock()
+		}
+		reverted++
+	}
+	if reverted > 0 {
+		e.currentBlock = number
+		fmt.Printf("[EVM] Rolled back %d blocks to block %d\n", reverted, number)
+	}
+	return nil
+}
+
+// Finalize makes the executed blocks up to a block number permanent
+func (e *EVM) Finalize(number int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if number <= e.finalized {
+		return
+	}
+	e.finalized = number
+
+	keep := 0
+	for keep < len(e.journal) && e.journal[keep].number <= number {
+		keep++
+	}
+	e.journal = append([]blockJournal{}, e.journal[keep:]...)
+}
+
 // selectShard determines which shard to use for a transaction
 func (e *EVM) selectShard(txHash common.Hash) int {
 	// Simple hash-based shard selection
@@ -348,7 +468,7 @@ func (e *EVM) recordBenchmark(blockNumber int64, txCount int, latency time.Durat
 }
 
 // benchmarkCollector collects and prints performance metrics
-func (e *EVM) benchmarkCollector() {
+func (e *EVM) benchmarkCollector(ctx context.Context) {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
@@ -356,6 +476,8 @@ func (e *EVM) benchmarkCollector() {
 		select {
 		case <-ticker.C:
 			e.printBenchmark()
+		case <-ctx.Done():
+			return
 		}
 	}
 }
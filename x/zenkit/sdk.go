@@ -56,14 +56,16 @@ type Contract struct {
 
 // TransactionRequest represents a transaction request
 type TransactionRequest struct {
-	From     common.Address `json:"from"`
-	To       common.Address `json:"to"`
-	Value    string         `json:"value"`
-	Data     string         `json:"data"`
-	GasLimit uint64         `json:"gas_limit"`
-	GasPrice string         `json:"gas_price"`
-	Nonce    uint64         `json:"nonce"`
-	ChainID  uint64         `json:"chain_id"`
+	From              common.Address `json:"from"`
+	To                common.Address `json:"to"`
+	Value             string         `json:"value"`
+	Data              string         `json:"data"`
+	GasLimit          uint64         `json:"gas_limit"`
+	GasPrice          string         `json:"gas_price"`
+	Nonce             uint64         `json:"nonce"`
+	ChainID           uint64         `json:"chain_id"`
+	FeePayer          common.Address `json:"fee_payer,omitempty"`           // Sponsor paying the fee, zero for From
+	FeePayerSignature string         `json:"fee_payer_signature,omitempty"` // Fee payer's co-signature
 }
 
 // SDK provides developer tools and utilities
@@ -197,11 +199,20 @@ func (s *SDK) DeployContract(contractName, bytecode, abi string) (common.Address
 	return address, txHash, nil
 }
 
-// BuildTransaction builds a transaction
+// BuildTransaction builds a transaction. A sponsored one must be co-signed
+// by its fee payer before the sender signs it.
 func (s *SDK) BuildTransaction(req TransactionRequest) (string, error) {
 	// In production: actual transaction building
 	fmt.Println("[ZENKIT] Building transaction")
 
+	if req.FeePayer != (common.Address{}) {
+		if req.FeePayerSignature == "" {
+			fmt.Printf("[ZENKIT] Transaction awaits co-signature of fee payer %s\n", req.FeePayer.Hex())
+		} else if err := VerifyFeePayer(req); err != nil {
+			return "", err
+		}
+	}
+
 	jsonData, _ := json.MarshalIndent(req, "", "  ")
 	return string(jsonData), nil
 }
@@ -214,6 +225,53 @@ func (s *SDK) SignTransaction(txData, privateKey string) (string, error) {
 	return "0x" + strings.Repeat("ab", 32), nil
 }
 
+// SponsorHash returns the hash a fee payer co-signs: the request without
+// its co-signature
+func SponsorHash(req TransactionRequest) common.Hash {
+	req.FeePayerSignature = ""
+	data, _ := json.Marshal(req)
+	return crypto.Keccak256Hash(data)
+}
+
+// CoSignTransaction signs a request as its fee payer, agreeing to pay its
+// fee. The fee payer's allowance for the sender must cover it on chain.
+func (s *SDK) CoSignTransaction(req *TransactionRequest, sponsorKey string) error {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(sponsorKey, "0x"))
+	if err != nil {
+		return fmt.Errorf("invalid fee payer key: %w", err)
+	}
+	if signer := crypto.PubkeyToAddress(key.PublicKey); signer != req.FeePayer {
+		return fmt.Errorf("key of %s cannot co-sign for fee payer %s", signer.Hex(), req.FeePayer.Hex())
+	}
+	sig, err := crypto.Sign(SponsorHash(*req).Bytes(), key)
+	if err != nil {
+		return fmt.Errorf("co-signing failed: %w", err)
+	}
+	req.FeePayerSignature = "0x" + common.Bytes2Hex(sig)
+
+	fmt.Printf("[ZENKIT] Fee payer %s co-signed transaction from %s\n", req.FeePayer.Hex(), req.From.Hex())
+	return nil
+}
+
+// VerifyFeePayer checks a sponsored request was co-signed by its fee payer
+func VerifyFeePayer(req TransactionRequest) error {
+	if req.FeePayer == (common.Address{}) {
+		return nil
+	}
+	sig := common.FromHex(req.FeePayerSignature)
+	if len(sig) != crypto.SignatureLength {
+		return fmt.Errorf("fee payer %s has not co-signed", req.FeePayer.Hex())
+	}
+	pub, err := crypto.SigToPub(SponsorHash(req).Bytes(), sig)
+	if err != nil {
+		return fmt.Errorf("invalid fee payer signature: %w", err)
+	}
+	if signer := crypto.PubkeyToAddress(*pub); signer != req.FeePayer {
+		return fmt.Errorf("co-signed by %s, not fee payer %s", signer.Hex(), req.FeePayer.Hex())
+	}
+	return nil
+}
+
 // CallContract performs a contract call
 func (s *SDK) CallContract(contractAddr common.Address, method string, args ...interface{}) (interface{}, error) {
 	fmt.Printf("[ZENKIT] Calling contract method: %s\n", method)